@@ -0,0 +1,676 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrChecksum is returned for a download that isn't the version of the file
+// it was meant to be.
+var ErrChecksum = errors.New("download doesn't match its checksum")
+
+// DefaultLimit is the cache size limit used until one is set.
+const DefaultLimit int64 = 10 << 30
+
+const limitKey = super.Setting + "cache_limit"
+
+// partial is the suffix of files that are still being downloaded.
+const partial = ".part"
+
+// Entry describes the downloaded copy of a remote file.
+type Entry struct {
+	Path     string
+	Size     int64
+	Checksum string
+	Accessed time.Time
+}
+
+// Stats summarises the contents of the cache.
+type Stats struct {
+	Entries int
+	Pinned  int
+	Size    int64
+	Limit   int64
+}
+
+// Cache manages the downloaded copies of remote files in the music store.
+// It keeps their total size under a limit by evicting the least recently
+// played ones first. Pinned files are never evicted.
+type Cache struct {
+	db    *badger.DB
+	limit int64
+	// Keyed by file name in the music store.
+	entries map[string]*Entry
+	// Keyed by path.
+	pinned map[string]*api.File
+	// owners are what pinned each file, keyed by path.
+	owners map[string]map[string]struct{}
+	mu     sync.Mutex
+}
+
+func New(db *badger.DB) (*Cache, error) {
+	err := os.Mkdir(super.LocalStorage(super.MusicStore), 0755)
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		slog.Error("creating local cache", "error", err)
+		return nil, err
+	}
+
+	c := &Cache{
+		db:      db,
+		limit:   DefaultLimit,
+		entries: make(map[string]*Entry),
+		pinned:  make(map[string]*api.File),
+		owners:  make(map[string]map[string]struct{}),
+	}
+
+	err = db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(limitKey))
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		if err == nil {
+			err = item.Value(func(v []byte) error {
+				return gob.NewDecoder(bytes.NewReader(v)).Decode(&c.limit)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(super.Cached)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var entry Entry
+			err := it.Item().Value(func(v []byte) error {
+				return gob.NewDecoder(bytes.NewReader(v)).Decode(&entry)
+			})
+			if err != nil {
+				return err
+			}
+
+			c.entries[string(it.Item().Key()[len(prefix):])] = &entry
+		}
+
+		prefix = []byte(super.Pinned)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			file := &api.File{}
+			err := it.Item().Value(func(v []byte) error {
+				return proto.Unmarshal(v, file)
+			})
+			if err != nil {
+				return err
+			}
+
+			owner, _, _ := strings.Cut(string(it.Item().Key()[len(prefix):]), "\x00")
+			c.own(owner, file)
+		}
+
+		return nil
+	})
+	if err != nil {
+		slog.Error("loading cache entries", "error", err)
+		return nil, err
+	}
+
+	err = c.cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, c.evict()
+}
+
+// cleanup removes leftovers of interrupted downloads, adopts files the
+// cache doesn't know about and forgets entries whose file is gone. Only
+// the music store is touched.
+func (c *Cache) cleanup() error {
+	dir := super.LocalStorage(super.MusicStore)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		slog.Error("os.ReadDir", "error", err)
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Interrupted downloads of pinned files are resumed later on, the
+	// ones of other versions are removed then.
+	pinned := c.pinnedNames()
+
+	// Files are named after their path, which can't be told from the name.
+	// Pinned ones get theirs, the rest when they are opened.
+	paths := make(map[string]string, len(c.pinned))
+	for path := range c.pinned {
+		paths[filepath.Base(super.MusicFile(path))] = path
+	}
+
+	found := make(map[string]bool)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		if name, _, _ := strings.Cut(file.Name(), "."); pinned[name] && strings.HasSuffix(file.Name(), partial) {
+			continue
+		}
+
+		if strings.HasSuffix(file.Name(), partial) {
+			err := os.Remove(filepath.Join(dir, file.Name()))
+			if err != nil {
+				slog.Warn("removing partial download", "file", file.Name(), "error", err)
+			}
+			continue
+		}
+
+		found[file.Name()] = true
+
+		if _, ok := c.entries[file.Name()]; ok {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			slog.Warn("file.Info", "file", file.Name(), "error", err)
+			continue
+		}
+
+		entry := &Entry{
+			Path:     paths[file.Name()],
+			Size:     info.Size(),
+			Accessed: info.ModTime(),
+		}
+
+		err = c.store(file.Name(), entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name := range c.entries {
+		if !found[name] {
+			err := c.remove(name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Open returns the cached copy of file. If there isn't one, or it is
+// stale because the file changed on the server, it returns fs.ErrNotExist.
+func (c *Cache) Open(file *api.File) (*os.File, error) {
+	location := super.MusicFile(file.Path)
+	name := filepath.Base(location)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	if entry.Checksum != "" && file.Checksum != "" && entry.Checksum != file.Checksum {
+		slog.Info("stale cache entry", "path", file.Path)
+
+		err := c.remove(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, fs.ErrNotExist
+	}
+
+	f, err := os.Open(location)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.Join(err, c.remove(name))
+	}
+
+	if err != nil {
+		slog.Error("os.Open", "path", file.Path, "error", err)
+		return nil, err
+	}
+
+	entry.Path = file.Path
+	entry.Accessed = time.Now()
+	if entry.Checksum == "" {
+		entry.Checksum = file.Checksum
+	}
+
+	err = c.store(name, entry)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Create returns a new partial file for file to be downloaded into.
+// Once the download is finished it must be passed to Commit, or to Abort
+// if the download failed.
+func (c *Cache) Create(file *api.File) (*os.File, error) {
+	location := super.MusicFile(file.Path)
+	f, err := os.CreateTemp(filepath.Dir(location), filepath.Base(location)+"-*"+partial)
+	if err != nil {
+		slog.Error("os.CreateTemp", "path", file.Path, "error", err)
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Resume is like Create but it reuses the partial file of an earlier
+// download of file, if any. It returns the file positioned at its end
+// and the offset to resume downloading from. Partial files of other
+// versions of the file are removed, they can't be resumed.
+func (c *Cache) Resume(file *api.File) (*os.File, int64, error) {
+	location := resumable(file)
+
+	parts, err := os.ReadDir(filepath.Dir(location))
+	if err != nil {
+		slog.Error("os.ReadDir", "path", file.Path, "error", err)
+		return nil, 0, err
+	}
+
+	hashed := filepath.Base(super.MusicFile(file.Path))
+	for _, part := range parts {
+		name := part.Name()
+		if name == filepath.Base(location) || !strings.HasPrefix(name, hashed+".") || !strings.HasSuffix(name, partial) {
+			continue
+		}
+
+		err := os.Remove(filepath.Join(filepath.Dir(location), name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("removing partial download", "file", name, "error", err)
+		}
+	}
+
+	f, err := os.OpenFile(location, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		slog.Error("os.OpenFile", "path", file.Path, "error", err)
+		return nil, 0, err
+	}
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		slog.Error("file.Seek", "path", file.Path, "error", err)
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, offset, nil
+}
+
+// resumable returns where the download of file is kept until finished, by
+// the checksum of the version downloaded.
+func resumable(file *api.File) string {
+	return super.MusicFile(file.Path) + "." + file.Checksum + partial
+}
+
+// Cached reports whether there is a copy of file. A copy of another
+// version of the file, with a different checksum, doesn't count.
+func (c *Cache) Cached(file *api.File) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[filepath.Base(super.MusicFile(file.Path))]
+	return ok && (entry.Checksum == "" || file.Checksum == "" || entry.Checksum == file.Checksum)
+}
+
+// Commit moves a finished download in place and evicts older files if
+// the cache grew over its limit. The partial file is left open. A download
+// that doesn't match the checksum of file returns ErrChecksum and should
+// be aborted.
+func (c *Cache) Commit(file *api.File, part *os.File) error {
+	err := part.Sync()
+	if err != nil {
+		slog.Error("file.Sync", "path", file.Path, "error", err)
+		return err
+	}
+
+	info, err := part.Stat()
+	if err != nil {
+		slog.Error("file.Stat", "path", file.Path, "error", err)
+		return err
+	}
+
+	if file.Checksum != "" {
+		h := sha256.New()
+		_, err = io.Copy(h, io.NewSectionReader(part, 0, info.Size()))
+		if err != nil {
+			slog.Error("reading download", "path", file.Path, "error", err)
+			return err
+		}
+
+		if fmt.Sprintf("%x", h.Sum(nil)) != file.Checksum {
+			slog.Warn("download doesn't match its checksum", "path", file.Path)
+			return ErrChecksum
+		}
+	}
+
+	location := super.MusicFile(file.Path)
+	err = os.Rename(part.Name(), location)
+	if err != nil {
+		slog.Error("os.Rename", "path", file.Path, "error", err)
+		return err
+	}
+
+	c.mu.Lock()
+	err = c.store(filepath.Base(location), &Entry{
+		Path:     file.Path,
+		Size:     info.Size(),
+		Checksum: file.Checksum,
+		Accessed: time.Now(),
+	})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return c.evict()
+}
+
+// Abort closes and removes a partial download.
+func (c *Cache) Abort(part *os.File) {
+	part.Close()
+
+	err := os.Remove(part.Name())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("removing partial download", "file", part.Name(), "error", err)
+	}
+}
+
+// Pin marks file so that it is never evicted, for owner, or unmarks it.
+// The owner is what was pinned, an album or a playlist for example, and
+// the file stays pinned as long as any owner has it pinned.
+func (c *Cache) Pin(owner string, file *api.File, pinned bool) error {
+	b, err := proto.Marshal(file)
+	if err != nil {
+		slog.Error("proto.Marshal", "error", err)
+		return err
+	}
+
+	key := []byte(super.Pinned + owner + "\x00" + file.Path)
+	err = c.db.Update(func(txn *badger.Txn) error {
+		if pinned {
+			return txn.Set(key, b)
+		}
+
+		return txn.Delete(key)
+	})
+	if err != nil {
+		slog.Error("badger.Update", "path", file.Path, "error", err)
+		return err
+	}
+
+	c.mu.Lock()
+	if pinned {
+		c.own(owner, file)
+	} else {
+		delete(c.owners[file.Path], owner)
+		if len(c.owners[file.Path]) == 0 {
+			delete(c.owners, file.Path)
+			delete(c.pinned, file.Path)
+		}
+	}
+	_, still := c.pinned[file.Path]
+	c.mu.Unlock()
+
+	if still {
+		return nil
+	}
+
+	// Drop what was downloaded of it so far.
+	err = os.Remove(resumable(file))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("removing partial download", "path", file.Path, "error", err)
+	}
+
+	return c.evict()
+}
+
+// own records that owner pinned file. It must be called with c.mu held,
+// or before c is shared.
+func (c *Cache) own(owner string, file *api.File) {
+	owners, ok := c.owners[file.Path]
+	if !ok {
+		owners = make(map[string]struct{})
+		c.owners[file.Path] = owners
+	}
+
+	owners[owner] = struct{}{}
+	c.pinned[file.Path] = file
+}
+
+// PinnedBy reports whether owner pinned the file at path.
+func (c *Cache) PinnedBy(owner, path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.owners[path][owner]
+	return ok
+}
+
+// Owned returns the files owner pinned.
+func (c *Cache) Owned(owner string) []*api.File {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var files []*api.File
+	for path, owners := range c.owners {
+		if _, ok := owners[owner]; ok {
+			files = append(files, c.pinned[path])
+		}
+	}
+
+	return files
+}
+
+// Owners returns everything that has files pinned.
+func (c *Cache) Owners() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	unique := make(map[string]struct{})
+	for _, owners := range c.owners {
+		for owner := range owners {
+			unique[owner] = struct{}{}
+		}
+	}
+
+	owners := make([]string, 0, len(unique))
+	for owner := range unique {
+		owners = append(owners, owner)
+	}
+
+	sort.Strings(owners)
+
+	return owners
+}
+
+// Pinned reports whether the file at path is pinned.
+func (c *Cache) Pinned(path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.pinned[path]
+	return ok
+}
+
+// Missing returns the pinned files that haven't been downloaded yet.
+func (c *Cache) Missing() []*api.File {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var missing []*api.File
+	for path, file := range c.pinned {
+		if _, ok := c.entries[filepath.Base(super.MusicFile(path))]; !ok {
+			missing = append(missing, file)
+		}
+	}
+
+	return missing
+}
+
+// SetLimit sets the size limit of the cache in bytes and evicts
+// files if the cache is now over it.
+func (c *Cache) SetLimit(limit int64) error {
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(limit)
+	if err != nil {
+		slog.Error("gob.Encode", "error", err)
+		return err
+	}
+
+	err = c.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(limitKey), buf.Bytes())
+	})
+	if err != nil {
+		slog.Error("badger.Set", "error", err)
+		return err
+	}
+
+	c.mu.Lock()
+	c.limit = limit
+	c.mu.Unlock()
+
+	return c.evict()
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := Stats{
+		Entries: len(c.entries),
+		Limit:   c.limit,
+	}
+
+	pinned := c.pinnedNames()
+	for name, entry := range c.entries {
+		stats.Size += entry.Size
+		if pinned[name] {
+			stats.Pinned++
+		}
+	}
+
+	return stats
+}
+
+// evict removes the least recently accessed files that aren't
+// pinned until the cache fits in its limit.
+func (c *Cache) evict() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pinned := c.pinnedNames()
+
+	var size int64
+	var names []string
+	for name, entry := range c.entries {
+		size += entry.Size
+		if !pinned[name] {
+			names = append(names, name)
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return c.entries[names[i]].Accessed.Before(c.entries[names[j]].Accessed)
+	})
+
+	for _, name := range names {
+		if size <= c.limit {
+			break
+		}
+
+		size -= c.entries[name].Size
+
+		slog.Info("evicting cached file", "path", c.entries[name].Path)
+
+		err := c.remove(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pinnedNames returns the names in the music store of the pinned files.
+// Entries are matched by name, not all of them know their path. It must
+// be called with c.mu held.
+func (c *Cache) pinnedNames() map[string]bool {
+	names := make(map[string]bool, len(c.pinned))
+	for path := range c.pinned {
+		names[filepath.Base(super.MusicFile(path))] = true
+	}
+
+	return names
+}
+
+// store persists entry. It must be called with c.mu held.
+func (c *Cache) store(name string, entry *Entry) error {
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(entry)
+	if err != nil {
+		slog.Error("gob.Encode", "error", err)
+		return err
+	}
+
+	err = c.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(super.Cached+name), buf.Bytes())
+	})
+	if err != nil {
+		slog.Error("badger.Set", "error", err)
+		return err
+	}
+
+	c.entries[name] = entry
+
+	return nil
+}
+
+// remove deletes the file and its entry. It must be called with c.mu held.
+func (c *Cache) remove(name string) error {
+	err := os.Remove(super.LocalStorage(super.MusicStore, super.Storage(name)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Error("os.Remove", "file", name, "error", err)
+		return err
+	}
+
+	err = c.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(super.Cached + name))
+	})
+	if err != nil {
+		slog.Error("badger.Delete", "error", err)
+		return err
+	}
+
+	delete(c.entries, name)
+
+	return nil
+}
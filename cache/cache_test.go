@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// newCache returns a cache with its music store under a HOME of the test,
+// and its db.
+func newCache(t *testing.T) (*Cache, *badger.DB) {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+
+	err := os.MkdirAll(super.LocalStorage(), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	c, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c, db
+}
+
+// download stores size bytes as file, last played at accessed.
+func download(t *testing.T, c *Cache, file *api.File, size int, accessed time.Time) {
+	t.Helper()
+
+	part, err := c.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer part.Close()
+
+	_, err = part.Write(make([]byte, size))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Commit(file, part)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.mu.Lock()
+	for _, entry := range c.entries {
+		if entry.Path == file.Path {
+			entry.Accessed = accessed
+		}
+	}
+	c.mu.Unlock()
+}
+
+// kept checks which of files are still cached.
+func kept(t *testing.T, c *Cache, files map[string]*api.File, want ...string) {
+	t.Helper()
+
+	keep := make(map[string]bool)
+	for _, name := range want {
+		keep[name] = true
+	}
+
+	for name, file := range files {
+		_, err := os.Stat(super.MusicFile(file.Path))
+		if got := err == nil; got != keep[name] || c.Cached(file) != keep[name] {
+			t.Errorf("%s cached %v, want %v", name, got, keep[name])
+		}
+	}
+}
+
+func TestEvictKeepsPinned(t *testing.T) {
+	c, db := newCache(t)
+
+	files := make(map[string]*api.File)
+	start := time.Now().Add(-time.Hour)
+	for i, name := range []string{"a", "b", "c", "d"} {
+		files[name] = &api.File{Path: "/" + name + ".flac", Source: "server"}
+		// a was played the longest ago.
+		download(t, c, files[name], 100, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	pin := func(owner, name string, pinned bool) {
+		t.Helper()
+
+		err := c.Pin(owner, files[name], pinned)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pin("album", "a", true)
+	pin("album", "b", true)
+	pin("playlist", "b", true)
+
+	// The least recently played files go first, unless pinned.
+	err := c.SetLimit(250)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept(t, c, files, "a", "b")
+
+	// Still pinned by the playlist.
+	pin("album", "a", false)
+	pin("album", "b", false)
+
+	if !c.Pinned(files["b"].Path) || c.Pinned(files["a"].Path) {
+		t.Errorf("pinned a %v, b %v, want only b", c.Pinned(files["a"].Path), c.Pinned(files["b"].Path))
+	}
+
+	err = c.SetLimit(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept(t, c, files, "b")
+
+	// Pins are stored with what pinned them.
+	c, err = New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.PinnedBy("playlist", files["b"].Path) || c.PinnedBy("album", files["b"].Path) {
+		t.Error("the pins of b changed on restart")
+	}
+
+	err = c.SetLimit(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept(t, c, files, "b")
+
+	pin("playlist", "b", false)
+	kept(t, c, files)
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bh90210/super/server/api"
+	"google.golang.org/grpc"
+)
+
+// DefaultConcurrency is how many files are downloaded at the same time.
+const DefaultConcurrency = 2
+
+// retryDelay is how long a failed download waits before it is queued again.
+const retryDelay = 30 * time.Second
+
+// Progress reports the state of the background downloads.
+type Progress struct {
+	// Active is the number of files being downloaded.
+	Active int
+	// Queued is the number of files waiting to be downloaded.
+	Queued int
+	// Path is the file the progress update is about.
+	Path string
+	// Bytes is how much of Path has been downloaded so far.
+	Bytes int64
+}
+
+// Downloader fetches pinned files in the background so they are
+// available offline. Interrupted downloads resume where they stopped.
+type Downloader struct {
+	cache    *Cache
+	conn     *grpc.ClientConn
+	queue    []*api.File
+	pending  map[string]bool
+	active   int
+	wake     chan struct{}
+	progress chan Progress
+	mu       sync.Mutex
+}
+
+// NewDownloader starts concurrency workers, downloading over conn, and
+// queues the pinned files that are still missing from c.
+func NewDownloader(c *Cache, conn *grpc.ClientConn, concurrency int) (*Downloader, error) {
+	d := &Downloader{
+		cache:    c,
+		conn:     conn,
+		pending:  make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+		progress: make(chan Progress, 64),
+	}
+
+	for range concurrency {
+		go d.work()
+	}
+
+	d.Download(c.Missing()...)
+
+	return d, nil
+}
+
+// Download queues files to be downloaded. Files that are already
+// queued or being downloaded are skipped.
+func (d *Downloader) Download(files ...*api.File) {
+	d.mu.Lock()
+	for _, file := range files {
+		if d.pending[file.Path] {
+			continue
+		}
+
+		d.pending[file.Path] = true
+		d.queue = append(d.queue, file)
+	}
+	d.mu.Unlock()
+
+	d.report("", 0)
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Progress returns a channel that receives the download progress.
+func (d *Downloader) Progress() <-chan Progress {
+	return d.progress
+}
+
+func (d *Downloader) work() {
+	for {
+		d.mu.Lock()
+		if len(d.queue) == 0 {
+			d.mu.Unlock()
+			<-d.wake
+			continue
+		}
+
+		file := d.queue[0]
+		d.queue = d.queue[1:]
+		d.active++
+		// Let the other workers know there is more to do.
+		if len(d.queue) > 0 {
+			select {
+			case d.wake <- struct{}{}:
+			default:
+			}
+		}
+		d.mu.Unlock()
+
+		err := d.download(file)
+
+		d.mu.Lock()
+		d.active--
+		delete(d.pending, file.Path)
+		d.mu.Unlock()
+
+		d.report(file.Path, 0)
+
+		if err != nil {
+			slog.Error("download", "path", file.Path, "error", err, "retry", retryDelay)
+
+			time.AfterFunc(retryDelay, func() {
+				if d.cache.Pinned(file.Path) && !d.cache.Cached(file) {
+					d.Download(file)
+				}
+			})
+		}
+	}
+}
+
+func (d *Downloader) download(file *api.File) error {
+	// It might have been unpinned or downloaded while queued.
+	if !d.cache.Pinned(file.Path) || d.cache.Cached(file) {
+		return nil
+	}
+
+	part, offset, err := d.cache.Resume(file)
+	if err != nil {
+		return err
+	}
+
+	defer part.Close()
+
+	client := api.NewLibraryClient(d.conn)
+
+	response, err := client.Download(context.Background(), &api.DownloadRequest{
+		Path:   file.Path,
+		Offset: offset,
+	})
+	if err != nil {
+		slog.Error("client.Download", "error", err)
+		return err
+	}
+
+	for {
+		data, err := response.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			slog.Error("response.Recv", "path", file.Path, "error", err)
+			return err
+		}
+
+		n, err := part.Write(data.Data)
+		if err != nil {
+			slog.Error("file.Write", "path", file.Path, "error", err)
+			return err
+		}
+
+		offset += int64(n)
+		d.report(file.Path, offset)
+	}
+
+	err = d.cache.Commit(file, part)
+	if errors.Is(err, ErrChecksum) {
+		// The file changed on the server while downloading, the retry
+		// starts over.
+		d.cache.Abort(part)
+	}
+
+	return err
+}
+
+func (d *Downloader) report(path string, bytes int64) {
+	d.mu.Lock()
+	progress := Progress{
+		Active: d.active,
+		Queued: len(d.queue),
+		Path:   path,
+		Bytes:  bytes,
+	}
+	d.mu.Unlock()
+
+	select {
+	case d.progress <- progress:
+	default:
+	}
+}
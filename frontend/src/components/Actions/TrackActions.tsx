@@ -47,6 +47,8 @@ interface TrackActionsWrapperProps {
   album?: Album | null;
   artist?: Artist | null;
   onSavedToggle?: () => void;
+  // Removes the track from the playlist shown, when it is one of the app.
+  onRemove?: () => void;
   playlist?: Playlist | null;
   track: Track | Spotify.Track;
   trigger?: ('contextMenu' | 'click')[];
@@ -54,7 +56,8 @@ interface TrackActionsWrapperProps {
 }
 
 export const TrackActionsWrapper: FC<TrackActionsWrapperProps> = memo((props) => {
-  const { children, artist, track, playlist, canEdit, album, saved, onSavedToggle } = props;
+  const { children, artist, track, playlist, canEdit, album, saved, onSavedToggle, onRemove } =
+    props;
 
   const { t } = useTranslation(['playlist']);
 
@@ -187,6 +190,14 @@ export const TrackActionsWrapper: FC<TrackActionsWrapperProps> = memo((props) =>
         key: '2',
         icon: <DeleteIcon />,
         onClick: () => {
+          if (onRemove) {
+            onRemove();
+            message.open({
+              type: 'success',
+              content: t('Removed from playlist'),
+            });
+            return;
+          }
           if (!handleUserValidation()) return;
           return playlistService
             .removePlaylistItems(playlist!.id, [track.uri], playlist?.snapshot_id!)
@@ -39,6 +39,7 @@ interface DefaultProps {
   playlist?: Playlist | null;
   artist?: Artist | null;
   onToggleLike?: () => void;
+  onRemove?: () => void;
 
   view: 'LIST' | 'COMPACT';
   context: {
@@ -342,6 +343,7 @@ export const SongView = (props: SongViewProps) => {
       trigger={['contextMenu']}
       saved={props.onToggleLike ? props.saved : undefined}
       onSavedToggle={props.onToggleLike ? props.onToggleLike : undefined}
+      onRemove={props.onRemove}
     >
       <button
         onClick={isMobile ? onClick : undefined}
@@ -15,4 +15,9 @@ export const playlist = {
   Title: 'Title',
   Album: 'Album',
   Artist: 'Artist',
+  Rename: 'Rename',
+  Duplicate: 'Duplicate',
+  'Playlist duplicated': 'Playlist duplicated',
+  Download: 'Download',
+  'Remove download': 'Remove download',
 };
@@ -38,4 +38,9 @@ export const playlist = {
   Unfollow: 'Dejar de seguir',
   'Artist unfollowed': 'Dejaste de seguir al artista',
   'Artist followed': 'Empezaste a seguir al artista',
+  Rename: 'Cambiar nombre',
+  Duplicate: 'Duplicar',
+  'Playlist duplicated': 'Playlist duplicada',
+  Download: 'Descargar',
+  'Remove download': 'Eliminar descarga',
 };
@@ -0,0 +1,51 @@
+/**
+ * @description A track as the app sends it, api.File.
+ */
+export interface SuperFile {
+  artist?: string;
+  album?: string;
+  track?: string;
+  duration?: string;
+  path: string;
+  source?: string;
+  cached?: boolean;
+  year?: number;
+  genre?: string;
+  format?: string;
+  trackNumber?: number;
+  durationSeconds?: number;
+}
+
+/**
+ * @description The role of the user on a playlist, api.Role: none, viewer, editor or owner. Playlists that were never synced have none.
+ */
+export type SuperRole = 0 | 1 | 2 | 3;
+
+/**
+ * @description A playlist as the app sends it, api.Playlist. Tracks are paths.
+ */
+export interface SuperPlaylist {
+  id: string;
+  name?: string;
+  tracks?: string[];
+  /** @description Unix seconds. */
+  created?: number;
+  /** @description Unix seconds. */
+  modified?: number;
+  owner?: string;
+  role?: SuperRole;
+}
+
+export type SuperRecentKind = 'query' | 'song' | 'artist' | 'album';
+
+/**
+ * @description A recent search as the app sends it, search.Recent.
+ */
+export interface SuperRecent {
+  Kind: SuperRecentKind;
+  /** @description What was typed, the artist, the album or the path of the song. */
+  Name: string;
+  /** @description The song, only set for songs. */
+  File?: SuperFile | null;
+  At: string;
+}
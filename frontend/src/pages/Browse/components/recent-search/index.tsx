@@ -1,4 +1,4 @@
-import { memo } from 'react';
+import { memo, useEffect } from 'react';
 
 // Utils
 import { useTranslation } from 'react-i18next';
@@ -13,6 +13,12 @@ export const RecentSearchesActions = memo(() => {
   const [t] = useTranslation(['search']);
   const items = useAppSelector((state) => state.searchHistory.items);
 
+  useEffect(() => {
+    const stop = dispatch(searchHistoryActions.listen());
+    dispatch(searchHistoryActions.fetchItems());
+    return stop;
+  }, [dispatch]);
+
   if (!items || !items.length) {
     return null;
   }
@@ -0,0 +1,34 @@
+import { FC, useCallback } from 'react';
+
+import { useTranslation } from 'react-i18next';
+import { Tooltip } from '../../../components/Tooltip';
+import { AddedToLibrary, AddToLibrary } from '../../../components/Icons';
+
+// Redux
+import { playlistActions } from '../../../store/slices/playlist';
+import { useAppDispatch, useAppSelector } from '../../../store/store';
+
+/**
+ * @description Keeps the tracks of the playlist shown, and the ones added to it later, available offline.
+ */
+export const PinPlaylistButton: FC<{ size?: number }> = ({ size = 32 }) => {
+  const dispatch = useAppDispatch();
+  const { t } = useTranslation(['playlist']);
+  const pinned = useAppSelector((state) => state.playlist.pinned);
+
+  const onToggle = useCallback(() => {
+    dispatch(playlistActions.pinPlaylist(!pinned));
+  }, [dispatch, pinned]);
+
+  return (
+    <Tooltip title={t(pinned ? 'Remove download' : 'Download')}>
+      <button onClick={onToggle}>
+        {pinned ? (
+          <AddedToLibrary height={size} width={size} />
+        ) : (
+          <AddToLibrary height={size} width={size} />
+        )}
+      </button>
+    </Tooltip>
+  );
+};
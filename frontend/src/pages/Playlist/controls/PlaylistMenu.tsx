@@ -0,0 +1,90 @@
+import { FC, memo, useMemo, useState } from 'react';
+import { Dropdown, Input, MenuProps, Modal, message } from 'antd';
+import { DeleteIcon, EditIcon, AddToPlaylist } from '../../../components/Icons';
+
+// Services
+import { superService } from '../../../services/super';
+
+// Utils
+import { useTranslation } from 'react-i18next';
+import { useNavigate } from 'react-router-dom';
+
+// Redux
+import { useAppSelector } from '../../../store/store';
+
+/**
+ * @description The options of the playlist shown, the changes come back from the app as the playlists event.
+ */
+export const PlaylistMenu: FC<{ children: React.ReactNode }> = memo(({ children }) => {
+  const { t } = useTranslation(['playlist']);
+
+  const navigate = useNavigate();
+  const canEdit = useAppSelector((state) => state.playlist.canEdit);
+  const playlist = useAppSelector((state) => state.playlist.playlist);
+
+  const [name, setName] = useState<string | null>(null);
+
+  const items = useMemo(() => {
+    const items: MenuProps['items'] = [];
+    if (!playlist) return items;
+
+    if (canEdit) {
+      items.push({
+        label: t('Rename'),
+        key: 'rename',
+        icon: <EditIcon />,
+        onClick: () => setName(playlist.name),
+      });
+    }
+
+    items.push({
+      label: t('Duplicate'),
+      key: 'duplicate',
+      icon: <AddToPlaylist />,
+      onClick: () => {
+        return superService.duplicatePlaylist(playlist.id).then(() => {
+          message.open({ type: 'success', content: t('Playlist duplicated') });
+        });
+      },
+    });
+
+    if (canEdit) {
+      items.push(
+        { type: 'divider' },
+        {
+          label: t('Delete playlist'),
+          key: 'delete',
+          icon: <DeleteIcon />,
+          onClick: () => {
+            return superService.deletePlaylist(playlist.id).then(() => navigate('/'));
+          },
+        }
+      );
+    }
+
+    return items;
+  }, [canEdit, navigate, playlist, t]);
+
+  return (
+    <>
+      <Dropdown menu={{ items }} trigger={['click']}>
+        {children}
+      </Dropdown>
+
+      <Modal
+        centered
+        open={name !== null}
+        title={t('Rename')}
+        okText={t('Save')}
+        okButtonProps={{ disabled: !name?.trim() }}
+        onCancel={() => setName(null)}
+        onOk={() => {
+          superService.renamePlaylist(playlist!.id, name!.trim());
+          setName(null);
+        }}
+      >
+        <Input autoFocus value={name || ''} onChange={(e) => setName(e.target.value)} />
+      </Modal>
+    </>
+  );
+});
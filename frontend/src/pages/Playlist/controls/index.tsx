@@ -3,15 +3,15 @@ import { Col, Dropdown, Row, Space } from 'antd';
 import { PlayCircleButton } from './playCircle';
 import { Tooltip } from '../../../components/Tooltip';
 import { MenuDots, OrderCompactIcon, OrderListIcon } from '../../../components/Icons';
-import { AddPlaylistToLibraryButton } from './AddPlaylistToLibrary';
-import { PlayistActionsWrapper } from '../../../components/Actions/PlaylistActions';
+import { PlaylistMenu } from './PlaylistMenu';
+import { PinPlaylistButton } from './PinPlaylist';
 
 // Utils
 import { useTranslation } from 'react-i18next';
 
 // Redux
 import { useAppDispatch, useAppSelector } from '../../../store/store';
-import { playlistActions, refreshPlaylist } from '../../../store/slices/playlist';
+import { playlistActions } from '../../../store/slices/playlist';
 
 // Interfaces
 import type { FC } from 'react';
@@ -22,12 +22,9 @@ export const PlaylistControls: FC = () => {
   const dispatch = useAppDispatch();
   const [tor] = useTranslation(['order']);
 
-  const user = useAppSelector((state) => state.auth.user);
   const view = useAppSelector((state) => state.playlist.view);
   const playlist = useAppSelector((state) => state.playlist.playlist);
 
-  const isMine = playlist?.owner?.id === user?.id;
-
   const items = filters.map((filter) => ({
     key: filter,
     label: tor(filter),
@@ -41,19 +38,11 @@ export const PlaylistControls: FC = () => {
           <Space align='center'>
             <PlayCircleButton />
 
-            {!isMine ? (
-              <div className='scale' style={{ marginRight: 10 }}>
-                <AddPlaylistToLibraryButton id={playlist!.id} />
-              </div>
-            ) : null}
+            <div className='scale' style={{ marginRight: 10 }}>
+              <PinPlaylistButton />
+            </div>
 
-            <PlayistActionsWrapper
-              playlist={playlist!}
-              trigger={['click']}
-              onRefresh={() => {
-                dispatch(refreshPlaylist(playlist!.id));
-              }}
-            >
+            <PlaylistMenu>
               <div>
                 <Tooltip title={`${tor('More options for')} ${playlist?.name}`}>
                   <div className='scale'>
@@ -61,7 +50,7 @@ export const PlaylistControls: FC = () => {
                   </div>
                 </Tooltip>
               </div>
-            </PlayistActionsWrapper>
+            </PlaylistMenu>
           </Space>
         </Col>
         <Col>
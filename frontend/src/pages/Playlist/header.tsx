@@ -3,7 +3,6 @@ import { Col, Row, Space } from 'antd';
 import { Link } from 'react-router-dom';
 import { PlaylistTableHeader } from './table/header';
 import { PlayCircleButton } from './controls/playCircle';
-import { editPlaylistModalActions } from '../../store/slices/editPlaylistModal';
 
 // I18n
 import { useTranslation } from 'react-i18next';
@@ -21,7 +20,7 @@ import { sumTracksLength } from '../../utils/spotify/sumTracksLength';
 
 // Redux
 import { isRightLayoutOpen } from '../../store/slices/ui';
-import { useAppDispatch, useAppSelector } from '../../store/store';
+import { useAppSelector } from '../../store/store';
 
 interface PlaylistHeaderProps {
   color: string;
@@ -32,16 +31,12 @@ interface PlaylistHeaderProps {
 export const PlaylistHeader: FC<PlaylistHeaderProps> = ({ container, color, sectionContainer }) => {
   const { t } = useTranslation(['playlist']);
 
-  const dispatch = useAppDispatch();
-  const user = useAppSelector((state) => state.auth.user);
   const owner = useAppSelector((state) => state.playlist.user);
   const playlist = useAppSelector((state) => state.playlist.playlist);
   const tracks = useAppSelector((state) => state.playlist.tracks);
 
   const time = useMemo(() => sumTracksLength(tracks.map((t) => t.track)), [tracks]);
 
-  const isMine = user?.id === owner?.id;
-
   const [headerWidth, setHeaderWidth] = useState(0);
   const [activeHeader, setActiveHeader] = useState(false);
   const [activeTable, setActiveTable] = useState(false);
@@ -116,32 +111,6 @@ export const PlaylistHeader: FC<PlaylistHeaderProps> = ({ container, color, sect
         <Row gutter={[24, 24]} align={'middle'}>
           <Col xs={24} sm={6} lg={5}>
             <div>
-              {isMine ? (
-                <div className='playlist-img-overlay'>
-                  <div className='playlist-img-overlay-container'>
-                    <button
-                      type='button'
-                      aria-haspopup='true'
-                      onClick={() => dispatch(editPlaylistModalActions.setPlaylist({ playlist }))}
-                    >
-                      <div className='icon'>
-                        <svg
-                          data-encore-id='icon'
-                          role='img'
-                          height={50}
-                          width={50}
-                          aria-hidden='true'
-                          viewBox='0 0 24 24'
-                          style={{ margin: '0 auto' }}
-                        >
-                          <path d='M17.318 1.975a3.329 3.329 0 1 1 4.707 4.707L8.451 20.256c-.49.49-1.082.867-1.735 1.103L2.34 22.94a1 1 0 0 1-1.28-1.28l1.581-4.376a4.726 4.726 0 0 1 1.103-1.735L17.318 1.975zm3.293 1.414a1.329 1.329 0 0 0-1.88 0L5.159 16.963c-.283.283-.5.624-.636 1l-.857 2.372 2.371-.857a2.726 2.726 0 0 0 1.001-.636L20.611 5.268a1.329 1.329 0 0 0 0-1.879z'></path>
-                        </svg>
-                        <span data-encore-id='text'>Choose photo</span>
-                      </div>
-                    </button>
-                  </div>
-                </div>
-              ) : null}
               <img
                 src={
                   playlist?.images && playlist?.images.length
@@ -159,12 +128,7 @@ export const PlaylistHeader: FC<PlaylistHeaderProps> = ({ container, color, sect
                 <p className='text-white'>
                   {t(playlist?.public ? 'Playlist' : 'Private Playlist')}
                 </p>
-                <div
-                  className={isMine ? `pointer` : ''}
-                  onClick={() => {
-                    if (isMine) dispatch(editPlaylistModalActions.setPlaylist({ playlist }));
-                  }}
-                >
+                <div>
                   <h1 className='playlist-title'>{playlist?.name}</h1>
                   <p className='playlist-description'>{getPlaylistDescription(playlist!)}</p>
                 </div>
@@ -36,10 +36,12 @@ const PlaylistView: FC<{ container: RefObject<HTMLDivElement | null> }> = (props
   }, [playlist]);
 
   useEffect(() => {
+    const stop = dispatch(playlistActions.listen());
     if (playlistId) {
       dispatch(playlistActions.fetchPlaylist(playlistId));
     }
     return () => {
+      stop();
       dispatch(playlistActions.setPlaylist({ playlist: null }));
     };
   }, [dispatch, playlistId]);
@@ -23,6 +23,10 @@ export const Song = (props: SongProps) => {
     dispatch(playlistActions.setTrackLikeState({ id: song.track.id, saved: !song.saved }));
   }, [dispatch, song.saved, song.track.id]);
 
+  const remove = useCallback(() => {
+    dispatch(playlistActions.removeTrackAt(index));
+  }, [dispatch, index]);
+
   return (
     <SongView
       activable
@@ -34,6 +38,7 @@ export const Song = (props: SongProps) => {
       playlist={playlist}
       addedAt={song.added_at}
       onToggleLike={toggleLike}
+      onRemove={remove}
       context={{
         context_uri: playlist?.uri,
         offset: { position: index },
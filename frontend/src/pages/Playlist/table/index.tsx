@@ -6,9 +6,6 @@ import { PlaylistControls } from '../controls';
 import ReactDragListView from 'react-drag-listview';
 import { PlaylistRecommendations } from '../recommendations';
 
-// Services
-import { playlistService } from '../../../services/playlists';
-
 // Redux
 import { playlistActions } from '../../../store/slices/playlist';
 import { useAppDispatch, useAppSelector } from '../../../store/store';
@@ -18,7 +15,6 @@ import { DEFAULT_PAGE_COLOR } from '../../../constants/spotify';
 
 // Interfaces
 import { memo, type FC } from 'react';
-import InfiniteScroll from 'react-infinite-scroll-component';
 
 interface PlaylistListProps {
   color: string;
@@ -49,52 +45,31 @@ export const PlaylistList: FC<PlaylistListProps> = memo(({ color }) => {
         <Divider />
       )}
 
-      <InfiniteScroll
-        loader={null}
-        scrollThreshold={0.5}
-        dataLength={tracks.length}
-        next={() => {
-          dispatch(playlistActions.getNextTracks());
-        }}
-        hasMore={tracks.length < playlist?.tracks?.total!}
-      >
-        {hasTracks ? (
-          <div style={{ paddingBottom: 30 }}>
-            {canEdit ? (
-              <div>
-                <ReactDragListView
-                  nodeSelector='button'
-                  lineClassName='drag-line'
-                  onDragEnd={(from, to) => {
-                    playlistService
-                      .reorderPlaylistItems(
-                        playlist?.id!,
-                        [tracks[from].track.uri],
-                        from,
-                        to,
-                        1,
-                        playlist?.snapshot_id!
-                      )
-                      .then(() => {
-                        dispatch(playlistActions.reorderTracks({ from, to }));
-                      });
-                  }}
-                >
-                  {tracks.map((song, index) => (
-                    <SongView song={song} key={`${song.added_at}-${song.track.id}`} index={index} />
-                  ))}
-                </ReactDragListView>
-              </div>
-            ) : (
-              <div>
+      {hasTracks ? (
+        <div style={{ paddingBottom: 30 }}>
+          {canEdit ? (
+            <div>
+              <ReactDragListView
+                nodeSelector='button'
+                lineClassName='drag-line'
+                onDragEnd={(from, to) => {
+                  dispatch(playlistActions.moveTrack({ from, to }));
+                }}
+              >
                 {tracks.map((song, index) => (
-                  <SongView song={song} key={`${song.added_at}-${song.track.id}`} index={index} />
+                  <SongView song={song} key={`${index}-${song.track.id}`} index={index} />
                 ))}
-              </div>
-            )}
-          </div>
-        ) : null}
-      </InfiniteScroll>
+              </ReactDragListView>
+            </div>
+          ) : (
+            <div>
+              {tracks.map((song, index) => (
+                <SongView song={song} key={`${index}-${song.track.id}`} index={index} />
+              ))}
+            </div>
+          )}
+        </div>
+      ) : null}
 
       <PlaylistRecommendations />
     </div>
@@ -6,20 +6,29 @@ import RecentlySearchedContent from './container/content';
 import { useNavigate } from 'react-router-dom';
 
 // Redux
-import { useAppSelector } from '../../../store/store';
+import { useAppDispatch, useAppSelector } from '../../../store/store';
+import { searchHistoryActions } from '../../../store/slices/searchHistory';
 
 // Constants
 import { DEFAULT_PAGE_COLOR } from '../../../constants/spotify';
 
 export const RecentlySearchedPage = memo(() => {
   const navigate = useNavigate();
+  const dispatch = useAppDispatch();
+  const loaded = useAppSelector((state) => state.searchHistory.loaded);
   const searches = useAppSelector((state) => state.searchHistory.items);
 
   useEffect(() => {
-    if (searches.length < 7) {
+    const stop = dispatch(searchHistoryActions.listen());
+    dispatch(searchHistoryActions.fetchItems());
+    return stop;
+  }, [dispatch]);
+
+  useEffect(() => {
+    if (loaded && searches.length < 7) {
       navigate('/search');
     }
-  }, [navigate, searches]);
+  }, [navigate, loaded, searches]);
 
   return (
     <>
@@ -0,0 +1,101 @@
+import { Events } from '@wailsio/runtime';
+
+// Interfaces
+import type { SuperRecentKind } from '../interfaces/super';
+
+/**
+ * @description Listen to an event of the app, returns the function that stops listening.
+ */
+const on = <T>(name: string, callback: (data: T) => void) => {
+  return Events.On(name, (event) => callback(event.data as T));
+};
+
+/**
+ * @description Ask for the playlists, the app answers with the playlists and playlists.pinned events.
+ */
+const getPlaylists = async () => {
+  return Events.Emit('front.playlists', null);
+};
+
+/**
+ * @description Show a playlist, the app answers with its tracks as the list event.
+ */
+const openPlaylist = async (playlistId: string) => {
+  return Events.Emit('front.playlist.open', playlistId);
+};
+
+const renamePlaylist = async (playlistId: string, name: string) => {
+  return Events.Emit('front.playlist.rename', { id: playlistId, name });
+};
+
+const duplicatePlaylist = async (playlistId: string) => {
+  return Events.Emit('front.playlist.duplicate', playlistId);
+};
+
+const deletePlaylist = async (playlistId: string) => {
+  return Events.Emit('front.playlist.delete', playlistId);
+};
+
+/**
+ * @description Remove the track at index of the playlist.
+ */
+const removePlaylistItem = async (playlistId: string, index: number) => {
+  return Events.Emit('front.playlist.remove', { id: playlistId, index });
+};
+
+/**
+ * @description Move the track at from of the playlist to to.
+ */
+const movePlaylistItem = async (playlistId: string, from: number, to: number) => {
+  return Events.Emit('front.playlist.move', { id: playlistId, from, to });
+};
+
+/**
+ * @description Keep the tracks of a playlist, and the ones added to it later, available offline.
+ */
+const pinPlaylist = async (playlistId: string) => {
+  return Events.Emit('front.pin.playlist', playlistId);
+};
+
+const unpinPlaylist = async (playlistId: string) => {
+  return Events.Emit('front.unpin.playlist', playlistId);
+};
+
+/**
+ * @description Ask for the recent searches, the app answers with the search.recent event, as it does after every change.
+ */
+const getRecentSearches = async () => {
+  return Events.Emit('front.search.recent', null);
+};
+
+/**
+ * @description Put a search at the top of the recent ones. Songs are kept by path.
+ */
+const addRecentSearch = async (kind: SuperRecentKind, name: string) => {
+  return Events.Emit('front.search.recent.add', { kind, name });
+};
+
+const removeRecentSearch = async (kind: SuperRecentKind, name: string) => {
+  return Events.Emit('front.search.recent.remove', { kind, name });
+};
+
+const clearRecentSearches = async () => {
+  return Events.Emit('front.search.recent.clear', null);
+};
+
+export const superService = {
+  on,
+  getPlaylists,
+  openPlaylist,
+  pinPlaylist,
+  unpinPlaylist,
+  deletePlaylist,
+  renamePlaylist,
+  movePlaylistItem,
+  duplicatePlaylist,
+  addRecentSearch,
+  getRecentSearches,
+  removePlaylistItem,
+  removeRecentSearch,
+  clearRecentSearches,
+};
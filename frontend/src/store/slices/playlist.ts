@@ -1,15 +1,17 @@
 import { createAsyncThunk, createSlice, PayloadAction } from '@reduxjs/toolkit';
 
 // Services
-import { userService } from '../../services/users';
-import { playlistService } from '../../services/playlists';
+import { superService } from '../../services/super';
+
+// Utils
+import { toPlaylist, toPlaylistItems, toUser } from '../../utils/super';
 
 // Interfaces
-import type { RootState } from '../store';
+import type { AppDispatch, RootState } from '../store';
 import type { User } from '../../interfaces/user';
 import type { Track } from '../../interfaces/track';
-import type { Pagination } from '../../interfaces/api';
-import type { Playlist, PlaylistItem, PlaylistItemWithSaved } from '../../interfaces/playlists';
+import type { SuperFile, SuperPlaylist } from '../../interfaces/super';
+import type { Playlist, PlaylistItemWithSaved } from '../../interfaces/playlists';
 
 const initialState: {
   user: User | null;
@@ -17,9 +19,14 @@ const initialState: {
   tracks: PlaylistItemWithSaved[];
   playlist: Playlist | null;
 
+  // The playlist shown as the app sent it, and the id asked for until it comes.
+  id: string | null;
+  source: SuperPlaylist | null;
+
   loading: boolean;
   canEdit: boolean;
   following: boolean;
+  pinned: boolean;
 
   order: string;
   view: 'LIST' | 'COMPACT';
@@ -29,131 +36,87 @@ const initialState: {
   playlist: null,
   recommedations: [],
 
+  id: null,
+  source: null,
+
   loading: true,
   canEdit: false,
   following: false,
+  pinned: false,
 
   order: 'ALL',
   view: 'LIST',
 };
 
-export const fetchPlaylist = createAsyncThunk<
-  [Playlist, PlaylistItemWithSaved[], boolean, boolean, User, Track[]],
-  string
->('playlist/fetchPlaylist', async (id, { getState }) => {
-  const { auth } = getState() as RootState;
-  const { user } = auth;
-
-  const promises = [
-    playlistService.getPlaylist(id),
-    playlistService.getPlaylistItems(id),
-    user ? userService.checkFollowedPlaylist(id) : Promise.resolve({ data: [false] }),
-  ];
-
-  const responses = await Promise.all(promises);
-  const playlist = responses[0].data as Playlist;
-  const { items } = responses[1].data as Pagination<PlaylistItem>;
-  const [following] = responses[2].data as boolean[];
-
-  const ids = items.map((item) => item.track.id);
-  const artistsIds = items.map((item) => item.track.artists[0].id);
-
-  const isMine = user?.id === playlist.owner?.id;
-  const canEdit = isMine || playlist.collaborative;
-
-  const extraPromises = [
-    playlist.owner?.id ? userService.getUser(playlist.owner.id) : Promise.resolve({ data: null }),
-    ids.length && user
-      ? userService.checkSavedTracks(items.map((item) => item.track.id)).catch(() => ({ data: [] }))
-      : Promise.resolve({ data: [] }),
-    isMine
-      ? ids.length
-        ? playlistService
-            .getRecommendations({
-              seed_tracks: ids.slice(0, 5).join(',') || undefined,
-              seed_artists: artistsIds.slice(0, 5).join(',') || undefined,
-              limit: 25,
-            })
-            .then((res) => ({
-              data: res.data.tracks,
-            }))
-            .catch(() => ({
-              data: [],
-            }))
-        : userService.fetchTopTracks({ limit: 25, timeRange: 'short_term' }).then((res) => ({
-            data: res.data.items,
-          }))
-      : Promise.resolve({ data: [] }),
+/**
+ * @description Listen to the playlists and the list the app sends, returns the function that stops listening.
+ */
+export const listen = () => (dispatch: AppDispatch) => {
+  const offs = [
+    superService.on<SuperPlaylist[] | null>('playlists', (playlists) => {
+      dispatch(playlistSlice.actions.setPlaylists({ playlists: playlists || [] }));
+    }),
+    superService.on<SuperFile[] | null>('list', (files) => {
+      dispatch(playlistSlice.actions.setFiles({ files: files || [] }));
+    }),
+    superService.on<string[] | null>('playlists.pinned', (ids) => {
+      dispatch(playlistSlice.actions.setPinned({ ids: ids || [] }));
+    }),
   ];
 
-  const extraResponses = await Promise.all(extraPromises);
-
-  const owner = extraResponses[0].data as User;
-  const saved = extraResponses[1].data as boolean[];
-  const recommendations = extraResponses[2].data as Track[];
-
-  const itemsWithSave: PlaylistItemWithSaved[] = items.map((item, index) => ({
-    ...item,
-    saved: saved[index],
-  }));
-
-  return [playlist, itemsWithSave, following, canEdit, owner, recommendations];
-});
+  return () => offs.forEach((off) => off());
+};
 
-export const refreshTracks = createAsyncThunk<PlaylistItemWithSaved[], string>(
-  'playlist/refreshTracks',
+// The tracks are taken for the playlist's once it came, so it is asked for first.
+export const fetchPlaylist = createAsyncThunk<void, string>(
+  'playlist/fetchPlaylist',
   async (id) => {
-    try {
-      const { data } = await playlistService.getPlaylistItems(id);
-      const ids = data.items.map((item) => item.track.id);
-
-      const { data: saved } = await (ids.length
-        ? userService.checkSavedTracks(ids).catch(() => ({ data: [] }))
-        : Promise.resolve({ data: [] }));
-
-      const itemsWithSave: PlaylistItemWithSaved[] = data.items.map((item, index) => ({
-        ...item,
-        saved: saved[index],
-      }));
-
-      return itemsWithSave;
-    } catch (error) {
-      console.log(error);
-      return [];
-    }
+    await new Promise<void>((resolve) => {
+      const off = superService.on('playlists', () => {
+        off();
+        resolve();
+      });
+      superService.getPlaylists();
+    });
+    await superService.openPlaylist(id);
   }
 );
 
-export const getNextTracks = createAsyncThunk<PlaylistItemWithSaved[]>(
-  'playlist/getNextTracks',
-  async (_params, { getState }) => {
-    const { playlist, tracks } = (getState() as RootState).playlist;
-
-    const { data } = await playlistService.getPlaylistItems(playlist!.id, {
-      offset: tracks.length,
-      limit: 50,
-    });
-
-    const ids = data.items.map((item) => item.track.id);
+// The app sends the tracks again after every change of the playlist shown.
+export const refreshTracks = createAsyncThunk<void, string>('playlist/refreshTracks', async (id) => {
+  await superService.openPlaylist(id);
+});
 
-    const { data: saved } = await (ids.length
-      ? userService.checkSavedTracks(ids).catch(() => ({ data: [] }))
-      : Promise.resolve({ data: [] }));
+export const refreshPlaylist = createAsyncThunk<void, string>(
+  'playlist/refreshPlaylist',
+  async () => {
+    await superService.getPlaylists();
+  }
+);
 
-    const itemsWithSave: PlaylistItemWithSaved[] = data.items.map((item, index) => ({
-      ...item,
-      saved: saved[index],
-    }));
+export const moveTrack = createAsyncThunk<void, { from: number; to: number }>(
+  'playlist/moveTrack',
+  async ({ from, to }, { getState, dispatch }) => {
+    const { playlist } = (getState() as RootState).playlist;
+    dispatch(playlistSlice.actions.reorderTracks({ from, to }));
+    await superService.movePlaylistItem(playlist!.id, from, to);
+  }
+);
 
-    return itemsWithSave;
+export const removeTrackAt = createAsyncThunk<void, number>(
+  'playlist/removeTrackAt',
+  async (index, { getState }) => {
+    const { playlist } = (getState() as RootState).playlist;
+    await superService.removePlaylistItem(playlist!.id, index);
   }
 );
 
-export const refreshPlaylist = createAsyncThunk<Playlist, string>(
-  'playlist/refreshPlaylist',
-  async (id) => {
-    const { data } = await playlistService.getPlaylist(id);
-    return data;
+export const pinPlaylist = createAsyncThunk<void, boolean>(
+  'playlist/pinPlaylist',
+  async (pinned, { getState }) => {
+    const { playlist } = (getState() as RootState).playlist;
+    if (pinned) await superService.pinPlaylist(playlist!.id);
+    else await superService.unpinPlaylist(playlist!.id);
   }
 );
 
@@ -164,14 +127,46 @@ const playlistSlice = createSlice({
     setPlaylist(state, action: PayloadAction<{ playlist: Playlist | null }>) {
       state.playlist = action.payload.playlist;
       if (!action.payload.playlist) {
+        state.id = null;
+        state.source = null;
         state.tracks = [];
         state.following = false;
         state.canEdit = false;
+        state.pinned = false;
         state.user = null;
         state.loading = true;
         state.view = 'LIST';
       }
     },
+    setPlaylists(state, action: PayloadAction<{ playlists: SuperPlaylist[] }>) {
+      if (!state.id) return;
+      const playlist = action.payload.playlists.find((p) => p.id === state.id);
+      if (!playlist) {
+        // Deleted, here or on the server.
+        state.playlist = null;
+        state.source = null;
+        state.tracks = [];
+        state.loading = false;
+        return;
+      }
+      state.source = playlist;
+      state.playlist = toPlaylist(playlist);
+      state.user = playlist.owner ? toUser(playlist.owner) : null;
+      // Viewers can't change a shared playlist, never synced ones have no role.
+      state.canEdit = playlist.role !== 1;
+    },
+    setFiles(state, action: PayloadAction<{ files: SuperFile[] }>) {
+      // The list is sent for searches as well, it is the playlist's when it has its tracks.
+      const paths = state.source?.tracks || [];
+      const { files } = action.payload;
+      if (!state.source || files.length !== paths.length) return;
+      if (files.some((file, index) => file.path !== paths[index])) return;
+      state.tracks = toPlaylistItems(state.source, files);
+      state.loading = false;
+    },
+    setPinned(state, action: PayloadAction<{ ids: string[] }>) {
+      state.pinned = !!state.id && action.payload.ids.includes(state.id);
+    },
     removeTrack(state, action: PayloadAction<{ id: string }>) {
       state.tracks = state.tracks.filter((track) => track.track.id !== action.payload.id);
     },
@@ -200,34 +195,21 @@ const playlistSlice = createSlice({
     },
   },
   extraReducers: (builder) => {
-    builder.addCase(fetchPlaylist.pending, (state) => {
+    builder.addCase(fetchPlaylist.pending, (state, action) => {
+      state.id = action.meta.arg;
+      state.source = null;
       state.loading = true;
     });
-    builder.addCase(fetchPlaylist.fulfilled, (state, action) => {
-      state.playlist = action.payload[0];
-      state.tracks = action.payload[1];
-      state.following = action.payload[2];
-      state.canEdit = action.payload[3];
-      state.user = action.payload[4];
-      state.recommedations = action.payload[5];
-      state.loading = false;
-    });
-    builder.addCase(refreshTracks.fulfilled, (state, action) => {
-      state.tracks = action.payload;
-    });
-    builder.addCase(refreshPlaylist.fulfilled, (state, action: PayloadAction<Playlist>) => {
-      state.playlist = action.payload;
-    });
-    builder.addCase(getNextTracks.fulfilled, (state, action) => {
-      state.tracks = [...state.tracks, ...action.payload];
-    });
   },
 });
 
 export const playlistActions = {
+  listen,
+  moveTrack,
+  pinPlaylist,
   fetchPlaylist,
   refreshTracks,
-  getNextTracks,
+  removeTrackAt,
   refreshPlaylist,
   ...playlistSlice.actions,
 };
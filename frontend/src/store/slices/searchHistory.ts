@@ -1,36 +1,89 @@
-import { createSlice, PayloadAction } from '@reduxjs/toolkit';
+import { createAsyncThunk, createSlice, PayloadAction } from '@reduxjs/toolkit';
+
+// Services
+import { superService } from '../../services/super';
+
+// Utils
+import { toRecentItem } from '../../utils/super';
 
 // Interfaces
+import type { AppDispatch, RootState } from '../store';
 import type { Album } from '../../interfaces/albums';
 import type { Artist } from '../../interfaces/artist';
 import type { Playlist } from '../../interfaces/playlists';
 import type { Track } from '../../interfaces/track';
+import type { SuperRecent, SuperRecentKind } from '../../interfaces/super';
 
 type Item = Playlist | Album | Track | Artist;
 
 const initialState: {
   items: Item[];
+  // What the app knows each item by, by uri.
+  searches: Record<string, { kind: SuperRecentKind; name: string }>;
+  loaded: boolean;
 } = {
   items: [],
+  searches: {},
+  loaded: false,
+};
+
+/**
+ * @description Listen to the recent searches the app sends, returns the function that stops listening.
+ */
+export const listen = () => (dispatch: AppDispatch) => {
+  return superService.on<SuperRecent[] | null>('search.recent', (recent) => {
+    dispatch(searchHistoryActionsSlice.actions.setRecent({ recent: recent || [] }));
+  });
 };
 
+export const fetchItems = createAsyncThunk('searchHistory/fetchItems', async () => {
+  await superService.getRecentSearches();
+});
+
+// Songs are kept by path, the id of the tracks of the app. Playlists aren't kept.
+export const setItem = createAsyncThunk<void, Item>('searchHistory/setItem', async (item) => {
+  if (item.type === 'playlist') return;
+  const kind = item.type === 'track' ? 'song' : item.type;
+  await superService.addRecentSearch(kind, item.type === 'track' ? item.id : item.name);
+});
+
+export const removeItem = createAsyncThunk<void, Item>(
+  'searchHistory/removeItem',
+  async (item, { getState }) => {
+    const search = (getState() as RootState).searchHistory.searches[item.uri];
+    if (!search) return;
+    await superService.removeRecentSearch(search.kind, search.name);
+  }
+);
+
+export const clearItems = createAsyncThunk('searchHistory/clearItems', async () => {
+  await superService.clearRecentSearches();
+});
+
 const searchHistoryActionsSlice = createSlice({
   name: 'searchHistory',
   initialState,
   reducers: {
-    setItem(state, action: PayloadAction<Item>) {
-      state.items.unshift(action.payload);
-    },
-    clearItems(state) {
+    setRecent(state, action: PayloadAction<{ recent: SuperRecent[] }>) {
       state.items = [];
-    },
-    removeItem(state, action: PayloadAction<Item>) {
-      state.items = state.items.filter((item) => item.id !== action.payload.id);
+      state.searches = {};
+      action.payload.recent.forEach((recent) => {
+        const item = toRecentItem(recent);
+        if (!item) return;
+        state.items.push(item);
+        state.searches[item.uri] = { kind: recent.Kind, name: recent.Name };
+      });
+      state.loaded = true;
     },
   },
 });
 
 export const searchHistoryActions = {
+  listen,
+  setItem,
+  fetchItems,
+  removeItem,
+  clearItems,
   ...searchHistoryActionsSlice.actions,
 };
 
@@ -32,7 +32,6 @@ import yourLibraryReducer from './slices/yourLibrary';
 import searchHistoryReducer from './slices/searchHistory';
 import artistDiscographyReducer from './slices/discography';
 import editPlaylistModalReducer from './slices/editPlaylistModal';
-import expireReducer from 'redux-persist-expire';
 
 const appReducer = combineReducers({
   ui: uiReducer,
@@ -64,14 +63,14 @@ const rootReducer = (state, action) => {
   return appReducer(state, action);
 };
 
-const whitelist = ['language', 'ui', 'searchHistory'] as string[];
+// The recent searches are kept by the app.
+const whitelist = ['language', 'ui'] as string[];
 
 const persistedReducer = persistReducer(
   {
     storage,
     whitelist,
     key: 'root',
-    transforms: [expireReducer('searchHistory', { expireSeconds: 60 * 60 * 24 })],
   },
   rootReducer
 );
@@ -0,0 +1,128 @@
+import type { User } from '../interfaces/user';
+import type { Track } from '../interfaces/track';
+import type { Album } from '../interfaces/albums';
+import type { Artist, SimpleArtist } from '../interfaces/artist';
+import type { SuperFile, SuperPlaylist, SuperRecent } from '../interfaces/super';
+import type { Playlist, PlaylistItemWithSaved } from '../interfaces/playlists';
+
+// Constants
+import { PLAYLIST_DEFAULT_IMAGE } from '../constants/spotify';
+
+const COVER = { url: PLAYLIST_DEFAULT_IMAGE, width: 300, height: 300 };
+
+/**
+ * @description Tracks no longer in the library come with their path only, they are shown by their file name.
+ */
+const fileName = (path: string) => path.split(/[\\/]/).pop() || path;
+
+export const toUser = (owner: string): User => ({ id: owner, display_name: owner, type: 'user' });
+
+export const toPlaylist = (playlist: SuperPlaylist): Playlist => ({
+  id: playlist.id,
+  name: playlist.name || '',
+  type: 'playlist',
+  uri: `super:playlist:${playlist.id}`,
+  href: '',
+  description: null,
+  collaborative: false,
+  public: null,
+  snapshot_id: String(playlist.modified || 0),
+  external_urls: { spotify: '' },
+  followers: { href: '', total: 0 },
+  images: [],
+  owner: playlist.owner ? toUser(playlist.owner) : null,
+  tracks: { href: '', total: playlist.tracks?.length || 0 },
+});
+
+export const toArtist = (name: string): SimpleArtist => ({
+  id: name,
+  name,
+  type: 'artist',
+  uri: `super:artist:${name}`,
+  href: '',
+  external_urls: { spotify: '' },
+});
+
+export const toAlbum = (name: string, artist: string, year?: number): Album => ({
+  id: name,
+  name,
+  type: 'album',
+  album_type: 'album',
+  uri: `super:album:${name}`,
+  href: '',
+  artists: [toArtist(artist)],
+  images: [COVER],
+  available_markets: [],
+  external_urls: { spotify: '' },
+  release_date: year ? String(year) : '',
+  release_date_precision: 'year',
+  total_tracks: 0,
+});
+
+export const toTrack = (file: SuperFile): Track => {
+  const artist = toArtist(file.artist || '');
+  const album = toAlbum(file.album || '', file.artist || '', file.year);
+
+  return {
+    id: file.path,
+    name: file.track || fileName(file.path),
+    type: 'track',
+    uri: `super:track:${file.path}`,
+    href: '',
+    album,
+    artists: [artist],
+    duration_ms: Math.round((file.durationSeconds || 0) * 1000),
+    track_number: file.trackNumber || 0,
+    disc_number: 1,
+    explicit: false,
+    is_local: false,
+    is_playable: true,
+    popularity: 0,
+    preview_url: '',
+    available_markets: [],
+    external_ids: { isrc: '' },
+    external_urls: { spotify: '' },
+  };
+};
+
+/**
+ * @description Playlists have no dates for their tracks, they are shown as added when the playlist was created.
+ */
+export const toPlaylistItems = (
+  playlist: SuperPlaylist,
+  files: SuperFile[]
+): PlaylistItemWithSaved[] => {
+  const addedAt = new Date((playlist.created || 0) * 1000).toISOString();
+  const addedBy = toUser(playlist.owner || '');
+
+  return files.map((file) => ({
+    added_at: addedAt,
+    added_by: addedBy,
+    is_local: false,
+    primary_color: '',
+    saved: false,
+    track: toTrack(file),
+  }));
+};
+
+/**
+ * @description Searches picked as results are shown as such, typed ones aren't.
+ */
+export const toRecentItem = (recent: SuperRecent): Track | Album | Artist | null => {
+  switch (recent.Kind) {
+    case 'song':
+      return toTrack(recent.File || { path: recent.Name });
+    case 'album':
+      return toAlbum(recent.Name, '');
+    case 'artist':
+      return {
+        ...toArtist(recent.Name),
+        followers: { href: '', total: 0 },
+        genres: [],
+        images: [],
+        popularity: 0,
+      };
+    default:
+      return null;
+  }
+};
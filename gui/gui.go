@@ -2,23 +2,26 @@ package gui
 
 import (
 	"context"
-	"io"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/bh90210/super/cache"
 	"github.com/bh90210/super/player"
+	"github.com/bh90210/super/playlist"
+	"github.com/bh90210/super/queue"
 	"github.com/bh90210/super/search"
 	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/store"
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
 	"github.com/wailsapp/wails/v3/pkg/application"
-)
-
-type PlayerState int
-
-const (
-	STOPPED PlayerState = iota
-	PLAYING
-	PAUSED
+	"google.golang.org/grpc"
 )
 
 type State struct {
@@ -30,17 +33,25 @@ type State struct {
 
 	App    *application.App
 	Player *player.Player
+	// Queue is what plays next, apart from the list shown.
+	Queue  *queue.Queue
 	Search *search.Search
-
-	// Stop the ticker.
-	tickerStop chan struct{}
-	// Reset the ticker.
-	tickerReset chan struct{}
-	// Kill the ticker.
-	tickerKill chan struct{}
-	reposition chan float64
-	playing    PlayerState
-	mu         sync.Mutex
+	Cache  *cache.Cache
+	// Playlists are the playlists made in the app.
+	Playlists *playlist.Playlists
+	// Downloader fetches pinned files for offline use.
+	Downloader *cache.Downloader
+
+	db *badger.DB
+	// conn is the connection to the server.
+	conn *grpc.ClientConn
+
+	// retries is how often the current track was tried again and skipped
+	// how many tracks in a row couldn't be played.
+	retries int
+	skipped int
+
+	mu sync.Mutex
 }
 
 type Menu struct {
@@ -73,11 +84,19 @@ type Controls struct {
 
 type Dupload struct{}
 
+// Toast is a message the frontend shows for a while. Retry and Skip ask
+// for buttons that send front.retry and front.skip.
+type Toast struct {
+	Message string
+	Retry   bool
+	Skip    bool
+}
+
 type Active struct {
 	Name string
 	List map[int]*api.File
-
-	index int
+	// Playlist is the id of the playlist shown, if one is.
+	Playlist string
 }
 
 type Button struct {
@@ -122,26 +141,78 @@ type ProgressBar struct {
 
 func (s *State) Init(app *application.App) (err error) {
 	s.Active.List = make(map[int]*api.File)
-	s.tickerReset = make(chan struct{})
-	s.tickerStop = make(chan struct{})
-	s.tickerKill = make(chan struct{})
-	s.reposition = make(chan float64)
 
 	s.App = app
-	s.playing = STOPPED
+
+	// Try to create local data storage directory, if not already created.
+	err = os.MkdirAll(super.LocalStorage(super.DataStore), 0755)
+	if err != nil {
+		s.App.Logger.Error("creating local storage", "error", err)
+		return err
+	}
+
+	// Then open badger (local data storage), it is shared by all components.
+	s.db, err = badger.Open(badger.DefaultOptions(super.LocalStorage(super.DataStore)))
+	if err != nil {
+		s.App.Logger.Error("badger.Open", "error", err)
+		return err
+	}
+
+	// Data written by an older version is upgraded before anything reads it.
+	err = store.Migrate(s.db)
+	if err != nil {
+		s.App.Logger.Error("store.Migrate", "error", err)
+		return err
+	}
+
+	s.Cache, err = cache.New(s.db)
+	if err != nil {
+		s.App.Logger.Error("cache.New", "error", err)
+		return err
+	}
+
+	// One connection to the server, shared by everything talking to it.
+	s.conn, err = super.Dial()
+	if err != nil {
+		s.App.Logger.Error("super.Dial", "error", err)
+		return err
+	}
+
+	s.Downloader, err = cache.NewDownloader(s.Cache, s.conn, cache.DefaultConcurrency)
+	if err != nil {
+		s.App.Logger.Error("cache.NewDownloader", "error", err)
+		return err
+	}
 
 	s.Player = &player.Player{}
-	if err := s.Player.Init(s.App.Logger); err != nil {
+	if err := s.Player.Init(s.App.Logger, s.Cache, s.db, s.conn); err != nil {
 		s.App.Logger.Error("player.Init", "error", err)
 		return err
 	}
 
-	s.Search, err = search.NewSearch()
+	s.Search, err = search.NewSearch(s.db, s.conn)
 	if err != nil {
 		s.App.Logger.Error("search.NewSearch", "error", err)
 		return err
 	}
 
+	// The queue stores tracks by path, they are looked up in the library.
+	s.Queue, err = queue.New(s.db, s.Search.Files)
+	if err != nil {
+		s.App.Logger.Error("queue.New", "error", err)
+		return err
+	}
+
+	s.Playlists, err = playlist.New(s.db, s.conn)
+	if err != nil {
+		s.App.Logger.Error("playlist.New", "error", err)
+		return err
+	}
+
+	// Playlists are searched by name along with the library.
+	s.Search.SetPlaylists(s.Playlists.List)
+	s.Search.SetCached(s.Cache.Cached)
+
 	return
 }
 
@@ -157,29 +228,83 @@ func (s *State) ServiceStartup(ctx context.Context, options application.ServiceO
 			s.App.Event.Emit("play.pause.deactivate", true)
 			s.App.Event.Emit("volume.set", "70")
 			s.Controls.Volume.Value = .7
+			s.Player.SetVolume(s.Controls.Volume.Value)
 			s.App.Event.Emit("status.left", "--")
 			s.App.Event.Emit("status.center", "--")
 			s.App.Event.Emit("status.right", "--")
+			s.App.Event.Emit("sources", s.Search.Sources())
+			s.App.Event.Emit("connection", s.Search.Connection().String())
+			s.App.Event.Emit("cache.stats", s.Cache.Stats())
+			s.App.Event.Emit("replaygain", s.Player.Gain())
+			s.App.Event.Emit("crossfade", s.Player.Crossfade().Seconds())
+			s.App.Event.Emit("dsp.presets", player.Presets)
+			s.App.Event.Emit("dsp", s.Player.DSP())
+			s.App.Event.Emit("playlists.user", s.Playlists.User())
+			s.devices()
 			s.List()
+			s.queued()
 			s.App.Event.Off("ready")
 		})
 	}()
 
+	// Let the frontend know when the client goes on or offline.
+	go func() {
+		for connection := range s.Search.Connections() {
+			s.App.Event.Emit("connection", connection.String())
+			if connection == search.Online {
+				s.List()
+			}
+		}
+	}()
+
+	// Keep the active downloads counter up to date.
+	go func() {
+		for progress := range s.Downloader.Progress() {
+			s.mu.Lock()
+			s.Menu.ActiveDownloads = progress.Active + progress.Queued
+			s.mu.Unlock()
+
+			s.App.Event.Emit("downloads.active", progress.Active+progress.Queued)
+			s.App.Event.Emit("downloads.progress", progress)
+
+			if progress.Active+progress.Queued == 0 {
+				s.App.Event.Emit("cache.stats", s.Cache.Stats())
+			}
+		}
+	}()
+
+	// Keep the playlists up to date with the ones synced from the server.
+	go func() {
+		for range s.Playlists.Changed() {
+			s.playlists(nil)
+		}
+	}()
+
+	// Follow the player when it moves on to the queued track.
+	go func() {
+		for file := range s.Player.Transitions() {
+			s.transition(file)
+		}
+	}()
+
+	// Keep the controls in line with the player.
+	go func() {
+		for event := range s.Player.Subscribe() {
+			s.playback(event)
+		}
+	}()
+
 	// Listeners.
 	s.App.Event.On("front.volume.mute", func(event *application.CustomEvent) {
 		s.App.Event.Emit("volume.set", "0")
 		s.Controls.Volume.Value = 0.
-		if s.Player.Oto != nil {
-			s.Player.Oto.SetVolume(0.)
-		}
+		s.Player.SetVolume(0.)
 	})
 
 	s.App.Event.On("front.volume.max", func(event *application.CustomEvent) {
 		s.App.Event.Emit("volume.set", "100")
 		s.Controls.Volume.Value = 1.
-		if s.Player.Oto != nil {
-			s.Player.Oto.SetVolume(1.)
-		}
+		s.Player.SetVolume(1.)
 	})
 
 	s.App.Event.On("front.volume.set", func(event *application.CustomEvent) {
@@ -191,42 +316,282 @@ func (s *State) ServiceStartup(ctx context.Context, options application.ServiceO
 
 		vol := scale(float64(i), 0., 1., 0, 100)
 		s.Controls.Volume.Value = vol
-		if s.Player.Oto != nil {
-			s.Player.Oto.SetVolume(vol)
+		s.Player.SetVolume(vol)
+	})
+
+	// The mode is one of off, track or album, the gains are in dB.
+	s.App.Event.On("front.replaygain", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+
+		gain := s.Player.Gain()
+		if mode, ok := data["mode"].(string); ok {
+			gain.Mode = player.ParseGainMode(mode)
+		}
+
+		if preamp, ok := data["preamp"].(float64); ok {
+			gain.Preamp = preamp
+		}
+
+		if fallback, ok := data["fallback"].(float64); ok {
+			gain.Fallback = fallback
+		}
+
+		err := s.Player.SetGain(gain)
+		if err != nil {
+			s.App.Logger.Error("s.Player.SetGain", "error", err)
+			return
+		}
+
+		s.App.Event.Emit("replaygain", gain)
+	})
+
+	// The crossfade is in seconds, 0 turns it off.
+	s.App.Event.On("front.crossfade", func(event *application.CustomEvent) {
+		seconds, ok := event.Data.(float64)
+		if !ok {
+			return
+		}
+
+		err := s.Player.SetCrossfade(time.Duration(seconds * float64(time.Second)))
+		if err != nil {
+			s.App.Logger.Error("s.Player.SetCrossfade", "error", err)
+			return
+		}
+
+		s.App.Event.Emit("crossfade", s.Player.Crossfade().Seconds())
+	})
+
+	s.App.Event.On("front.devices", func(event *application.CustomEvent) {
+		s.devices()
+	})
+
+	// The output device can change while playing.
+	s.App.Event.On("front.device", func(event *application.CustomEvent) {
+		name, ok := event.Data.(string)
+		if !ok {
+			return
 		}
+
+		err := s.Player.SetDevice(name)
+		if errors.Is(err, player.ErrRestart) {
+			s.App.Event.Emit("toast", Toast{Message: "The output device changes when super is started again"})
+		} else if err != nil {
+			s.App.Logger.Error("s.Player.SetDevice", "error", err)
+		}
+
+		s.App.Event.Emit("device", s.Player.Device())
+		s.App.Event.Emit("dsp", s.Player.DSP())
+	})
+
+	// A preset replaces the bands, the rest of the settings stay.
+	s.App.Event.On("front.dsp.preset", func(event *application.CustomEvent) {
+		preset, ok := event.Data.(string)
+		if !ok {
+			return
+		}
+
+		current := s.Player.DSP()
+		dsp := player.NewDSP(preset)
+		dsp.Preamp, dsp.Mono, dsp.Balance = current.Preamp, current.Mono, current.Balance
+
+		s.setDSP(dsp)
+	})
+
+	s.App.Event.On("front.dsp", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+
+		dsp := s.Player.DSP()
+		if preamp, ok := data["preamp"].(float64); ok {
+			dsp.Preamp = preamp
+		}
+
+		if mono, ok := data["mono"].(bool); ok {
+			dsp.Mono = mono
+		}
+
+		if balance, ok := data["balance"].(float64); ok {
+			dsp.Balance = balance
+		}
+
+		// Gains of the bands in order, changing them by hand leaves the
+		// preset.
+		if gains, ok := data["gains"].([]any); ok {
+			dsp.Bands = slices.Clone(dsp.Bands)
+			for i, gain := range gains {
+				gain, ok := gain.(float64)
+				if !ok || i >= len(dsp.Bands) {
+					continue
+				}
+
+				dsp.Bands[i].Gain = gain
+				dsp.Preset = ""
+			}
+		}
+
+		s.setDSP(dsp)
 	})
 
 	s.App.Event.On("front.list.play", func(event *application.CustomEvent) {
 		s.App.Logger.Debug("front.list.play", "event", event.Data)
-		s.play(int(event.Data.(float64)))
+
+		s.mu.Lock()
+		name := s.Active.Name
+		s.mu.Unlock()
+
+		// The list shown becomes the queue.
+		file, err := s.Queue.Play(s.listed(), int(event.Data.(float64))-1)
+		if err != nil {
+			s.App.Logger.Error("s.Queue.Play", "error", err)
+			return
+		}
+
+		s.App.Event.Emit("status.left", name)
+		s.play(file)
+
+		// Playing from a search remembers what was searched and played.
+		if query, ok := strings.CutPrefix(name, "Search: "); ok {
+			s.recent(s.Search.AddRecent(search.Query, query))
+			s.recent(s.Search.AddRecent(search.Song, file.Path))
+		}
 	})
 
 	s.App.Event.On("front.play.pause", func(event *application.CustomEvent) {
-		if s.Player.Oto != nil {
-			if s.Player.Oto.IsPlaying() {
-				s.Player.Oto.Pause()
-				s.App.Event.Emit("play.pause", "Play")
-				s.tickerStop <- struct{}{}
-				s.playing = PAUSED
-			} else {
-				s.Player.Oto.Play()
-				s.App.Event.Emit("play.pause", "Pause")
-				s.tickerReset <- struct{}{}
-				s.playing = PLAYING
+		switch s.Player.State() {
+		case player.Playing:
+			s.Player.Pause()
+			return
+
+		case player.Idle, player.Error:
+			// Pick up the queue from where it was left.
+			file, ok := s.Queue.Current()
+			if ok {
+				s.play(file)
 			}
+			return
+		}
+
+		err := s.Player.Play()
+		if err != nil {
+			s.App.Logger.Error("s.Player.Play", "error", err)
 		}
 	})
 
+	s.App.Event.On("front.stop", func(event *application.CustomEvent) {
+		s.Player.Stop()
+	})
+
 	s.App.Event.On("front.next", func(event *application.CustomEvent) {
-		s.play(s.Active.index + 2)
+		file, ok := s.Queue.Next()
+		if !ok {
+			s.Player.Stop()
+			s.queued()
+			return
+		}
+
+		s.play(file)
+	})
+
+	s.App.Event.On("front.retry", func(event *application.CustomEvent) {
+		file, ok := s.Queue.Current()
+		if ok {
+			s.play(file)
+		}
+	})
+
+	s.App.Event.On("front.skip", func(event *application.CustomEvent) {
+		s.skip()
 	})
 
 	s.App.Event.On("front.previous", func(event *application.CustomEvent) {
-		s.play(s.Active.index)
+		file, ok := s.Queue.Previous()
+		if ok {
+			s.play(file)
+		}
+	})
+
+	s.App.Event.On("front.queue", func(event *application.CustomEvent) {
+		s.App.Event.Emit("queue", s.Queue.Snapshot())
+	})
+
+	// Tracks are picked by their index in the list shown.
+	s.App.Event.On("front.queue.next", func(event *application.CustomEvent) {
+		file, ok := s.listedAt(int(event.Data.(float64)))
+		if !ok {
+			return
+		}
+
+		err := s.Queue.PlayNext(file)
+		if err != nil {
+			s.App.Logger.Error("s.Queue.PlayNext", "error", err)
+		}
+
+		s.queued()
+	})
+
+	s.App.Event.On("front.queue.add", func(event *application.CustomEvent) {
+		file, ok := s.listedAt(int(event.Data.(float64)))
+		if !ok {
+			return
+		}
+
+		err := s.Queue.Add(file)
+		if err != nil {
+			s.App.Logger.Error("s.Queue.Add", "error", err)
+		}
+
+		s.queued()
+	})
+
+	s.App.Event.On("front.queue.move", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+		err := s.Queue.Move(int(data["from"].(float64)), int(data["to"].(float64)))
+		if err != nil {
+			s.App.Logger.Error("s.Queue.Move", "error", err)
+		}
+
+		s.queued()
+	})
+
+	s.App.Event.On("front.queue.remove", func(event *application.CustomEvent) {
+		err := s.Queue.Remove(int(event.Data.(float64)))
+		if err != nil {
+			s.App.Logger.Error("s.Queue.Remove", "error", err)
+		}
+
+		s.queued()
+	})
+
+	s.App.Event.On("front.queue.clear", func(event *application.CustomEvent) {
+		err := s.Queue.Clear()
+		if err != nil {
+			s.App.Logger.Error("s.Queue.Clear", "error", err)
+		}
+
+		s.queued()
+	})
+
+	s.App.Event.On("front.queue.shuffle", func(event *application.CustomEvent) {
+		err := s.Queue.Shuffle(event.Data.(bool))
+		if err != nil {
+			s.App.Logger.Error("s.Queue.Shuffle", "error", err)
+		}
+
+		s.queued()
+	})
+
+	// One of off, one or all.
+	s.App.Event.On("front.queue.repeat", func(event *application.CustomEvent) {
+		err := s.Queue.SetRepeat(queue.ParseRepeat(event.Data.(string)))
+		if err != nil {
+			s.App.Logger.Error("s.Queue.SetRepeat", "error", err)
+		}
+
+		s.queued()
 	})
 
 	s.App.Event.On("front.progress", func(event *application.CustomEvent) {
-		s.reposition <- event.Data.(float64)
+		// Seeking a track that is still downloading waits for it.
+		go s.seek(event.Data.(float64))
 	})
 
 	s.App.Event.On("front.search.query", func(event *application.CustomEvent) {
@@ -247,6 +612,7 @@ func (s *State) ServiceStartup(ctx context.Context, options application.ServiceO
 			s.Active.List[k] = &v
 		}
 		s.Active.Name = "Search: " + event.Data.(string)
+		s.Active.Playlist = ""
 		s.mu.Unlock()
 
 		s.App.Event.Emit("list", list)
@@ -254,6 +620,238 @@ func (s *State) ServiceStartup(ctx context.Context, options application.ServiceO
 		s.App.Logger.Debug("front.search.query", "event", event.Data.(string))
 	})
 
+	s.App.Event.On("front.sources", func(event *application.CustomEvent) {
+		s.App.Event.Emit("sources", s.Search.Sources())
+	})
+
+	s.App.Event.On("front.sources.add", func(event *application.CustomEvent) {
+		err := s.Search.AddSource(event.Data.(string))
+		if err != nil {
+			s.App.Logger.Error("s.Search.AddSource", "error", err)
+			return
+		}
+
+		s.App.Event.Emit("sources", s.Search.Sources())
+	})
+
+	s.App.Event.On("front.sources.remove", func(event *application.CustomEvent) {
+		err := s.Search.RemoveSource(event.Data.(string))
+		if err != nil {
+			s.App.Logger.Error("s.Search.RemoveSource", "error", err)
+			return
+		}
+
+		s.App.Event.Emit("sources", s.Search.Sources())
+		s.List()
+	})
+
+	s.App.Event.On("front.cache.stats", func(event *application.CustomEvent) {
+		s.App.Event.Emit("cache.stats", s.Cache.Stats())
+	})
+
+	// The limit is set in megabytes.
+	s.App.Event.On("front.cache.limit", func(event *application.CustomEvent) {
+		err := s.Cache.SetLimit(int64(event.Data.(float64)) << 20)
+		if err != nil {
+			s.App.Logger.Error("s.Cache.SetLimit", "error", err)
+			return
+		}
+
+		s.App.Event.Emit("cache.stats", s.Cache.Stats())
+	})
+
+	s.App.Event.On("front.pin.artist", func(event *application.CustomEvent) {
+		name := event.Data.(string)
+		s.pin(artistPin(name), s.artist(name), true)
+	})
+
+	s.App.Event.On("front.unpin.artist", func(event *application.CustomEvent) {
+		s.unpin(artistPin(event.Data.(string)))
+	})
+
+	s.App.Event.On("front.pin.album", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+		artist, album := data["artist"].(string), data["album"].(string)
+		s.pin(albumPin(artist, album), s.album(artist, album), true)
+	})
+
+	s.App.Event.On("front.unpin.album", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+		s.unpin(albumPin(data["artist"].(string), data["album"].(string)))
+	})
+
+	// The search page asks for every section at once, or for the next page
+	// of one of them. Sections left out get their first page.
+	s.App.Event.On("front.search.grouped", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+
+		query, _ := data["query"].(string)
+
+		var pages search.Pages
+		for name, page := range map[string]*search.Page{
+			"artists":   &pages.Artists,
+			"albums":    &pages.Albums,
+			"songs":     &pages.Songs,
+			"playlists": &pages.Playlists,
+		} {
+			section, ok := data[name].(map[string]any)
+			if !ok {
+				continue
+			}
+
+			if offset, ok := section["offset"].(float64); ok {
+				page.Offset = int(offset)
+			}
+
+			if size, ok := section["size"].(float64); ok {
+				page.Size = int(size)
+			}
+		}
+
+		results, err := s.Search.Grouped(query, pages)
+		if err != nil {
+			s.App.Logger.Error("s.Search.Grouped", "error", err)
+			return
+		}
+
+		s.App.Event.Emit("search.results", results)
+	})
+
+	s.App.Event.On("front.search.suggest", func(event *application.CustomEvent) {
+		suggestions, err := s.Search.Suggest(event.Data.(string), 0)
+		if err != nil {
+			s.App.Logger.Error("s.Search.Suggest", "error", err)
+			return
+		}
+
+		s.App.Event.Emit("search.suggestions", suggestions)
+	})
+
+	s.App.Event.On("front.search.recent", func(event *application.CustomEvent) {
+		s.App.Event.Emit("search.recent", s.Search.Recent())
+	})
+
+	// Searches are added by the frontend as results are clicked, with the
+	// kind of result and its name, or path for songs.
+	s.App.Event.On("front.search.recent.add", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+		s.recent(s.Search.AddRecent(search.Kind(data["kind"].(string)), data["name"].(string)))
+	})
+
+	s.App.Event.On("front.search.recent.remove", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+		s.recent(s.Search.RemoveRecent(search.Kind(data["kind"].(string)), data["name"].(string)))
+	})
+
+	s.App.Event.On("front.search.recent.clear", func(event *application.CustomEvent) {
+		s.recent(s.Search.ClearRecent())
+	})
+
+	s.App.Event.On("front.playlists", func(event *application.CustomEvent) {
+		s.App.Event.Emit("playlists", s.Playlists.List())
+		s.App.Event.Emit("playlists.pinned", s.Playlists.Pinned())
+	})
+
+	s.App.Event.On("front.playlist.open", func(event *application.CustomEvent) {
+		s.playlist(event.Data.(string))
+	})
+
+	s.App.Event.On("front.playlist.create", func(event *application.CustomEvent) {
+		_, err := s.Playlists.Create(event.Data.(string))
+		s.playlists(err)
+	})
+
+	s.App.Event.On("front.playlist.rename", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+		s.playlists(s.Playlists.Rename(data["id"].(string), data["name"].(string)))
+	})
+
+	s.App.Event.On("front.playlist.duplicate", func(event *application.CustomEvent) {
+		_, err := s.Playlists.Duplicate(event.Data.(string))
+		s.playlists(err)
+	})
+
+	s.App.Event.On("front.playlist.delete", func(event *application.CustomEvent) {
+		s.playlists(s.Playlists.Delete(event.Data.(string)))
+	})
+
+	// Tracks are added by their index in the list shown, like to the queue.
+	s.App.Event.On("front.playlist.add", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+		file, ok := s.listedAt(int(data["index"].(float64)))
+		if !ok {
+			return
+		}
+
+		s.playlists(s.Playlists.Add(data["id"].(string), file.Path))
+	})
+
+	// Tracks are removed and moved by their position in the playlist.
+	s.App.Event.On("front.playlist.remove", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+		s.playlists(s.Playlists.Remove(data["id"].(string), int(data["index"].(float64))))
+	})
+
+	s.App.Event.On("front.playlist.move", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+		s.playlists(s.Playlists.Move(data["id"].(string), int(data["from"].(float64)), int(data["to"].(float64))))
+	})
+
+	// Playlists are synced with the server for the user of the token set,
+	// none if empty. Tokens are issued by the server.
+	s.App.Event.On("front.playlists.token", func(event *application.CustomEvent) {
+		err := s.Playlists.SetToken(strings.TrimSpace(event.Data.(string)))
+		if err != nil {
+			s.App.Logger.Error("s.Playlists.SetToken", "error", err)
+		}
+
+		s.App.Event.Emit("playlists.user", s.Playlists.User())
+		s.playlists(err)
+	})
+
+	// Roles are "viewer", "editor" or "none" to stop sharing.
+	s.App.Event.On("front.playlist.share", func(event *application.CustomEvent) {
+		data := event.Data.(map[string]any)
+		role, ok := api.Role_value["ROLE_"+strings.ToUpper(data["role"].(string))]
+		if !ok || role == int32(api.Role_ROLE_OWNER) {
+			s.App.Logger.Error("front.playlist.share", "role", data["role"])
+			return
+		}
+
+		err := s.Playlists.Share(data["id"].(string), data["user"].(string), api.Role(role))
+		if err != nil {
+			s.App.Logger.Error("s.Playlists.Share", "error", err)
+		}
+	})
+
+	// Pin the list currently shown. A playlist is pinned as such, so the
+	// tracks added to it later are pinned as well.
+	s.App.Event.On("front.pin.list", func(event *application.CustomEvent) {
+		if id := s.activePlaylist(); id != "" {
+			s.pinPlaylist(id, true)
+			return
+		}
+
+		s.pin(listPin(s.activeName()), s.active(), true)
+	})
+
+	s.App.Event.On("front.unpin.list", func(event *application.CustomEvent) {
+		if id := s.activePlaylist(); id != "" {
+			s.pinPlaylist(id, false)
+			return
+		}
+
+		s.unpin(listPin(s.activeName()))
+	})
+
+	s.App.Event.On("front.pin.playlist", func(event *application.CustomEvent) {
+		s.pinPlaylist(event.Data.(string), true)
+	})
+
+	s.App.Event.On("front.unpin.playlist", func(event *application.CustomEvent) {
+		s.pinPlaylist(event.Data.(string), false)
+	})
+
 	s.App.Event.On("front.search.button", func(event *application.CustomEvent) {
 		s.App.Logger.Debug("front.search.button", "event", event.Data)
 		s.List()
@@ -294,6 +892,70 @@ func (s *State) ServiceStartup(ctx context.Context, options application.ServiceO
 	return nil
 }
 
+// recent sends the recent searches after they changed.
+func (s *State) recent(err error) {
+	if err != nil {
+		s.App.Logger.Error("recent searches", "error", err)
+		return
+	}
+
+	s.App.Event.Emit("search.recent", s.Search.Recent())
+}
+
+// playlists sends the playlists after they changed, and shows the one
+// shown again.
+func (s *State) playlists(err error) {
+	if err != nil {
+		s.App.Logger.Error("playlists", "error", err)
+		return
+	}
+
+	s.App.Event.Emit("playlists", s.Playlists.List())
+	s.App.Event.Emit("playlists.pinned", s.Playlists.Pinned())
+	s.pinOffline()
+
+	if id := s.activePlaylist(); id != "" {
+		s.playlist(id)
+	}
+}
+
+// activePlaylist returns the id of the playlist shown, if one is.
+func (s *State) activePlaylist() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Active.Playlist
+}
+
+// playlist shows the playlist with id as the list. Tracks no longer in the
+// library are shown with their path only.
+func (s *State) playlist(id string) {
+	p, err := s.Playlists.Get(id)
+	if errors.Is(err, playlist.ErrNotFound) {
+		// Deleted while shown.
+		s.List()
+		return
+	}
+
+	if err != nil {
+		s.App.Logger.Error("s.Playlists.Get", "error", err)
+		return
+	}
+
+	files := s.Search.Files(p.Tracks)
+
+	s.mu.Lock()
+	s.Active.List = make(map[int]*api.File)
+	for i, file := range files {
+		s.Active.List[i] = file
+	}
+	s.Active.Name = "Playlist: " + p.Name
+	s.Active.Playlist = p.Id
+	s.mu.Unlock()
+
+	s.App.Event.Emit("list", files)
+}
+
 func scale(unscaledNum, minAllowed, maxAllowed, min, max float64) float64 {
 	return (maxAllowed-minAllowed)*(unscaledNum-min)/(max-min) + minAllowed
 }
@@ -302,10 +964,12 @@ func (s *State) List() []api.File {
 	list := s.Search.List()
 
 	s.mu.Lock()
+	s.Active.List = make(map[int]*api.File)
 	for k, v := range list {
 		s.Active.List[k] = &v
 	}
 	s.Active.Name = "--"
+	s.Active.Playlist = ""
 	s.mu.Unlock()
 
 	s.App.Event.Emit("list", list)
@@ -313,172 +977,429 @@ func (s *State) List() []api.File {
 	return list
 }
 
-func (s *State) play(index int) {
+// Pins are owned by what was pinned, so unpinning an album keeps the tracks
+// a pinned playlist still has. Owners are joined with a unit separator,
+// which names don't have.
+func artistPin(artist string) string       { return "artist\x1f" + artist }
+func albumPin(artist, album string) string { return "album\x1f" + artist + "\x1f" + album }
+func playlistPin(id string) string         { return "playlist\x1f" + id }
+func listPin(name string) string           { return "list\x1f" + name }
+
+// pin makes files available offline for owner, or not. The paths of the
+// files that couldn't be are sent to the frontend.
+func (s *State) pin(owner string, files []*api.File, pinned bool) {
+	var download []*api.File
+	var failed []string
+	for _, file := range files {
+		// Local files are always available.
+		if super.Local(file.Source) {
+			continue
+		}
+
+		// Tracks of playlists no longer in the library can't be downloaded.
+		if pinned && file.Source == "" {
+			failed = append(failed, file.Path)
+			continue
+		}
+
+		err := s.Cache.Pin(owner, file, pinned)
+		if err != nil {
+			s.App.Logger.Error("s.Cache.Pin", "path", file.Path, "error", err)
+			failed = append(failed, file.Path)
+			continue
+		}
+
+		if pinned {
+			download = append(download, file)
+		}
+	}
+
+	s.Downloader.Download(download...)
+	s.App.Event.Emit("cache.stats", s.Cache.Stats())
+
+	if len(failed) > 0 {
+		s.App.Event.Emit("pin.failed", failed)
+		s.App.Event.Emit("toast", Toast{
+			Message: fmt.Sprintf("%d of %d tracks could not be pinned", len(failed), len(files)),
+		})
+	}
+}
+
+// unpin releases the files owner pinned, the ones it pinned since changed
+// included.
+func (s *State) unpin(owner string) {
+	s.pin(owner, s.Cache.Owned(owner), false)
+}
+
+// pinPlaylist keeps the tracks of the playlist with id available offline,
+// now and as it changes, or stops.
+func (s *State) pinPlaylist(id string, pinned bool) {
+	p, err := s.Playlists.Get(id)
+	if err != nil {
+		s.App.Logger.Error("s.Playlists.Get", "error", err)
+		return
+	}
+
+	err = s.Playlists.Pin(id, pinned)
+	if err != nil {
+		s.App.Logger.Error("s.Playlists.Pin", "error", err)
+		return
+	}
+
+	if pinned {
+		s.pin(playlistPin(id), s.Search.Files(p.Tracks), true)
+	} else {
+		s.unpin(playlistPin(id))
+	}
+
+	s.App.Event.Emit("playlists.pinned", s.Playlists.Pinned())
+}
+
+// pinOffline brings the pins of the playlists up to date: it pins the
+// tracks added to the pinned playlists since, and releases the tracks
+// removed from them and the playlists no longer pinned.
+func (s *State) pinOffline() {
+	pinned := make(map[string]bool)
+	for _, id := range s.Playlists.Pinned() {
+		owner := playlistPin(id)
+		pinned[owner] = true
+
+		p, err := s.Playlists.Get(id)
+		if err != nil {
+			continue
+		}
+
+		tracks := make(map[string]bool)
+		var files []*api.File
+		for _, file := range s.Search.Files(p.Tracks) {
+			tracks[file.Path] = true
+
+			// Ones not in the library were reported when pinned.
+			if file.Source != "" && !super.Local(file.Source) && !s.Cache.PinnedBy(owner, file.Path) {
+				files = append(files, file)
+			}
+		}
+
+		var removed []*api.File
+		for _, file := range s.Cache.Owned(owner) {
+			if !tracks[file.Path] {
+				removed = append(removed, file)
+			}
+		}
+
+		if len(files) > 0 {
+			s.pin(owner, files, true)
+		}
+
+		if len(removed) > 0 {
+			s.pin(owner, removed, false)
+		}
+	}
+
+	for _, owner := range s.Cache.Owners() {
+		if strings.HasPrefix(owner, playlistPin("")) && !pinned[owner] {
+			s.unpin(owner)
+		}
+	}
+}
+
+func (s *State) artist(artist string) []*api.File {
+	var files []*api.File
+	list := s.Search.List()
+	for i := range list {
+		if list[i].Artist == artist {
+			files = append(files, &list[i])
+		}
+	}
+
+	return files
+}
+
+func (s *State) album(artist, album string) []*api.File {
+	var files []*api.File
+	list := s.Search.List()
+	for i := range list {
+		if list[i].Artist == artist && list[i].Album == album {
+			files = append(files, &list[i])
+		}
+	}
+
+	return files
+}
+
+// activeName returns the name of the list shown.
+func (s *State) activeName() string {
 	s.mu.Lock()
-	if s.playing == PLAYING || s.playing == PAUSED {
-		s.tickerKill <- struct{}{}
+	defer s.mu.Unlock()
+
+	return s.Active.Name
+}
+
+func (s *State) active() []*api.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var files []*api.File
+	for _, file := range s.Active.List {
+		files = append(files, file)
 	}
 
-	s.App.Event.Emit("status.left", s.Active.Name)
-	track, ok := s.Active.List[index-1]
-	if ok {
-		go func() {
-			s.App.Event.Emit("progress.bar", 100)
-			s.App.Event.Emit("segmented", nil)
-			s.App.Event.Emit("time", "loading...")
-		}()
+	return files
+}
 
-		s.App.Event.Emit("play.pause", "Pause")
-		s.App.Event.Emit("play.pause.deactivate", false)
-		s.App.Event.Emit("status.center", track.Artist+" - "+track.Track)
-		s.Active.index = index - 1
+// listed returns the list shown in order.
+func (s *State) listed() []*api.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := make([]*api.File, 0, len(s.Active.List))
+	for i := 0; i < len(s.Active.List); i++ {
+		files = append(files, s.Active.List[i])
+	}
+
+	return files
+}
+
+// listedAt returns the track at index of the list shown, counting from 1.
+func (s *State) listedAt(index int) (*api.File, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, ok := s.Active.List[index-1]
+	return file, ok
+}
+
+// transition follows the player when it moves on to the queued track.
+func (s *State) transition(file *api.File) {
+	current, ok := s.Queue.Advance()
+	if !ok || current.Path != file.Path {
+		s.App.Logger.Warn("transition to a track not in the queue", "path", file.Path)
+	}
+
+	s.App.Event.Emit("status.center", file.Artist+" - "+file.Track)
+	s.queued()
+}
+
+// play starts playing file, which the queue moved on to.
+func (s *State) play(file *api.File) {
+	s.App.Event.Emit("status.center", file.Artist+" - "+file.Track)
+	s.App.Logger.Debug("play", "track", file.Track, "artist", file.Artist)
+
+	err := s.Player.New(file)
+	if err != nil {
+		s.failed(file, err)
+		return
+	}
+
+	s.mu.Lock()
+	s.retries, s.skipped = 0, 0
+	s.mu.Unlock()
+
+	s.queued()
+}
+
+const (
+	// maxRetries is how often a track that is unavailable for now is tried
+	// again before asking what to do.
+	maxRetries = 3
+	// maxSkipped is how many tracks in a row are skipped before giving up,
+	// so a queue of broken tracks on repeat doesn't go round forever.
+	maxSkipped = 10
+)
+
+// failed decides what to do about a track that can't be played. Tracks
+// that might work later are retried a few times, then the frontend asks
+// whether to retry or skip. Tracks that never will are skipped.
+func (s *State) failed(file *api.File, err error) {
+	message := file.Track + ": " + err.Error()
+
+	var trackErr *player.TrackError
+	temporary := errors.As(err, &trackErr) && trackErr.Temporary()
+	if trackErr != nil {
+		message = file.Track + ": " + trackErr.Kind.Error()
+	}
+
+	s.mu.Lock()
+	retry := temporary && s.retries < maxRetries
+	if retry {
+		s.retries++
+	} else {
+		s.retries = 0
+	}
+
+	retries := s.retries
+	s.skipped++
+	skip := !temporary && s.skipped <= maxSkipped
+	s.mu.Unlock()
+
+	switch {
+	case retry:
+		s.App.Event.Emit("toast", Toast{
+			Message: fmt.Sprintf("%s, retrying (%d/%d)", message, retries, maxRetries),
+		})
+
+		time.AfterFunc(time.Duration(retries)*time.Second, func() {
+			// Something else was played since.
+			current, ok := s.Queue.Current()
+			if !ok || current.Path != file.Path || s.Player.State() != player.Error {
+				return
+			}
+
+			s.play(file)
+		})
+
+	case temporary:
+		s.App.Event.Emit("toast", Toast{Message: message, Retry: true, Skip: true})
+
+	case skip:
+		s.App.Event.Emit("toast", Toast{Message: message + ", skipped"})
+		s.skip()
 
-		s.App.Logger.Debug("play", "track", track.Track, "artist", track.Artist, "index", index-1)
-		s.Player.New(track.Path, s.Controls.Volume.Value, 0)
+	default:
+		s.App.Event.Emit("toast", Toast{Message: message})
+	}
+}
+
+// skip moves on from a track that can't be played.
+func (s *State) skip() {
+	file, ok := s.Queue.Next()
+	if ok {
+		s.play(file)
+		return
 	}
 
-	nextTrack, nextOk := s.Active.List[index]
-	if nextOk {
-		s.App.Event.Emit("status.right", nextTrack.Artist+" - "+nextTrack.Track)
+	s.queued()
+
+	s.App.Event.Emit("play.pause", "Play")
+	s.App.Event.Emit("play.pause.deactivate", true)
+	s.App.Event.Emit("status.center", "--")
+}
+
+// queued brings the controls and the prefetched track in line with the
+// queue after it changed.
+func (s *State) queued() {
+	next, ok := s.Queue.Peek()
+	if ok {
+		s.App.Event.Emit("status.right", next.Artist+" - "+next.Track)
 		s.App.Event.Emit("next", false)
 	} else {
 		s.App.Event.Emit("status.right", "--")
 		s.App.Event.Emit("next", true)
 	}
 
-	_, prevOk := s.Active.List[index-2]
-	if prevOk {
-		s.App.Event.Emit("previous", false)
-	} else {
-		s.App.Event.Emit("previous", true)
-	}
+	s.App.Event.Emit("previous", !s.Queue.HasPrevious())
+	s.App.Event.Emit("queue", s.Queue.Snapshot())
 
-	s.playing = PLAYING
-	s.mu.Unlock()
+	// Prefetch it so it follows without a gap.
+	if s.Player.State() != player.Idle {
+		s.Player.Queue(next)
+	}
+}
 
-	// s.App.EmitEvent("time", "loading...")
-	// s.App.EmitEvent("progress.bar", 0.)
+// playback updates the controls after an event of the player.
+func (s *State) playback(event player.Event) {
+	switch event.State {
+	case player.Loading:
+		s.App.Event.Emit("play.pause", "Pause")
+		s.App.Event.Emit("play.pause.deactivate", false)
+		s.App.Event.Emit("progress.bar", 100)
+		s.App.Event.Emit("segmented", nil)
+		s.App.Event.Emit("time", "loading...")
+
+	case player.Playing, player.Paused:
+		if event.State == player.Playing {
+			s.App.Event.Emit("play.pause", "Pause")
+		} else {
+			s.App.Event.Emit("play.pause", "Play")
+		}
 
-	for {
-		select {
-		case <-s.tickerKill:
+		if event.Duration <= 0 {
+			s.App.Event.Emit("progress.bar", 100)
+			s.App.Event.Emit("segmented", nil)
+			s.App.Event.Emit("time", "loading...")
 			return
+		}
 
-		default:
-			if s.Player.Oto != nil {
-				if s.Player.Oto.IsPlaying() {
-					break
-				}
-			}
+		s.App.Event.Emit("segmented.off", nil)
+		s.App.Event.Emit("time", event.Position.Truncate(time.Second).String())
+		s.App.Event.Emit("progress.bar", scale(float64(event.Position), 0, 100, 0, float64(event.Duration)))
 
-			time.Sleep(50 * time.Millisecond)
-			continue
+	case player.Stopped:
+		if event.File != nil {
+			s.App.Event.Emit("play.pause", "Play")
+			s.App.Event.Emit("time", event.Position.Truncate(time.Second).String())
+			s.App.Event.Emit("progress.bar", 0.)
+			return
 		}
 
-		break
+		// The last track ended, a queued one might not have loaded in time.
+		file, ok := s.Queue.Advance()
+		if ok {
+			s.play(file)
+			return
+		}
+
+		s.queued()
+
+		s.App.Event.Emit("play.pause", "Play")
+		s.App.Event.Emit("play.pause.deactivate", true)
+		s.App.Event.Emit("status.center", "--")
+		s.App.Event.Emit("time", "--:--")
+		s.App.Event.Emit("progress.bar", 0.)
+
+	case player.Error:
+		s.App.Logger.Error("player", "path", event.File.Path, "error", event.Err)
+		s.App.Event.Emit("play.pause", "Play")
+		s.App.Event.Emit("segmented.off", nil)
+		s.App.Event.Emit("time", "--:--")
+		s.App.Event.Emit("progress.bar", 0.)
 	}
+}
 
-	const tik = time.Duration(200 * time.Millisecond)
-	go func() {
-		ticker := time.NewTicker(tik)
-		var loading bool
-		for {
-			select {
-			case <-ticker.C:
-				if !s.Player.Oto.IsPlaying() {
-					ticker.Stop()
-					s.playing = STOPPED
-
-					if nextOk {
-						s.play(index + 1)
-						return
-					}
-
-					s.App.Event.Emit("play.pause", "Play")
-					s.App.Event.Emit("play.pause.deactivate", true)
-					s.App.Event.Emit("status.center", "--")
-					s.App.Event.Emit("time", "--:--")
-					s.App.Event.Emit("progress.bar", 0.)
-					return
-				}
+// devices sends the output devices and the one in use to the frontend.
+func (s *State) devices() {
+	devices, err := s.Player.Devices()
+	if err != nil {
+		s.App.Logger.Error("s.Player.Devices", "error", err)
+		return
+	}
 
-				meta := s.Player.Meta()
+	s.App.Event.Emit("devices", devices)
+	s.App.Event.Emit("device", s.Player.Device())
+}
 
-				// s.App.Logger.Debug("ticker", "meta", meta)
+// setDSP applies dsp and lets the frontend know.
+func (s *State) setDSP(dsp player.DSP) {
+	err := s.Player.SetDSP(dsp)
+	if err != nil {
+		s.App.Logger.Error("s.Player.SetDSP", "error", err)
+		return
+	}
 
-				if meta.Size <= 0 || meta.Length <= 0 {
-					if loading {
-						loading = false
-					} else {
-						loading = true
-					}
-					s.App.Event.Emit("progress.bar", 100)
-					s.App.Event.Emit("segmented", nil)
-					s.App.Event.Emit("time", "loading...")
-					continue
-				}
+	s.App.Event.Emit("dsp", s.Player.DSP())
+}
 
-				s.App.Event.Emit("segmented.off", nil)
+// seek moves the current track to a percentage of its duration.
+func (s *State) seek(percent float64) {
+	duration := s.Player.Duration()
+	if duration <= 0 {
+		return
+	}
 
-				s.App.Event.Emit("time", time.Duration(
-					int(float64(meta.Size-meta.Length)/44100)*int(time.Second),
-				).String())
-				s.App.Event.Emit("progress.bar", scale(float64(meta.Size-meta.Length), 0, 100, 0, float64(meta.Size)))
-			case <-s.tickerStop:
-				ticker.Stop()
+	if percent < 0 {
+		percent = 0
+	}
 
-			case <-s.tickerReset:
-				ticker.Reset(tik)
+	if percent > 100 {
+		percent = 100
+	}
 
-			case <-s.tickerKill:
-				return
+	position := time.Duration(scale(percent, 0, float64(duration), 0, 100))
 
-			case newPosition := <-s.reposition:
-				meta := s.Player.Meta()
-				if meta.Path != "" {
-					if newPosition < 0 {
-						newPosition = 0
-					}
-
-					if newPosition > 100 {
-						newPosition = 100
-					}
-
-					offset := scale(
-						newPosition,
-						0,
-						float64(meta.Size),
-						0,
-						100,
-					)
-
-					s.App.Logger.Debug("offset", "offset", int64(offset), "newpos", newPosition, "size", meta.Size)
-
-					if s.Player.Oto != nil {
-						if meta.Download {
-							if s.Player.Oto.IsPlaying() {
-								s.Player.New(track.Path, s.Controls.Volume.Value, int64(offset)*4)
-							} else {
-								s.Player.New(track.Path, s.Controls.Volume.Value, int64(offset)*4)
-								s.Player.Oto.Pause()
-							}
-						} else {
-							_, err := s.Player.Oto.Seek(int64(offset)*4, io.SeekStart)
-							if err != nil {
-								s.App.Logger.Error("s.Player.Oto.Seek", "error", err)
-								return
-							}
-						}
-					}
-
-					meta := s.Player.Meta()
-
-					s.App.Event.Emit("time", time.Duration(
-						int(float64(meta.Size-meta.Length)/44100)*int(time.Second),
-					).String())
-					s.App.Event.Emit("progress.bar", scale(float64(meta.Size-meta.Length), 0, 100, 0, float64(meta.Size)))
-				}
-			}
-		}
-	}()
+	s.App.Logger.Debug("seek", "position", position, "percent", percent, "duration", duration)
+
+	err := s.Player.SeekTo(position)
+	if err != nil {
+		s.App.Logger.Error("s.Player.SeekTo", "error", err)
+	}
 }
@@ -0,0 +1,225 @@
+package player
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// The output format. oto allows a single context per process, so it is
+// fixed when the player is initialised and every track is converted to it.
+const (
+	sampleRate = 44100
+	channels   = 2
+	// frame is the size of one output frame, stereo float32.
+	frame = channels * 4
+)
+
+// chunk is how many frames the converter reads at once.
+const chunk = 4096
+
+// toDuration returns how long n bytes of output play for.
+func toDuration(n int64) time.Duration {
+	return time.Duration(n/frame) * time.Second / sampleRate
+}
+
+// toOffset returns the output byte offset d into a track.
+func toOffset(d time.Duration) int64 {
+	return int64(d*sampleRate/time.Second) * frame
+}
+
+// format describes the PCM a decoder produces, little endian 16bit
+// integers or, if float is set, float32 samples.
+type format struct {
+	rate     int
+	channels int
+	float    bool
+}
+
+// size returns the size of one sample.
+func (f format) size() int {
+	if f.float {
+		return 4
+	}
+
+	return 2
+}
+
+// converter turns the PCM of a decoder into samples of the output format.
+// Mono is spread to both channels, more than two channels are downmixed and
+// other sample rates are resampled.
+type converter struct {
+	src       io.ReadSeeker
+	format    format
+	resampler *resampler
+	in        []byte
+	mixed     []float32
+	out       []float32
+	eof       bool
+	// pos is the output position in bytes.
+	pos int64
+	// gain is the loudness normalisation, applied before the limiter.
+	gain    float32
+	limiter *limiter
+}
+
+func newConverter(src io.ReadSeeker, f format) *converter {
+	c := &converter{
+		src:     src,
+		format:  f,
+		in:      make([]byte, chunk*f.channels*f.size()),
+		gain:    1,
+		limiter: newLimiter(),
+	}
+
+	if f.rate != sampleRate {
+		c.resampler = newResampler(f.rate, sampleRate)
+	}
+
+	return c
+}
+
+// read converts the next samples into out, which must hold whole frames.
+func (c *converter) read(out []float32) (int, error) {
+	for len(c.out) < len(out) && !c.eof {
+		err := c.fill()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(c.out) == 0 && c.eof {
+		return 0, io.EOF
+	}
+
+	n := copy(out, c.out)
+
+	// Without normalisation the samples are left as they are.
+	if c.gain != 1 {
+		c.limiter.process(out[:n], c.gain)
+	}
+
+	c.out = append(c.out[:0], c.out[n:]...)
+	c.pos += int64(n * 4)
+
+	return n, nil
+}
+
+// fill reads and converts the next chunk of the source.
+func (c *converter) fill() error {
+	n, err := io.ReadFull(c.src, c.in)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		c.eof = true
+	} else if err != nil {
+		return err
+	}
+
+	size := c.format.channels * c.format.size()
+	c.mixed = c.mixed[:0]
+	for i := 0; i+size <= n; i += size {
+		left, right := downmix(c.in[i:i+size], c.format)
+		c.mixed = append(c.mixed, left, right)
+	}
+
+	if c.resampler == nil {
+		c.out = append(c.out, c.mixed...)
+		return nil
+	}
+
+	c.out = c.resampler.process(c.mixed, c.out)
+	if c.eof {
+		c.out = c.resampler.flush(c.out)
+	}
+
+	return nil
+}
+
+// duration returns the length of the source, if its decoder knows it.
+func (c *converter) duration() time.Duration {
+	l, ok := c.src.(interface{ Length() int64 })
+	if !ok {
+		return 0
+	}
+
+	frames := l.Length() / int64(c.format.channels*c.format.size())
+	if frames <= 0 {
+		return 0
+	}
+
+	return time.Duration(frames) * time.Second / time.Duration(c.format.rate)
+}
+
+// Seek seeks to an offset of the output in bytes.
+func (c *converter) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.pos
+	default:
+		return 0, errors.New("converter: unsupported whence")
+	}
+
+	if offset == c.pos {
+		return c.pos, nil
+	}
+
+	// The matching frame of the source.
+	in := offset / frame * int64(c.format.rate) / sampleRate
+	_, err := c.src.Seek(in*int64(c.format.channels*c.format.size()), io.SeekStart)
+	if err != nil {
+		return c.pos, err
+	}
+
+	c.out = c.out[:0]
+	c.eof = false
+	if c.resampler != nil {
+		c.resampler.reset()
+	}
+
+	c.pos = offset / frame * frame
+
+	return c.pos, nil
+}
+
+// downmix returns the left and right samples of a frame. Channels past
+// the first two follow the WAV and FLAC order: centre, LFE and the
+// surround pairs. The LFE is dropped.
+func downmix(b []byte, f format) (float32, float32) {
+	sample := func(i int) float32 {
+		if f.float {
+			return math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+		}
+
+		return float32(int16(binary.LittleEndian.Uint16(b[2*i:]))) / (1 << 15)
+	}
+
+	n := f.channels
+	switch n {
+	case 1:
+		mono := sample(0)
+		return mono, mono
+
+	case 2:
+		return sample(0), sample(1)
+	}
+
+	const half = 0.7071
+	left, right := sample(0), sample(1)
+	gain := float32(1)
+
+	// Centre.
+	left += half * sample(2)
+	right += half * sample(2)
+	gain += half
+
+	// Surround pairs.
+	for i := 4; i+1 < n; i += 2 {
+		left += half * sample(i)
+		right += half * sample(i+1)
+		gain += half
+	}
+
+	return left / gain, right / gain
+}
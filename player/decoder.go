@@ -0,0 +1,137 @@
+package player
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// decoder knows how to turn one format into PCM.
+type decoder struct {
+	name       string
+	extensions []string
+	// sniff reports whether header, the first bytes of a file past any
+	// ID3v2 tag, belong to the format.
+	sniff func(header []byte) bool
+	// decode returns the PCM stream of r and its format.
+	decode func(r io.ReadSeeker) (io.ReadSeeker, format, error)
+}
+
+// decoders are the registered formats, in the order they are sniffed.
+var decoders []*decoder
+
+// register adds a format to the player. Formats register themselves from
+// their own file, so the player doesn't need to know about any of them.
+func register(d *decoder) {
+	decoders = append(decoders, d)
+}
+
+// sniffLen is how many bytes are looked at to tell the format of a file.
+const sniffLen = 64
+
+// decoderFor picks the decoder of r by its content. The extension is only
+// used when nothing matches, for example when a long ID3 tag hasn't been
+// downloaded yet. r is left at the start.
+func decoderFor(r io.ReadSeeker, ext string) (*decoder, error) {
+	header, err := sniff(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range decoders {
+		if d.sniff(header) {
+			return d, nil
+		}
+	}
+
+	ext = strings.ToLower(ext)
+	for _, d := range decoders {
+		if slices.Contains(d.extensions, ext) {
+			return d, nil
+		}
+	}
+
+	return nil, ErrUnsupported
+}
+
+// sniff returns the first bytes of r after any ID3v2 tag, which mp3 and
+// sometimes flac files start with.
+func sniff(r io.ReadSeeker) ([]byte, error) {
+	defer r.Seek(0, io.SeekStart)
+
+	header, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(header) < 10 || !bytes.HasPrefix(header, []byte("ID3")) {
+		return header, nil
+	}
+
+	// The tag size is stored in 7 bits per byte, without the header.
+	size := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+	size += 10
+	// With a footer.
+	if header[5]&0x10 != 0 {
+		size += 10
+	}
+
+	_, err = r.Seek(size, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return readHeader(r)
+}
+
+func readHeader(r io.Reader) ([]byte, error) {
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+
+	return header[:n], nil
+}
+
+// floatReader turns blocks of interleaved float32 samples into the little
+// endian bytes the converter reads.
+type floatReader struct {
+	// next returns the next block of samples.
+	next    func() ([]float32, error)
+	pending []float32
+}
+
+func (f *floatReader) Read(p []byte) (int, error) {
+	for len(f.pending) == 0 {
+		samples, err := f.next()
+		if len(samples) == 0 && err != nil {
+			return 0, err
+		}
+
+		f.pending = samples
+	}
+
+	n := min(len(p)/4, len(f.pending))
+	for i, v := range f.pending[:n] {
+		binary.LittleEndian.PutUint32(p[4*i:], math.Float32bits(v))
+	}
+
+	f.pending = f.pending[n:]
+
+	return n * 4, nil
+}
+
+// sample returns the sample a byte offset of the stream points to, for
+// decoders to seek by.
+func sample(offset int64, whence int, channels int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, errors.New("unsupported whence")
+	}
+
+	return offset / int64(channels*4), nil
+}
@@ -0,0 +1,95 @@
+package player
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+var errNoDevices = errors.New("output devices can't be picked on this system")
+
+var errNoDevice = errors.New("no such output device")
+
+// ErrRestart is returned for an output device that is only played to
+// once super is started again.
+var ErrRestart = errors.New("output device changes on restart")
+
+const deviceKey = super.Setting + "device"
+
+// Device is an audio output.
+type Device struct {
+	// Name identifies the device to the system.
+	Name        string
+	Description string
+	// Default is set for the device the system plays to unless told otherwise.
+	Default bool
+}
+
+// Devices returns the output devices of the system. There is always at
+// least the default one.
+func (p *Player) Devices() ([]Device, error) {
+	devices, err := devices()
+	if err != nil {
+		p.logger.Error("listing output devices", "error", err)
+		return nil, err
+	}
+
+	return append([]Device{{Name: defaultDevice, Description: "System default"}}, devices...), nil
+}
+
+// SetDevice moves playback to the output device called name, even while
+// playing, and remembers it. The DSP settings of that device are applied.
+// Where the device can't change while playing it is remembered and
+// ErrRestart is returned.
+func (p *Player) SetDevice(name string) error {
+	err := switchDevice(name)
+	restart := errors.Is(err, ErrRestart)
+	if err != nil && !restart {
+		p.logger.Error("switching output device", "device", name, "error", err)
+		return err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = gob.NewEncoder(buf).Encode(name)
+	if err != nil {
+		p.logger.Error("gob.Encode", "error", err)
+		return err
+	}
+
+	err = p.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(deviceKey), buf.Bytes())
+	})
+	if err != nil {
+		p.logger.Error("badger.Set", "error", err)
+		return err
+	}
+
+	dsp, err := p.loadDSP(name)
+	if err != nil {
+		p.logger.Error("loading dsp settings", "device", name, "error", err)
+		return err
+	}
+
+	p.stateMu.Lock()
+	p.device, p.dsp = name, dsp
+	p.stateMu.Unlock()
+
+	p.source.setChain(dsp.chain())
+
+	if restart {
+		return ErrRestart
+	}
+
+	return nil
+}
+
+// Device returns the name of the output device.
+func (p *Player) Device() string {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	return p.device
+}
@@ -0,0 +1,269 @@
+package player
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// On Linux oto plays through ALSA, which on most desktops is routed to
+// PulseAudio or PipeWire. Their sinks are the devices and pactl moves the
+// stream of the player between them. A null sink is enough to try it out:
+//
+//	pactl load-module module-null-sink sink_name=null
+//
+// Without a sound server the devices are the playback devices of the ALSA
+// cards. ALSA picks one when the stream is opened, so switching between
+// them takes a restart.
+
+// alsaPCM lists the PCM devices of the ALSA cards.
+const alsaPCM = "/proc/asound/pcm"
+
+// devices lists the sinks of the sound server, or the ALSA playback
+// devices without one.
+func devices() ([]Device, error) {
+	_, err := exec.LookPath("pactl")
+	if err != nil {
+		return alsaDevices()
+	}
+
+	out, err := exec.Command("pactl", "list", "sinks").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	fallback, err := defaultSink()
+	if err != nil {
+		return nil, err
+	}
+
+	return sinks(out, fallback), nil
+}
+
+// sinks returns the devices of the output of pactl list sinks. Fallback
+// is the name of the default sink.
+func sinks(out []byte, fallback string) []Device {
+	var devices []Device
+	for _, block := range blocks(out, "Sink #") {
+		name := block["Name"]
+		if name == "" {
+			continue
+		}
+
+		devices = append(devices, Device{
+			Name:        name,
+			Description: block["Description"],
+			Default:     name == fallback,
+		})
+	}
+
+	return devices
+}
+
+// switchDevice moves the stream of the player to the sink called name.
+// Streams opened later go there too.
+func switchDevice(name string) error {
+	_, err := exec.LookPath("pactl")
+	if err != nil {
+		return switchALSA(name)
+	}
+
+	if name == defaultDevice {
+		os.Unsetenv("PULSE_SINK")
+
+		name, err = defaultSink()
+		if err != nil {
+			return err
+		}
+	} else {
+		os.Setenv("PULSE_SINK", name)
+	}
+
+	out, err := exec.Command("pactl", "list", "sink-inputs").Output()
+	if err != nil {
+		return err
+	}
+
+	pid := strconv.Itoa(os.Getpid())
+	for _, block := range blocks(out, "Sink Input #") {
+		if strings.Trim(block["application.process.id"], `"`) != pid {
+			continue
+		}
+
+		err = exec.Command("pactl", "move-sink-input", block["index"], name).Run()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// defaultSink returns the name of the sink the sound server plays to by
+// default.
+func defaultSink() (string, error) {
+	out, err := exec.Command("pactl", "info").Output()
+	if err != nil {
+		return "", err
+	}
+
+	for line := range strings.Lines(string(out)) {
+		name, ok := strings.CutPrefix(line, "Default Sink:")
+		if ok {
+			return strings.TrimSpace(name), nil
+		}
+	}
+
+	return "", errors.New("no default sink")
+}
+
+// blocks splits the long output of pactl list into one map per object,
+// keyed by the fields and properties of the object. The number in the
+// heading is stored as index.
+func blocks(out []byte, heading string) []map[string]string {
+	var blocks []map[string]string
+	var block map[string]string
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		index, ok := strings.CutPrefix(line, heading)
+		if ok {
+			block = map[string]string{"index": strings.TrimSpace(index)}
+			blocks = append(blocks, block)
+			continue
+		}
+
+		if block == nil {
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+
+		// Properties are written as key = "value".
+		key, value, ok := strings.Cut(line, " = ")
+		if !ok {
+			key, value, ok = strings.Cut(line, ":")
+		}
+
+		if ok {
+			key = strings.TrimSpace(key)
+			if _, seen := block[key]; !seen {
+				block[key] = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	return blocks
+}
+
+// alsaDevices lists the playback devices of the ALSA cards. Without any
+// only the default device is there.
+func alsaDevices() ([]Device, error) {
+	out, err := os.ReadFile(alsaPCM)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pcms(out, os.Getenv("ALSA_CARD")), nil
+}
+
+// pcms returns the playback devices of the contents of /proc/asound/pcm,
+// where each line is a device:
+//
+//	00-03: HDMI 0 : HDMI 0 : playback 1
+//
+// Card is the card ALSA plays to by default, the first one if empty.
+func pcms(out []byte, card string) []Device {
+	if card == "" {
+		card = "0"
+	}
+
+	var devices []Device
+	for line := range strings.Lines(string(out)) {
+		id, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		c, d, ok := strings.Cut(strings.TrimSpace(id), "-")
+		if !ok {
+			continue
+		}
+
+		cardIndex, err := strconv.Atoi(c)
+		if err != nil {
+			continue
+		}
+
+		device, err := strconv.Atoi(d)
+		if err != nil {
+			continue
+		}
+
+		fields := strings.Split(rest, ":")
+		playback := false
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(strings.TrimSpace(field), "playback") {
+				playback = true
+			}
+		}
+
+		if !playback {
+			continue
+		}
+
+		devices = append(devices, Device{
+			Name:        fmt.Sprintf("hw:%d,%d", cardIndex, device),
+			Description: strings.TrimSpace(fields[0]),
+			Default:     strconv.Itoa(cardIndex) == card && device == 0,
+		})
+	}
+
+	return devices
+}
+
+// switchALSA makes the stream oto opens play to the ALSA device called
+// name, one of alsaDevices. An open stream stays where it is, that is
+// ErrRestart.
+func switchALSA(name string) error {
+	if name == defaultDevice {
+		os.Unsetenv("ALSA_PCM_CARD")
+		os.Unsetenv("ALSA_PCM_DEVICE")
+		return ErrRestart
+	}
+
+	devices, err := alsaDevices()
+	if err != nil {
+		return err
+	}
+
+	var card, device int
+	found := false
+	for _, d := range devices {
+		if d.Name == name {
+			_, err = fmt.Sscanf(name, "hw:%d,%d", &card, &device)
+			found = err == nil
+			break
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%w: %s", errNoDevice, name)
+	}
+
+	os.Setenv("ALSA_PCM_CARD", strconv.Itoa(card))
+	os.Setenv("ALSA_PCM_DEVICE", strconv.Itoa(device))
+
+	return ErrRestart
+}
@@ -0,0 +1,175 @@
+package player
+
+import (
+	"slices"
+	"testing"
+)
+
+// pactlSinks is pactl list sinks on a laptop with PipeWire and a null sink
+// loaded, cut short.
+const pactlSinks = `Sink #46
+	State: SUSPENDED
+	Name: alsa_output.pci-0000_00_1f.3.analog-stereo
+	Description: Built-in Audio Analog Stereo
+	Driver: PipeWire
+	Sample Specification: s32le 2ch 48000Hz
+	Channel Map: front-left,front-right
+	Owner Module: 4294967295
+	Mute: no
+	Volume: front-left: 42597 /  65% / -11.23 dB,   front-right: 42597 /  65% / -11.23 dB
+	        balance 0.00
+	Base Volume: 65536 / 100% / 0.00 dB
+	Monitor Source: alsa_output.pci-0000_00_1f.3.analog-stereo.monitor
+	Latency: 0 usec, configured 0 usec
+	Flags: HARDWARE HW_MUTE_CTRL HW_VOLUME_CTRL DECIBEL_VOLUME LATENCY
+	Properties:
+		alsa.card = "0"
+		alsa.card_name = "HDA Intel PCH"
+		device.description = "Built-in Audio Analog Stereo"
+		object.serial = "47"
+	Ports:
+		analog-output-speaker: Speakers (type: Speaker, priority: 10000, availability unknown)
+	Active Port: analog-output-speaker
+	Formats:
+		pcm
+
+Sink #112
+	State: RUNNING
+	Name: null
+	Description: Null Output
+	Driver: PipeWire
+	Sample Specification: float32le 2ch 48000Hz
+	Channel Map: front-left,front-right
+	Owner Module: 536870913
+	Mute: no
+	Volume: front-left: 65536 / 100% / 0.00 dB,   front-right: 65536 / 100% / 0.00 dB
+	        balance 0.00
+	Base Volume: 65536 / 100% / 0.00 dB
+	Monitor Source: null.monitor
+	Latency: 0 usec, configured 0 usec
+	Flags: DECIBEL_VOLUME LATENCY
+	Properties:
+		node.name = "null"
+		device.description = "Null Output"
+		object.serial = "113"
+	Formats:
+		pcm
+`
+
+// pactlInputs is pactl list sink-inputs with the player playing to the
+// null sink.
+const pactlInputs = `Sink Input #118
+	Driver: PipeWire
+	Owner Module: n/a
+	Client: 117
+	Sink: 112
+	Properties:
+		application.name = "super"
+		application.process.id = "4242"
+		media.name = "ALSA Playback"
+
+Sink Input #120
+	Driver: PipeWire
+	Sink: 46
+	Properties:
+		application.process.id = "77"
+`
+
+func TestBlocks(t *testing.T) {
+	sinks := blocks([]byte(pactlSinks), "Sink #")
+	if len(sinks) != 2 {
+		t.Fatalf("%d sinks, want 2", len(sinks))
+	}
+
+	want := map[string]string{
+		"index":       "112",
+		"Name":        "null",
+		"Description": "Null Output",
+		"State":       "RUNNING",
+		// Property values keep their quotes.
+		"node.name": `"null"`,
+	}
+	for key, value := range want {
+		if got := sinks[1][key]; got != value {
+			t.Errorf("null sink %s = %q, want %q", key, got, value)
+		}
+	}
+
+	// The first of a key wins, the description property doesn't replace
+	// the field.
+	if got := sinks[0]["Description"]; got != "Built-in Audio Analog Stereo" {
+		t.Errorf("Description = %q", got)
+	}
+
+	// Volume: has colons of its own.
+	if got := sinks[0]["Volume"]; got == "" {
+		t.Error("Volume is missing")
+	}
+
+	inputs := blocks([]byte(pactlInputs), "Sink Input #")
+	if len(inputs) != 2 || inputs[0]["index"] != "118" || inputs[0]["application.process.id"] != `"4242"` {
+		t.Errorf("sink inputs %v", inputs)
+	}
+
+	if got := blocks([]byte("Default Sink: null\n"), "Sink #"); got != nil {
+		t.Errorf("blocks of no sinks = %v", got)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	got := sinks([]byte(pactlSinks), "null")
+	want := []Device{
+		{Name: "alsa_output.pci-0000_00_1f.3.analog-stereo", Description: "Built-in Audio Analog Stereo"},
+		{Name: "null", Description: "Null Output", Default: true},
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("sinks = %v, want %v", got, want)
+	}
+}
+
+// asoundPCM is /proc/asound/pcm with a USB interface next to the built in
+// card, which also has a capture only device.
+const asoundPCM = `00-00: ALC3246 Analog : ALC3246 Analog : playback 1 : capture 1
+00-02: ALC3246 Alt Analog : ALC3246 Alt Analog : capture 1
+00-03: HDMI 0 : HDMI 0 : playback 1
+01-00: USB Audio : USB Audio : playback 1 : capture 1
+`
+
+func TestPCMs(t *testing.T) {
+	tests := []struct {
+		name string
+		card string
+		want []Device
+	}{
+		{
+			name: "first card by default",
+			want: []Device{
+				{Name: "hw:0,0", Description: "ALC3246 Analog", Default: true},
+				{Name: "hw:0,3", Description: "HDMI 0"},
+				{Name: "hw:1,0", Description: "USB Audio"},
+			},
+		},
+		{
+			name: "ALSA_CARD",
+			card: "1",
+			want: []Device{
+				{Name: "hw:0,0", Description: "ALC3246 Analog"},
+				{Name: "hw:0,3", Description: "HDMI 0"},
+				{Name: "hw:1,0", Description: "USB Audio", Default: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pcms([]byte(asoundPCM), test.card); !slices.Equal(got, test.want) {
+				t.Errorf("pcms = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	if got := pcms(nil, ""); got != nil {
+		t.Errorf("pcms of no cards = %v", got)
+	}
+}
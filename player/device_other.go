@@ -0,0 +1,17 @@
+//go:build !linux
+
+package player
+
+// Elsewhere oto only plays to the default device.
+
+func devices() ([]Device, error) {
+	return nil, nil
+}
+
+func switchDevice(name string) error {
+	if name == defaultDevice {
+		return nil
+	}
+
+	return errNoDevices
+}
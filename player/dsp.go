@@ -0,0 +1,270 @@
+package player
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"slices"
+
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// dspKey is the prefix of the DSP settings, which are kept per output
+// device since every device sounds different.
+const dspKey = super.Setting + "dsp_"
+
+// defaultDevice is the device the settings are stored for until an output
+// device is picked.
+const defaultDevice = "default"
+
+// processor is a stage of the DSP chain. It works in place on interleaved
+// stereo samples of the output format.
+type processor interface {
+	process(samples []float32)
+}
+
+// chain runs the samples through every processor in order.
+type chain []processor
+
+func (c chain) process(samples []float32) {
+	for _, p := range c {
+		p.process(samples)
+	}
+}
+
+// Band is one band of the equalizer, a peaking filter.
+type Band struct {
+	// Frequency is the centre of the band in Hz.
+	Frequency float64
+	// Gain is in dB.
+	Gain float64
+	// Q is how narrow the band is.
+	Q float64
+}
+
+// bands are the centres of the ten equalizer bands, an octave apart.
+var bands = []float64{31, 62, 125, 250, 500, 1000, 2000, 4000, 8000, 16000}
+
+// bandQ is the Q of bands an octave wide.
+const bandQ = 1.41
+
+// Preset is a named set of band gains, in dB.
+type Preset struct {
+	Name  string
+	Gains []float64
+}
+
+// Presets are the equalizer presets, in the order they are shown.
+var Presets = []Preset{
+	{"flat", []float64{0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+	{"bass", []float64{6, 5, 4, 2, 0, 0, 0, 0, 0, 0}},
+	{"treble", []float64{0, 0, 0, 0, 0, 0, 2, 4, 5, 6}},
+	{"rock", []float64{4, 3, 2, 0, -1, -1, 0, 2, 3, 4}},
+	{"pop", []float64{-1, 0, 2, 3, 4, 3, 2, 0, -1, -1}},
+	{"jazz", []float64{3, 2, 1, 2, -1, -1, 0, 1, 2, 3}},
+	{"classical", []float64{4, 3, 2, 1, 0, 0, 0, 1, 2, 3}},
+	{"vocal", []float64{-2, -2, -1, 1, 3, 4, 3, 1, 0, -1}},
+}
+
+// DSP is how the output is processed before it is played.
+type DSP struct {
+	// Preset is the name of the preset the bands came from, empty once
+	// they were changed by hand.
+	Preset string
+	Bands  []Band
+	// Preamp is added to every band, in dB.
+	Preamp float64
+	// Mono mixes both channels together.
+	Mono bool
+	// Balance is from -1, left only, to 1, right only.
+	Balance float64
+}
+
+// NewDSP returns the settings of preset, flat if there is no such preset.
+func NewDSP(preset string) DSP {
+	p := Presets[0]
+	i := slices.IndexFunc(Presets, func(p Preset) bool { return p.Name == preset })
+	if i >= 0 {
+		p = Presets[i]
+	}
+
+	dsp := DSP{Preset: p.Name}
+	for i, frequency := range bands {
+		dsp.Bands = append(dsp.Bands, Band{
+			Frequency: frequency,
+			Gain:      p.Gains[i],
+			Q:         bandQ,
+		})
+	}
+
+	return dsp
+}
+
+// chain builds the processors the settings ask for. Stages that would
+// leave the samples as they are are left out.
+func (d DSP) chain() chain {
+	var c chain
+
+	boost := d.Preamp > 0
+	if d.Preamp != 0 {
+		c = append(c, preamp(decibels(d.Preamp)))
+	}
+
+	for _, b := range d.Bands {
+		if b.Gain == 0 || b.Frequency <= 0 || b.Frequency >= sampleRate/2 || b.Q <= 0 {
+			continue
+		}
+
+		boost = boost || b.Gain > 0
+		c = append(c, newPeaking(b))
+	}
+
+	if d.Mono {
+		c = append(c, mono{})
+	}
+
+	if d.Balance != 0 {
+		c = append(c, newBalance(d.Balance))
+	}
+
+	// Boosting can go over full scale.
+	if boost {
+		c = append(c, (*ceilingLimiter)(newLimiter()))
+	}
+
+	return c
+}
+
+// SetDSP sets how the output is processed and stores it for the current
+// output device.
+func (p *Player) SetDSP(dsp DSP) error {
+	dsp.Balance = min(max(dsp.Balance, -1), 1)
+
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(dsp)
+	if err != nil {
+		p.logger.Error("gob.Encode", "error", err)
+		return err
+	}
+
+	p.stateMu.Lock()
+	device := p.device
+	p.stateMu.Unlock()
+
+	err = p.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(dspKey+device), buf.Bytes())
+	})
+	if err != nil {
+		p.logger.Error("badger.Set", "error", err)
+		return err
+	}
+
+	p.stateMu.Lock()
+	p.dsp = dsp
+	p.stateMu.Unlock()
+
+	p.source.setChain(dsp.chain())
+
+	return nil
+}
+
+// DSP returns how the output is processed.
+func (p *Player) DSP() DSP {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	return p.dsp
+}
+
+// loadDSP reads the settings of device, flat if there are none.
+func (p *Player) loadDSP(device string) (DSP, error) {
+	dsp := NewDSP("")
+
+	err := p.setting(dspKey+device, &dsp)
+	if err != nil {
+		return DSP{}, err
+	}
+
+	return dsp, nil
+}
+
+type preamp float64
+
+func (g preamp) process(samples []float32) {
+	for i := range samples {
+		samples[i] *= float32(g)
+	}
+}
+
+// peaking is a peaking biquad filter, from the Audio EQ Cookbook, with the
+// state of both channels.
+type peaking struct {
+	b0, b1, b2, a1, a2 float64
+	// x1, x2, y1 and y2 are the previous inputs and outputs, per channel.
+	x1, x2, y1, y2 [channels]float64
+}
+
+func newPeaking(b Band) *peaking {
+	a := math.Pow(10, b.Gain/40)
+	w := 2 * math.Pi * b.Frequency / sampleRate
+	alpha := math.Sin(w) / (2 * b.Q)
+	a0 := 1 + alpha/a
+
+	return &peaking{
+		b0: (1 + alpha*a) / a0,
+		b1: -2 * math.Cos(w) / a0,
+		b2: (1 - alpha*a) / a0,
+		a1: -2 * math.Cos(w) / a0,
+		a2: (1 - alpha/a) / a0,
+	}
+}
+
+func (f *peaking) process(samples []float32) {
+	for i, v := range samples {
+		c := i % channels
+		x := float64(v)
+		y := f.b0*x + f.b1*f.x1[c] + f.b2*f.x2[c] - f.a1*f.y1[c] - f.a2*f.y2[c]
+
+		f.x2[c], f.x1[c] = f.x1[c], x
+		f.y2[c], f.y1[c] = f.y1[c], y
+
+		samples[i] = float32(y)
+	}
+}
+
+type mono struct{}
+
+func (mono) process(samples []float32) {
+	for i := 0; i+1 < len(samples); i += 2 {
+		v := (samples[i] + samples[i+1]) / 2
+		samples[i], samples[i+1] = v, v
+	}
+}
+
+// balance turns one channel down, the other one is left as it is.
+type balance struct {
+	left, right float32
+}
+
+func newBalance(b float64) balance {
+	if b < 0 {
+		return balance{left: 1, right: float32(1 + b)}
+	}
+
+	return balance{left: float32(1 - b), right: 1}
+}
+
+func (b balance) process(samples []float32) {
+	for i := 0; i+1 < len(samples); i += 2 {
+		samples[i] *= b.left
+		samples[i+1] *= b.right
+	}
+}
+
+// ceilingLimiter is the limiter as the last stage of the chain.
+type ceilingLimiter limiter
+
+func (l *ceilingLimiter) process(samples []float32) {
+	(*limiter)(l).process(samples, 1)
+}
@@ -0,0 +1,46 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bh90210/super/server/api"
+)
+
+// What can go wrong loading a track. A TrackError wraps one of them.
+var (
+	// ErrUnavailable is a failure that might go away, the server can't be
+	// reached or the cache can't be written. It is worth retrying.
+	ErrUnavailable = errors.New("track unavailable")
+	// ErrNotFound is a file that is gone, locally or from the server.
+	ErrNotFound = errors.New("track not found")
+	// ErrUnsupported is a format none of the decoders know.
+	ErrUnsupported = errors.New("unsupported format")
+	// ErrCorrupt is a file the decoder gave up on.
+	ErrCorrupt = errors.New("track can't be decoded")
+)
+
+// TrackError is returned when a track can't be played.
+type TrackError struct {
+	File *api.File
+	// Kind is one of the errors above.
+	Kind error
+	Err  error
+}
+
+func (e *TrackError) Error() string {
+	return fmt.Sprintf("%s: %v: %v", e.File.Path, e.Kind, e.Err)
+}
+
+func (e *TrackError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Temporary reports whether playing the track again might work.
+func (e *TrackError) Temporary() bool {
+	return e.Kind == ErrUnavailable
+}
+
+func trackError(file *api.File, kind, err error) *TrackError {
+	return &TrackError{File: file, Kind: kind, Err: err}
+}
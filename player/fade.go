@@ -0,0 +1,70 @@
+package player
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+const crossfadeKey = super.Setting + "crossfade"
+
+// MaxCrossfade is the longest crossfade between tracks.
+const MaxCrossfade = 12 * time.Second
+
+const (
+	// fadeTime is how long pausing, resuming and seeking fade for. It is
+	// just long enough to avoid the click of cutting a wave in half.
+	fadeTime = 20 * time.Millisecond
+	// fadeSteps is how many volume changes a fade is made of.
+	fadeSteps = 10
+)
+
+// SetCrossfade sets how long tracks fade into each other, 0 turns it off.
+// It is kept within [0, MaxCrossfade].
+func (p *Player) SetCrossfade(d time.Duration) error {
+	d = min(max(d, 0), MaxCrossfade)
+
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(d)
+	if err != nil {
+		p.logger.Error("gob.Encode", "error", err)
+		return err
+	}
+
+	err = p.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(crossfadeKey), buf.Bytes())
+	})
+	if err != nil {
+		p.logger.Error("badger.Set", "error", err)
+		return err
+	}
+
+	p.stateMu.Lock()
+	p.crossfade = d
+	p.stateMu.Unlock()
+
+	p.source.setCrossfade(d)
+
+	return nil
+}
+
+// Crossfade returns how long tracks fade into each other.
+func (p *Player) Crossfade() time.Duration {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	return p.crossfade
+}
+
+// fade moves the volume of oto from one level to another over fadeTime.
+// oto applies the volume as it mixes, so unlike the samples already
+// buffered it is heard right away.
+func (p *Player) fade(from, to float64) {
+	for i := 1; i <= fadeSteps; i++ {
+		p.oto.SetVolume(from + (to-from)*float64(i)/fadeSteps)
+		time.Sleep(fadeTime / fadeSteps)
+	}
+}
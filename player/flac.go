@@ -0,0 +1,93 @@
+package player
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/mewkiz/flac"
+)
+
+func init() {
+	register(&decoder{
+		name:       "flac",
+		extensions: []string{".flac"},
+		sniff: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("fLaC"))
+		},
+		decode: newFlacDecoder,
+	})
+}
+
+// flacDecoder decodes FLAC to float32 samples, whatever the bit depth.
+type flacDecoder struct {
+	floatReader
+	stream   *flac.Stream
+	channels int
+	block    []float32
+	// skip is how many frames of the next block are dropped, when seeking
+	// lands in the middle of it.
+	skip int
+}
+
+func newFlacDecoder(r io.ReadSeeker) (io.ReadSeeker, format, error) {
+	stream, err := flac.NewSeek(r)
+	if err != nil {
+		return nil, format{}, err
+	}
+
+	d := &flacDecoder{
+		stream:   stream,
+		channels: int(stream.Info.NChannels),
+	}
+	d.next = d.decode
+
+	return d, format{
+		rate:     int(stream.Info.SampleRate),
+		channels: d.channels,
+		float:    true,
+	}, nil
+}
+
+func (d *flacDecoder) decode() ([]float32, error) {
+	f, err := d.stream.ParseNext()
+	if err != nil {
+		return nil, err
+	}
+
+	scale := float32(int64(1) << (f.BitsPerSample - 1))
+	samples := int(f.BlockSize)
+
+	d.block = d.block[:0]
+	for i := min(d.skip, samples); i < samples; i++ {
+		for _, sub := range f.Subframes {
+			d.block = append(d.block, float32(sub.Samples[i])/scale)
+		}
+	}
+
+	d.skip = 0
+
+	return d.block, nil
+}
+
+// Length returns the size of the stream in bytes.
+func (d *flacDecoder) Length() int64 {
+	return int64(d.stream.Info.NSamples) * int64(d.channels*4)
+}
+
+func (d *flacDecoder) Seek(offset int64, whence int) (int64, error) {
+	target, err := sample(offset, whence, d.channels)
+	if err != nil {
+		return 0, err
+	}
+
+	// Seeking lands on the start of the frame holding the sample.
+	start, err := d.stream.Seek(uint64(target))
+	if err != nil {
+		return 0, err
+	}
+
+	d.pending = nil
+	d.skip = int(uint64(target) - start)
+
+	return offset, nil
+}
@@ -0,0 +1,127 @@
+package player
+
+import (
+	"math"
+	"time"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/super"
+)
+
+const gainKey = super.Setting + "replaygain"
+
+// GainMode is which ReplayGain value is applied.
+type GainMode int
+
+const (
+	GainOff GainMode = iota
+	GainTrack
+	GainAlbum
+)
+
+func (m GainMode) String() string {
+	switch m {
+	case GainTrack:
+		return "track"
+	case GainAlbum:
+		return "album"
+	default:
+		return "off"
+	}
+}
+
+// ParseGainMode returns the GainMode named s, GainOff if there is none.
+func ParseGainMode(s string) GainMode {
+	switch s {
+	case GainTrack.String():
+		return GainTrack
+	case GainAlbum.String():
+		return GainAlbum
+	default:
+		return GainOff
+	}
+}
+
+// Gain is how loudness is normalised.
+type Gain struct {
+	Mode GainMode
+	// Preamp is added to the ReplayGain of every file, in dB.
+	Preamp float64
+	// Fallback is the gain of files without ReplayGain, in dB.
+	Fallback float64
+}
+
+// factor returns the linear gain for file. It is kept low enough that
+// the peak of the file doesn't clip, if the peak is known.
+func (g Gain) factor(file *api.File) float32 {
+	if g.Mode == GainOff {
+		return 1
+	}
+
+	rg := file.GetReplayGain()
+	if rg == nil {
+		return float32(decibels(g.Preamp + g.Fallback))
+	}
+
+	gain, peak := rg.TrackGain, rg.TrackPeak
+	if g.Mode == GainAlbum {
+		gain, peak = rg.AlbumGain, rg.AlbumPeak
+	}
+
+	factor := decibels(g.Preamp + gain)
+	if peak > 0 {
+		factor = min(factor, 1/peak)
+	}
+
+	return float32(factor)
+}
+
+func decibels(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+const (
+	// ceiling is the highest level the limiter lets through, about -0.3dBFS.
+	ceiling = 0.966
+	// release is how long the limiter takes to recover.
+	release = 200 * time.Millisecond
+)
+
+// limiter keeps samples under the ceiling. It reacts to a peak at once
+// and lets go of it slowly, so the gain never jumps up.
+type limiter struct {
+	gain float32
+	// recover is how much the gain moves back towards 1 per frame.
+	recover float32
+}
+
+func newLimiter() *limiter {
+	return &limiter{
+		gain:    1,
+		recover: float32(1 - math.Exp(-1/(release.Seconds()*sampleRate))),
+	}
+}
+
+// process applies gain to interleaved stereo samples and limits them.
+func (l *limiter) process(samples []float32, gain float32) {
+	for i := 0; i+1 < len(samples); i += 2 {
+		left, right := samples[i]*gain, samples[i+1]*gain
+
+		peak := max(abs(left), abs(right))
+		if peak*l.gain > ceiling {
+			l.gain = ceiling / peak
+		}
+
+		samples[i], samples[i+1] = left*l.gain, right*l.gain
+
+		l.gain += (1 - l.gain) * l.recover
+	}
+}
+
+func abs(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
@@ -0,0 +1,27 @@
+package player
+
+import (
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+func init() {
+	register(&decoder{
+		name:       "mp3",
+		extensions: []string{".mp3"},
+		sniff: func(header []byte) bool {
+			// A frame sync, after the ID3 tag if there was one.
+			return len(header) > 1 && header[0] == 0xff && header[1]&0xe0 == 0xe0
+		},
+		decode: func(r io.ReadSeeker) (io.ReadSeeker, format, error) {
+			d, err := mp3.NewDecoder(r)
+			if err != nil {
+				return nil, format{}, err
+			}
+
+			// go-mp3 always decodes to 16bit stereo.
+			return d, format{rate: d.SampleRate(), channels: 2}, nil
+		},
+	})
+}
@@ -0,0 +1,296 @@
+package player
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/jfreymuth/oggvorbis"
+	"github.com/pion/opus"
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+func init() {
+	register(&decoder{
+		name:       "vorbis",
+		extensions: []string{".ogg", ".oga"},
+		sniff: func(header []byte) bool {
+			return bytes.HasPrefix(oggPacket(header), []byte("\x01vorbis"))
+		},
+		decode: newVorbisDecoder,
+	})
+
+	register(&decoder{
+		name:       "opus",
+		extensions: []string{".opus"},
+		sniff: func(header []byte) bool {
+			return bytes.HasPrefix(oggPacket(header), []byte("OpusHead"))
+		},
+		decode: newOpusDecoder,
+	})
+}
+
+// oggPacket returns the start of the first packet of an Ogg stream, which
+// tells what codec it carries.
+func oggPacket(header []byte) []byte {
+	if len(header) < 27 || !bytes.HasPrefix(header, []byte("OggS")) {
+		return nil
+	}
+
+	// The page header is followed by its segment table.
+	start := 27 + int(header[26])
+	if start > len(header) {
+		return nil
+	}
+
+	return header[start:]
+}
+
+// vorbisOrder maps the channels of a Vorbis stream to the WAV order the
+// converter downmixes, by channel count. -1 is a silent channel.
+var vorbisOrder = map[int][]int{
+	3: {0, 2, 1},
+	4: {0, 1, -1, -1, 2, 3},
+	5: {0, 2, 1, -1, 3, 4},
+	6: {0, 2, 1, 5, 3, 4},
+	// The rear centre is dropped.
+	7: {0, 2, 1, 6, 3, 4},
+	8: {0, 2, 1, 7, 5, 6, 3, 4},
+}
+
+// vorbisDecoder decodes Ogg Vorbis to float32 samples.
+type vorbisDecoder struct {
+	floatReader
+	reader *oggvorbis.Reader
+	order  []int
+	buffer []float32
+	block  []float32
+}
+
+func newVorbisDecoder(r io.ReadSeeker) (io.ReadSeeker, format, error) {
+	reader, err := oggvorbis.NewReader(r)
+	if err != nil {
+		return nil, format{}, err
+	}
+
+	d := &vorbisDecoder{
+		reader: reader,
+		order:  vorbisOrder[reader.Channels()],
+		buffer: make([]float32, chunk*reader.Channels()),
+	}
+	d.next = d.decode
+
+	return d, format{
+		rate:     reader.SampleRate(),
+		channels: d.channels(),
+		float:    true,
+	}, nil
+}
+
+func (d *vorbisDecoder) decode() ([]float32, error) {
+	n, err := d.reader.Read(d.buffer)
+	if d.order == nil {
+		return d.buffer[:n], err
+	}
+
+	in := d.reader.Channels()
+	d.block = d.block[:0]
+	for i := 0; i+in <= n; i += in {
+		for _, c := range d.order {
+			var v float32
+			if c >= 0 {
+				v = d.buffer[i+c]
+			}
+
+			d.block = append(d.block, v)
+		}
+	}
+
+	return d.block, err
+}
+
+// channels returns the channel count of the output, after reordering.
+func (d *vorbisDecoder) channels() int {
+	if d.order != nil {
+		return len(d.order)
+	}
+
+	return d.reader.Channels()
+}
+
+// Length returns the size of the stream in bytes.
+func (d *vorbisDecoder) Length() int64 {
+	return d.reader.Length() * int64(d.channels()*4)
+}
+
+func (d *vorbisDecoder) Seek(offset int64, whence int) (int64, error) {
+	target, err := sample(offset, whence, d.channels())
+	if err != nil {
+		return 0, err
+	}
+
+	err = d.reader.SetPosition(target)
+	if err != nil {
+		return 0, err
+	}
+
+	d.pending = nil
+
+	return offset, nil
+}
+
+// Opus always runs at 48kHz.
+const opusRate = 48000
+
+// opusPreroll is how long the decoder runs before the position seeking
+// lands on, so its output has settled by then.
+const opusPreroll = opusRate * 80 / 1000
+
+// opusFrame is the longest a packet can decode to, 120ms.
+const opusFrame = opusRate * 120 / 1000
+
+// opusDecoder decodes Ogg Opus to float32 samples. Only mono and stereo
+// streams are supported.
+type opusDecoder struct {
+	floatReader
+	src      io.ReadSeeker
+	reader   *oggreader.OggReader
+	decoder  opus.Decoder
+	channels int
+	preSkip  int64
+	gain     float32
+	block    []float32
+	// position is the sample the next packet starts at, counting the
+	// pre-skip.
+	position int64
+	// skip is how many samples are dropped before output starts.
+	skip int64
+}
+
+func newOpusDecoder(r io.ReadSeeker) (io.ReadSeeker, format, error) {
+	d := &opusDecoder{src: r}
+
+	header, err := d.open()
+	if err != nil {
+		return nil, format{}, err
+	}
+
+	d.channels = int(header.Channels)
+	d.preSkip = int64(header.PreSkip)
+	d.skip = d.preSkip
+	// The output gain is in Q7.8 dB.
+	d.gain = float32(math.Pow(10, float64(int16(header.OutputGain))/(20*256)))
+	d.block = make([]float32, opusFrame*d.channels)
+	d.next = d.decode
+
+	d.decoder, err = opus.NewDecoderWithOutput(opusRate, d.channels)
+	if err != nil {
+		return nil, format{}, err
+	}
+
+	return d, format{
+		rate:     opusRate,
+		channels: d.channels,
+		float:    true,
+	}, nil
+}
+
+// open starts reading the stream from the beginning, past the headers.
+func (d *opusDecoder) open() (*oggreader.OggHeader, error) {
+	_, err := d.src.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, header, err := oggreader.NewWith(d.src)
+	if err != nil {
+		return nil, err
+	}
+
+	// The comment header.
+	_, _, err = reader.ParseNextPacket()
+	if err != nil {
+		return nil, err
+	}
+
+	d.reader = reader
+	d.position = 0
+
+	return header, nil
+}
+
+func (d *opusDecoder) decode() ([]float32, error) {
+	packet, _, err := d.reader.ParseNextPacket()
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return d.decodePacket(packet)
+}
+
+// decodePacket decodes packet, dropping the samples that are skipped.
+func (d *opusDecoder) decodePacket(packet []byte) ([]float32, error) {
+	n, err := d.decoder.DecodeToFloat32(packet, d.block)
+	if err != nil {
+		return nil, err
+	}
+
+	d.position += int64(n)
+
+	skip := min(int64(n), d.skip)
+	d.skip -= skip
+
+	block := d.block[int(skip)*d.channels : n*d.channels]
+	if d.gain != 1 {
+		for i := range block {
+			block[i] *= d.gain
+		}
+	}
+
+	return block, nil
+}
+
+func (d *opusDecoder) Seek(offset int64, whence int) (int64, error) {
+	target, err := sample(offset, whence, d.channels)
+	if err != nil {
+		return 0, err
+	}
+
+	target += d.preSkip
+
+	_, err = d.open()
+	if err != nil {
+		return 0, err
+	}
+
+	// Packets ending on pages well before the target are skipped without
+	// decoding. Every packet of the first page that isn't skipped is
+	// decoded, so the page before tells where decoding starts.
+	for {
+		packet, page, err := d.reader.ParseNextPacket()
+		if err != nil {
+			return 0, err
+		}
+
+		if int64(page.GranulePosition) >= target-opusPreroll {
+			err = d.decoder.Init(opusRate, d.channels)
+			if err != nil {
+				return 0, err
+			}
+
+			d.skip = target - d.position
+			d.pending, err = d.decodePacket(packet)
+			if err != nil {
+				return 0, err
+			}
+
+			return offset, nil
+		}
+
+		d.position = int64(page.GranulePosition)
+	}
+}
@@ -2,54 +2,152 @@ package player
 
 import (
 	"bytes"
-	"context"
-	"crypto/sha256"
+	"encoding/gob"
 	"errors"
-	"fmt"
 	"io"
-	"io/fs"
-	"log"
 	"log/slog"
-	"os"
-	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/bh90210/super/cache"
 	"github.com/bh90210/super/server/api"
-	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
 	"github.com/ebitengine/oto/v3"
-	"github.com/hajimehoshi/ebiten/v2/audio/wav"
-	"github.com/hajimehoshi/go-mp3"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
+// State is what the player is doing.
+type State int
+
+const (
+	// Idle is the state before anything was played.
+	Idle State = iota
+	Loading
+	Playing
+	Paused
+	// Stopped is the state after Stop or once the last track ended.
+	Stopped
+	// Error is the state after a track failed to load.
+	Error
+)
+
+func (s State) String() string {
+	switch s {
+	case Loading:
+		return "loading"
+	case Playing:
+		return "playing"
+	case Paused:
+		return "paused"
+	case Stopped:
+		return "stopped"
+	case Error:
+		return "error"
+	default:
+		return "idle"
+	}
+}
+
+// Event is sent to subscribers on every state change and, while playing,
+// every tick with the position.
+type Event struct {
+	State State
+	// File is the current track, nil once the last track ended.
+	File     *api.File
+	Position time.Duration
+	Duration time.Duration
+	// Err is set in the Error state.
+	Err error
+}
+
+// tick is how often position events are sent while playing.
+const tick = 200 * time.Millisecond
+
 // Player .
 type Player struct {
-	Oto *oto.Player
-
+	// oto is the one long-lived oto player, every track is fed through it.
+	oto    *oto.Player
 	otoCtx *oto.Context
 	logger *slog.Logger
-
-	metadata Meta
-	mu       sync.RWMutex
+	cache  *cache.Cache
+	db     *badger.DB
+	// conn downloads the tracks that aren't cached.
+	conn *grpc.ClientConn
+
+	source      *source
+	transitions chan *api.File
+	// callbacks of the source run in order on their own goroutine, oto
+	// may read the source while the player holds its locks.
+	callbacks chan func()
+	// queued is the file that should play after the current one and
+	// next its prefetched track, once loaded.
+	queued *api.File
+	next   *track
+	mu     sync.Mutex
+
+	state     State
+	file      *api.File
+	volume    float64
+	gain      Gain
+	crossfade time.Duration
+	// device is the output device and dsp its settings.
+	device      string
+	dsp         DSP
+	subscribers []chan Event
+	// generation changes with every New so a load that was overtaken
+	// doesn't replace the newer track.
+	generation int
+	stateMu    sync.Mutex
 }
 
-func (p *Player) Init(logger *slog.Logger) error {
-	err := os.Mkdir(super.LocalStorage(super.MusicStore), 0755)
-	if err != nil && !errors.Is(err, fs.ErrExist) {
-		log.Fatal("creating local cache", err)
+func (p *Player) Init(logger *slog.Logger, cache *cache.Cache, db *badger.DB, conn *grpc.ClientConn) error {
+	p.logger = logger
+	p.cache = cache
+	p.db = db
+	p.conn = conn
+	p.volume = 1
+
+	err := p.setting(gainKey, &p.gain)
+	if err != nil {
+		p.logger.Error("loading replaygain settings", "error", err)
+		return err
 	}
 
-	p.logger = logger
+	err = p.setting(crossfadeKey, &p.crossfade)
+	if err != nil {
+		p.logger.Error("loading crossfade settings", "error", err)
+		return err
+	}
+
+	p.device = defaultDevice
+	err = p.setting(deviceKey, &p.device)
+	if err != nil {
+		p.logger.Error("loading output device", "error", err)
+		return err
+	}
+
+	// The stream oto opens goes to the device picked last time, if it's
+	// still there. It isn't open yet, so there is nothing to restart.
+	err = switchDevice(p.device)
+	if err != nil && !errors.Is(err, ErrRestart) {
+		p.logger.Warn("output device unavailable", "device", p.device, "error", err)
+		p.device = defaultDevice
+	}
+
+	p.dsp, err = p.loadDSP(p.device)
+	if err != nil {
+		p.logger.Error("loading dsp settings", "error", err)
+		return err
+	}
 
 	op := &oto.NewContextOptions{}
-	op.SampleRate = 44100
-	op.ChannelCount = 2
-	op.Format = oto.FormatSignedInt16LE
+	op.SampleRate = sampleRate
+	op.ChannelCount = channels
+	op.Format = oto.FormatFloat32LE
 
 	otoCtx, readyChan, err := oto.NewContext(op)
 	if err != nil {
-		p.logger.Error("oto.NewContext failed", err)
+		p.logger.Error("oto.NewContext failed", "error", err)
 		return err
 	}
 
@@ -57,273 +155,488 @@ func (p *Player) Init(logger *slog.Logger) error {
 
 	<-readyChan
 
+	p.transitions = make(chan *api.File, 16)
+	p.callbacks = make(chan func(), 16)
+	p.source = &source{
+		transition: func(t *track) {
+			p.callbacks <- func() { p.transition(t) }
+		},
+		end: func() {
+			p.callbacks <- p.end
+		},
+	}
+	p.source.setCrossfade(p.crossfade)
+	p.source.setChain(p.dsp.chain())
+	p.oto = p.otoCtx.NewPlayer(p.source)
+
+	go func() {
+		for callback := range p.callbacks {
+			callback()
+		}
+	}()
+
+	go p.tick()
+
 	p.logger.Info("oto context ready")
 
 	return nil
 }
 
-func (p *Player) New(track string, volume float64, offset int64) {
-	if p.Oto != nil {
-		if p.Oto.IsPlaying() {
-			p.Oto.Pause()
+// setting decodes the setting stored under key into v, leaving v as it is
+// if it was never set.
+func (p *Player) setting(key string, v any) error {
+	return p.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
 		}
 
-		p.Oto.Close()
-	}
+		if err != nil {
+			return err
+		}
 
-	h := sha256.New()
-	h.Write([]byte(track))
-	hashed := h.Sum(nil)
-	hashedTrack := fmt.Sprintf("%x", hashed)
+		return item.Value(func(b []byte) error {
+			return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+		})
+	})
+}
 
-	raw, err := os.ReadFile(super.LocalStorage(super.MusicStore, super.Storage(hashedTrack)))
-	if err != nil && !errors.Is(err, fs.ErrNotExist) {
-		p.logger.Error("os.Open failed", "error", err)
-		return
+// New starts playing file right away, replacing the current track. If the
+// track can't be played the player goes to the Error state and the
+// *TrackError is returned.
+func (p *Player) New(file *api.File) error {
+	p.stateMu.Lock()
+	if p.state == Playing {
+		p.fade(p.volume, 0)
 	}
 
+	p.oto.Pause()
+	p.generation++
+	generation := p.generation
+	p.setLocked(Loading, file, nil)
+	p.stateMu.Unlock()
+
+	// Use the prefetched track if it's the one asked for.
 	p.mu.Lock()
-	p.metadata.Format = filepath.Ext(track)
-	p.metadata.Path = track
-	p.metadata.streamer = &streamer{Reader: bytes.NewReader(raw)}
+	t := p.next
+	p.queued, p.next = nil, nil
 	p.mu.Unlock()
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-	if errors.Is(err, fs.ErrNotExist) {
-		fmt.Println("downloading track", track)
-
-		p.metadata.streamer.download = true
-		p.metadata.Download = true
+	p.source.queue(nil)
 
-		raw = make([]byte, 0)
+	if t == nil || t.file.Path != file.Path {
+		if t != nil {
+			t.close()
+		}
 
-		conn, err := grpc.NewClient(super.SuperServer,
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
-		)
+		var err error
+		t, err = p.load(file)
 		if err != nil {
-			p.logger.Error("grpc.NewClient", "error", err)
-			return
+			position, duration := p.Position(), p.Duration()
+
+			p.stateMu.Lock()
+			defer p.stateMu.Unlock()
+
+			// Don't fail the track asked for while this one was loading.
+			if p.generation != generation {
+				return nil
+			}
+
+			p.change(Error, file, err, position, duration)
+			return err
 		}
+	}
 
-		client := api.NewLibraryClient(conn)
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
 
-		response, err := client.Download(context.Background(), &api.DownloadRequest{
-			Path: track,
-		})
-		if err != nil {
-			p.logger.Error("client.Download", "error", err)
-			return
+	// Another track was asked for while this one was loading.
+	if p.generation != generation {
+		t.close()
+		return nil
+	}
+
+	p.source.play(t)
+
+	// Drop whatever oto buffered from the previous track.
+	p.oto.Reset()
+	p.oto.SetVolume(p.volume)
+	p.oto.Play()
+
+	p.setLocked(Playing, file, nil)
+
+	return nil
+}
+
+// Play resumes a paused track, or plays a stopped one from the start.
+func (p *Player) Play() error {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	switch p.state {
+	case Paused:
+
+	case Stopped:
+		// Once the last track ended there is nothing left to play.
+		if p.file == nil {
+			return errNoTrack
 		}
 
-		go func(conn *grpc.ClientConn, response api.Library_DownloadClient) {
-			defer conn.Close()
+	default:
+		return nil
+	}
 
-			p.metadata.streamer.file, err = os.CreateTemp(os.TempDir(), "super-")
-			if err != nil {
-				p.logger.Error("os.stream failed", "error", err)
-				return
-			}
+	p.oto.SetVolume(0)
+	p.oto.Play()
+	p.fade(0, p.volume)
 
-			storedFile, err := os.Create(super.LocalStorage(super.MusicStore, super.Storage(hashedTrack)))
-			if err != nil {
-				p.logger.Error("os.Create failed", "error", err)
-				return
-			}
+	p.setLocked(Playing, p.file, nil)
 
-			var mu sync.Mutex
-			var writeIndex int
-			var releaseCounter int
-			for {
-				releaseCounter++
-				if releaseCounter == 3 {
-					wg.Done()
-				}
-
-				data, err := response.Recv()
-				if err != nil && !errors.Is(err, io.EOF) {
-					p.logger.Error("response.Recv failed", "error", err)
-					return
-				}
-
-				if data != nil {
-					mu.Lock()
-					n, err := p.metadata.streamer.file.WriteAt(data.Data, int64(writeIndex))
-					if err != nil {
-						p.logger.Error("file.WriteAt failed", "error", err)
-						return
-					}
-
-					err = p.metadata.streamer.file.Sync()
-					if err != nil {
-						p.logger.Error("file.Sync failed", "error", err)
-						return
-					}
-					mu.Unlock()
-
-					writeIndex += n
-
-					_, err = storedFile.Write(data.Data)
-					if err != nil {
-						p.logger.Error("file.WriteAt failed", "error", err)
-						return
-					}
-				}
-
-				if errors.Is(err, io.EOF) {
-					err = storedFile.Sync()
-					if err != nil {
-						p.logger.Error("file.Sync failed", "error", err)
-						return
-					}
-
-					storedFile.Close()
-
-					p.mu.Lock()
-					p.metadata.streamer.finished = true
-					p.mu.Unlock()
-					break
-				}
-			}
-		}(conn, response)
-	} else {
-		wg.Done()
+	return nil
+}
+
+// Pause pauses the current track.
+func (p *Player) Pause() {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	if p.state != Playing {
+		return
 	}
 
-	wg.Wait()
+	p.fade(p.volume, 0)
+	p.oto.Pause()
+	p.oto.SetVolume(p.volume)
 
-	var newPlayer *oto.Player
-	switch p.metadata.Format {
-	case ".mp3":
-		decodedMp3, err := mp3.NewDecoder(p.metadata.streamer)
-		if err != nil {
-			p.logger.Error("mp3.NewDecoder failed", "error", err)
+	p.setLocked(Paused, p.file, nil)
+}
+
+// Stop pauses the current track and rewinds it.
+func (p *Player) Stop() {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	if p.state != Playing && p.state != Paused {
+		return
+	}
+
+	if p.state == Playing {
+		p.fade(p.volume, 0)
+	}
+
+	p.oto.Pause()
+	p.oto.SetVolume(p.volume)
+
+	// Seeking resets oto, which leaves it paused.
+	_, err := p.oto.Seek(0, io.SeekStart)
+	if err != nil && !errors.Is(err, errNoTrack) {
+		p.logger.Error("oto.Seek failed", "error", err)
+	}
+
+	p.setLocked(Stopped, p.file, nil)
+}
+
+// SetVolume sets the volume in the range of [0, 1].
+func (p *Player) SetVolume(volume float64) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	p.volume = volume
+	p.oto.SetVolume(volume)
+}
+
+// SetGain sets how loudness is normalised, starting with the current track.
+func (p *Player) SetGain(gain Gain) error {
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(gain)
+	if err != nil {
+		p.logger.Error("gob.Encode", "error", err)
+		return err
+	}
+
+	err = p.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(gainKey), buf.Bytes())
+	})
+	if err != nil {
+		p.logger.Error("badger.Set", "error", err)
+		return err
+	}
+
+	p.stateMu.Lock()
+	p.gain = gain
+	p.stateMu.Unlock()
+
+	p.source.regain(gain.factor)
+
+	return nil
+}
+
+// Gain returns how loudness is normalised.
+func (p *Player) Gain() Gain {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	return p.gain
+}
+
+// State returns what the player is doing.
+func (p *Player) State() State {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	return p.state
+}
+
+// Subscribe returns a channel that receives the events of the player.
+// Events are dropped for subscribers that don't keep up.
+func (p *Player) Subscribe() <-chan Event {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	events := make(chan Event, 16)
+	p.subscribers = append(p.subscribers, events)
+
+	return events
+}
+
+// Unsubscribe stops sending events to a channel returned by Subscribe
+// and closes it.
+func (p *Player) Unsubscribe(events <-chan Event) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	for i, subscriber := range p.subscribers {
+		if subscriber == events {
+			p.subscribers = append(p.subscribers[:i], p.subscribers[i+1:]...)
+			close(subscriber)
+			return
 		}
+	}
+}
 
-		newPlayer = p.otoCtx.NewPlayer(decodedMp3)
+// set moves the player to state and lets the subscribers know.
+func (p *Player) set(state State, file *api.File, err error) {
+	// Read before locking, the source can be in the middle of a read.
+	position, duration := p.Position(), p.Duration()
 
-	case ".wav":
-		decodedWav, err := wav.DecodeWithoutResampling(p.metadata.streamer)
-		if err != nil {
-			p.logger.Error("wav.DecodeWithoutResampling failed", "error", err)
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	p.change(state, file, err, position, duration)
+}
+
+// setLocked is set with stateMu held.
+func (p *Player) setLocked(state State, file *api.File, err error) {
+	p.change(state, file, err, p.Position(), p.Duration())
+}
+
+// change moves the player to state and emits it, with stateMu held.
+func (p *Player) change(state State, file *api.File, err error, position, duration time.Duration) {
+	p.state, p.file = state, file
+	p.emit(Event{
+		State:    state,
+		File:     file,
+		Position: position,
+		Duration: duration,
+		Err:      err,
+	})
+}
+
+// emit sends e to every subscriber, with stateMu held.
+func (p *Player) emit(e Event) {
+	for _, subscriber := range p.subscribers {
+		select {
+		case subscriber <- e:
+		default:
 		}
+	}
+}
 
-		newPlayer = p.otoCtx.NewPlayer(decodedWav)
+// tick sends the position while playing.
+func (p *Player) tick() {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Read before locking, the source can be in the middle of a read.
+		position, duration := p.Position(), p.Duration()
+
+		p.stateMu.Lock()
+		if p.state == Playing {
+			p.emit(Event{
+				State:    p.state,
+				File:     p.file,
+				Position: position,
+				Duration: duration,
+			})
+		}
+		p.stateMu.Unlock()
+	}
+}
 
-	default:
-		p.logger.Error("unsupported format", "error", p.metadata.Format)
+// Queue sets the file that plays once the current track ends, without a
+// gap in between. It is prefetched in the background. A nil file clears it.
+func (p *Player) Queue(file *api.File) {
+	p.mu.Lock()
+	if p.queued != nil && file != nil && p.queued.Path == file.Path {
+		p.mu.Unlock()
 		return
 	}
 
-	if p.Oto != nil {
-		if p.Oto.IsPlaying() {
-			p.Oto.Pause()
-		}
+	if p.next != nil {
+		p.next.close()
+	}
+
+	p.queued, p.next = file, nil
+	p.mu.Unlock()
+
+	p.source.queue(nil)
 
-		p.Oto.Close()
+	if file == nil {
+		return
 	}
 
-	p.Oto = newPlayer
-	if offset > 0 {
-		_, err := p.Oto.Seek(offset, io.SeekStart)
+	go func() {
+		t, err := p.load(file)
 		if err != nil {
-			p.logger.Error("p.Oto.Seek failed", "error", err)
 			return
 		}
-	}
 
-	p.Oto.SetVolume(volume)
-	p.Oto.Play()
-}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		// The queue changed while loading.
+		if p.queued != file {
+			t.close()
+			return
+		}
 
-type Meta struct {
-	Size     int
-	Length   int
-	Path     string
-	Format   string
-	Download bool
+		p.next = t
+		p.source.queue(t)
+	}()
+}
 
-	streamer *streamer
+// Transitions returns a channel that receives the queued file when
+// playback moves on to it.
+func (p *Player) Transitions() <-chan *api.File {
+	return p.transitions
 }
 
-func (p *Player) Meta() *Meta {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
+// transition runs once the source moved on to the queued track.
+func (p *Player) transition(t *track) {
+	p.mu.Lock()
+	if p.next == t {
+		p.queued, p.next = nil, nil
+	}
+	p.mu.Unlock()
 
-	return &Meta{
-		Size:     int(p.metadata.streamer.Size()),
-		Length:   p.metadata.streamer.Len(),
-		Format:   p.metadata.Format,
-		Path:     p.metadata.Path,
-		Download: p.metadata.Download,
+	// Something else was played since.
+	current, _, _, _ := p.source.status()
+	if current != t {
+		return
 	}
-}
 
-type streamer struct {
-	*bytes.Reader
-	download bool
-	finished bool
-	file     *os.File
-	size     int64
-	i        int
-	seek     int
-	meta     int
+	p.set(Playing, t.file, nil)
+
+	select {
+	case p.transitions <- t.file:
+	default:
+		p.logger.Warn("track transition dropped", "path", t.file.Path)
+	}
 }
 
-func (s *streamer) Read(p []byte) (n int, err error) {
-	if !s.download {
-		n, err := s.Reader.Read(p)
-		return n, err
+// end runs once the last track ended.
+func (p *Player) end() {
+	// Something else was played since.
+	current, _, _, _ := p.source.status()
+	if current != nil {
+		return
 	}
 
-	n, err = s.file.Read(p)
-	s.i += n
-	return n, err
+	p.set(Stopped, nil, nil)
 }
 
-func (s *streamer) Seek(offset int64, whence int) (int64, error) {
-	s.seek++
+// SeekTo moves playback of the current track to position. Seeking a track
+// that is still downloading waits for the download to be over.
+func (p *Player) SeekTo(position time.Duration) error {
+	t, _, duration, partial := p.source.status()
+	if t == nil {
+		return errNoTrack
+	}
 
-	if s.seek == 3 {
-		slog.Info("seeking to", "offset", offset)
-		s.meta = int(offset)
+	position = max(0, position)
+	if duration > 0 {
+		position = min(position, duration)
 	}
 
-	if s.download {
-		n, err := s.file.Seek(offset, whence)
+	offset := toOffset(position)
 
-		if whence == io.SeekStart {
-			s.i = int(n)
+	if partial {
+		streamer, decoder, err := p.complete(t)
+		if errors.Is(err, errAbandoned) {
+			return nil
 		}
 
-		return n, err
+		if err != nil {
+			return err
+		}
+
+		// Seek before swapping so not a frame of the start is heard.
+		_, err = decoder.Seek(offset, io.SeekStart)
+		if err != nil {
+			streamer.close()
+			return err
+		}
+
+		if !p.source.replace(t, streamer, decoder) {
+			// The track ended in the meantime.
+			streamer.close()
+			return nil
+		}
 	}
 
-	return s.Reader.Seek(offset, whence)
-}
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
 
-func (s *streamer) Size() int64 {
-	if s.download && s.finished {
-		if s.size == 0 {
-			i, err := s.file.Stat()
-			if err != nil {
-				slog.Error("file.Stat failed", "error", err)
-				return 0
-			}
+	// Fade around the jump so it doesn't click.
+	playing := p.state == Playing
+	if playing {
+		p.fade(p.volume, 0)
+	}
 
-			s.size = i.Size()
-		}
+	// This drops what oto has buffered too.
+	_, err := p.oto.Seek(offset, io.SeekStart)
 
-		return s.size - int64(s.meta)
+	if playing {
+		p.fade(0, p.volume)
 	}
 
-	return s.Reader.Size() - int64(s.meta)
+	if err != nil {
+		return err
+	}
+
+	p.emit(Event{
+		State:    p.state,
+		File:     p.file,
+		Position: position,
+		Duration: duration,
+	})
+
+	return nil
 }
 
-func (s *streamer) Len() int {
-	if s.download {
-		return int(s.size) - s.i
-	}
+// Position returns how far into the current track playback is.
+func (p *Player) Position() time.Duration {
+	_, pos, _, _ := p.source.status()
+
+	// What oto buffered hasn't been heard yet.
+	pos -= int64(p.oto.BufferedSize())
+
+	return toDuration(max(0, pos))
+}
 
-	return s.Reader.Len()
+// Duration returns the length of the current track, or 0 if unknown.
+func (p *Player) Duration() time.Duration {
+	_, _, duration, _ := p.source.status()
+	return duration
 }
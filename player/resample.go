@@ -0,0 +1,126 @@
+package player
+
+import "math"
+
+const (
+	// zeroCrossings is how many zero crossings of the sinc the kernel
+	// spans on each side, more is sharper and slower.
+	zeroCrossings = 32
+	// phases is how many kernel values are kept per input sample,
+	// the ones in between are interpolated.
+	phases = 256
+	// rolloff keeps the cutoff a bit under the Nyquist frequency
+	// to leave room for the transition band.
+	rolloff = 0.95
+)
+
+// resampler converts the sample rate of interleaved stereo samples
+// with a Blackman windowed sinc filter.
+type resampler struct {
+	// step is how many input frames there are per output frame.
+	step float64
+	// width is half the length of the kernel in input frames.
+	width  float64
+	kernel []float64
+	input  []float32
+	// offset is the position of the next output frame in input.
+	offset float64
+}
+
+func newResampler(from, to int) *resampler {
+	cutoff := min(1, float64(to)/float64(from)) * rolloff
+
+	r := &resampler{
+		step:  float64(from) / float64(to),
+		width: zeroCrossings / cutoff,
+	}
+
+	r.kernel = make([]float64, int(r.width*phases)+2)
+	for i := range r.kernel {
+		x := float64(i) / phases
+		r.kernel[i] = cutoff * sinc(cutoff*x) * blackman(x/r.width)
+	}
+
+	r.reset()
+
+	return r
+}
+
+// reset drops the filter history, for example after seeking.
+func (r *resampler) reset() {
+	// Start from silence so the first output frame is centred
+	// on the first input frame.
+	history := int(math.Ceil(r.width))
+	r.input = make([]float32, 2*history)
+	r.offset = float64(history)
+}
+
+// process resamples in and appends the result to out. Input frames are
+// kept until the kernel has moved past them.
+func (r *resampler) process(in, out []float32) []float32 {
+	r.input = append(r.input, in...)
+	frames := len(r.input) / 2
+
+	for {
+		last := int(math.Floor(r.offset + r.width))
+		if last >= frames {
+			break
+		}
+
+		first := max(0, int(math.Ceil(r.offset-r.width)))
+
+		var left, right float64
+		for k := first; k <= last; k++ {
+			h := r.at(r.offset - float64(k))
+			left += h * float64(r.input[2*k])
+			right += h * float64(r.input[2*k+1])
+		}
+
+		out = append(out, float32(left), float32(right))
+		r.offset += r.step
+	}
+
+	// Drop the frames the kernel won't reach anymore.
+	drop := min(frames, int(math.Ceil(r.offset-r.width)))
+	if drop > 0 {
+		r.input = append(r.input[:0], r.input[2*drop:]...)
+		r.offset -= float64(drop)
+	}
+
+	return out
+}
+
+// flush pushes the remaining input through the filter.
+func (r *resampler) flush(out []float32) []float32 {
+	silence := make([]float32, 2*(int(math.Ceil(r.width))+1))
+	return r.process(silence, out)
+}
+
+// at returns the kernel value x input frames away from its centre.
+func (r *resampler) at(x float64) float64 {
+	x = math.Abs(x) * phases
+	i := int(x)
+	if i+1 >= len(r.kernel) {
+		return 0
+	}
+
+	frac := x - float64(i)
+	return r.kernel[i] + frac*(r.kernel[i+1]-r.kernel[i])
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman is the Blackman window for x in [-1, 1].
+func blackman(x float64) float64 {
+	if math.Abs(x) > 1 {
+		return 0
+	}
+
+	return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+}
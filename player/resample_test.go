@@ -0,0 +1,118 @@
+package player
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// tone returns frames of interleaved stereo, a sine of frequency at rate
+// on the left and the same sine inverted on the right.
+func tone(frequency float64, rate, frames int) []float32 {
+	samples := make([]float32, 2*frames)
+	for i := range frames {
+		v := float32(0.5 * math.Sin(2*math.Pi*frequency*float64(i)/float64(rate)))
+		samples[2*i] = v
+		samples[2*i+1] = -v
+	}
+
+	return samples
+}
+
+// resample runs in through a new resampler in chunks of size frames.
+func resample(from, to int, in []float32, size int) []float32 {
+	r := newResampler(from, to)
+
+	var out []float32
+	for len(in) > 0 {
+		n := min(len(in), 2*size)
+		out = r.process(in[:n], out)
+		in = in[n:]
+	}
+
+	return r.flush(out)
+}
+
+// near reports whether a and b are the same samples, give or take the
+// rounding of where the chunks start.
+func near(a, b []float32) bool {
+	return slices.EqualFunc(a, b, func(a, b float32) bool {
+		return math.Abs(float64(a-b)) < 1e-6
+	})
+}
+
+// amplitude returns the peak of the left channel away from the edges, where
+// the filter starts and stops on silence.
+func amplitude(samples []float32, edge int) float64 {
+	var peak float64
+	for i := 2 * edge; i < len(samples)-2*edge; i += 2 {
+		peak = max(peak, math.Abs(float64(samples[i])))
+	}
+
+	return peak
+}
+
+func TestResampler(t *testing.T) {
+	const frames = 20000
+
+	tests := []struct {
+		name      string
+		from, to  int
+		frequency float64
+		amplitude float64
+	}{
+		{"44.1 to 48 kHz keeps 1 kHz", 44100, 48000, 1000, 0.5},
+		{"48 to 44.1 kHz keeps 1 kHz", 48000, 44100, 1000, 0.5},
+		{"96 to 48 kHz keeps 10 kHz", 96000, 48000, 10000, 0.5},
+		{"22.05 to 48 kHz keeps 5 kHz", 22050, 48000, 5000, 0.5},
+		{"96 to 48 kHz drops 30 kHz", 96000, 48000, 30000, 0},
+		{"88.2 to 44.1 kHz drops 40 kHz", 88200, 44100, 40000, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := resample(test.from, test.to, tone(test.frequency, test.from, frames), frames)
+
+			// Flushing runs the filter a frame past the end.
+			want := frames * test.to / test.from
+			if got := len(out) / 2; got < want || got > want+test.to/test.from+3 {
+				t.Errorf("%d frames out, want %d", got, want)
+			}
+
+			if got := amplitude(out, 2*zeroCrossings*test.to/test.from+2); math.Abs(got-test.amplitude) > 0.01 {
+				t.Errorf("amplitude %.4f, want %.4f", got, test.amplitude)
+			}
+
+			for i := 0; i+1 < len(out); i += 2 {
+				if out[i] != -out[i+1] {
+					t.Fatalf("frame %d is %v, %v, the channels got mixed", i/2, out[i], out[i+1])
+				}
+			}
+		})
+	}
+}
+
+func TestResamplerChunks(t *testing.T) {
+	in := tone(440, 44100, 5000)
+	want := resample(44100, 48000, in, len(in))
+
+	for _, size := range []int{1, 7, 64, 1000, 4999} {
+		if got := resample(44100, 48000, in, size); !near(got, want) {
+			t.Errorf("in chunks of %d frames the output differs", size)
+		}
+	}
+}
+
+func TestResamplerReset(t *testing.T) {
+	in := tone(440, 44100, 5000)
+	want := resample(44100, 48000, in, len(in))
+
+	r := newResampler(44100, 48000)
+	r.process(tone(1000, 44100, 1234), nil)
+	r.reset()
+
+	got := r.flush(r.process(in, nil))
+	if !near(got, want) {
+		t.Error("the output after reset differs from a new resampler's")
+	}
+}
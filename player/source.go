@@ -0,0 +1,296 @@
+package player
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/bh90210/super/server/api"
+)
+
+var errNoTrack = errors.New("no track")
+
+// source is the PCM stream the long-lived oto player reads from. When the
+// current track ends it moves on to the queued one within the same read,
+// so there is no gap between them. With a crossfade set, the queued track
+// starts that long before the current one ends and the two are mixed.
+type source struct {
+	current *track
+	next    *track
+	// crossfade is the length of the crossfade in frames, 0 if off.
+	crossfade int64
+	// fading is set while the current track fades into the next one, over
+	// fadeLen frames of which fadePos are done.
+	fading  bool
+	fadeLen int64
+	fadePos int64
+	samples []float32
+	mix     []float32
+	// chain processes the output once the tracks are mixed.
+	chain chain
+	// transition is called after moving on to the queued track.
+	transition func(*track)
+	// end is called when the last track ends.
+	end func()
+	mu  sync.Mutex
+}
+
+func (s *source) Read(b []byte) (int, error) {
+	s.mu.Lock()
+
+	size := len(b) / frame * channels
+	if cap(s.samples) < size {
+		s.samples = make([]float32, size)
+	}
+
+	samples := s.samples[:size]
+
+	var n int
+	var moved *track
+	var ended bool
+	var err error
+	for n < len(samples) {
+		if s.current == nil {
+			err = io.EOF
+			break
+		}
+
+		m, e := s.read(samples[n:])
+		n += m
+
+		// Any error returned to oto would close the player for good,
+		// a track that fails to decode ends there instead.
+		if e != nil && !errors.Is(e, io.EOF) {
+			slog.Error("decoder.read failed", "path", s.current.file.Path, "error", e)
+			e = io.EOF
+		}
+
+		if errors.Is(e, io.EOF) {
+			s.current.close()
+			s.current, s.next = s.next, nil
+			s.fading = false
+			if s.current != nil {
+				moved = s.current
+			} else {
+				ended = true
+			}
+			continue
+		}
+
+		break
+	}
+
+	s.chain.process(samples[:n])
+
+	for i, v := range samples[:n] {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+
+	s.mu.Unlock()
+
+	if moved != nil && s.transition != nil {
+		s.transition(moved)
+	}
+
+	if ended && s.end != nil {
+		s.end()
+	}
+
+	if n > 0 && errors.Is(err, io.EOF) {
+		return n * 4, nil
+	}
+
+	return n * 4, err
+}
+
+// read reads the current track into out, mixed with the start of the next
+// one while crossfading. It returns io.EOF once the current track is over,
+// which for a crossfade is when it has faded out. It must be called with
+// s.mu held.
+func (s *source) read(out []float32) (int, error) {
+	// The queue changed during the crossfade, the current track plays on.
+	if s.fading && s.next == nil {
+		s.fading = false
+	}
+
+	if !s.fading && s.crossfades() {
+		left := s.left()
+		if left <= s.crossfade {
+			s.fading, s.fadeLen, s.fadePos = true, max(left, 1), 0
+		} else {
+			// Stop right where the crossfade starts.
+			out = out[:min(int64(len(out)), (left-s.crossfade)*channels)]
+		}
+	}
+
+	// Play silence until more is downloaded, rather than wait for the
+	// network with s.mu held.
+	if s.current.streamer.starved() {
+		clear(out)
+		return len(out), nil
+	}
+
+	n, err := s.current.decoder.read(out)
+	if !s.fading || n == 0 {
+		return n, err
+	}
+
+	if cap(s.mix) < n {
+		s.mix = make([]float32, n)
+	}
+
+	mix := s.mix[:n]
+	var m int
+	if !s.next.streamer.starved() {
+		m, _ = s.next.decoder.read(mix)
+	}
+	clear(mix[m:])
+
+	for i := 0; i+1 < n; i += 2 {
+		// Equal power, so the loudness doesn't dip halfway.
+		x := math.Pi / 2 * float64(min(s.fadePos, s.fadeLen)) / float64(s.fadeLen)
+		fadeOut, fadeIn := float32(math.Cos(x)), float32(math.Sin(x))
+
+		out[i] = out[i]*fadeOut + mix[i]*fadeIn
+		out[i+1] = out[i+1]*fadeOut + mix[i+1]*fadeIn
+
+		s.fadePos++
+	}
+
+	// The duration was a little long, what is left of the track is silent
+	// by now anyway.
+	if s.fadePos >= s.fadeLen {
+		return n, io.EOF
+	}
+
+	return n, err
+}
+
+// crossfades reports whether the current track fades into the next one.
+// Tracks of the same album play gapless instead, they often run into each
+// other already. It must be called with s.mu held.
+func (s *source) crossfades() bool {
+	if s.crossfade == 0 || s.next == nil || s.current.duration == 0 {
+		return false
+	}
+
+	return !sameAlbum(s.current.file, s.next.file)
+}
+
+// left returns how many frames of the current track are left to read. It
+// must be called with s.mu held.
+func (s *source) left() int64 {
+	t := s.current
+	return max(0, (toOffset(t.duration)-t.decoder.pos)/frame)
+}
+
+func sameAlbum(a, b *api.File) bool {
+	return a.Album != "" && a.Album == b.Album && a.Artist == b.Artist
+}
+
+// Seek seeks the PCM stream of the current track.
+func (s *source) Seek(offset int64, whence int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == nil {
+		return 0, errNoTrack
+	}
+
+	// The next track starts over once the crossfade comes around again.
+	if s.fading && s.next != nil {
+		_, err := s.next.decoder.Seek(0, io.SeekStart)
+		if err != nil {
+			slog.Error("decoder.Seek failed", "path", s.next.file.Path, "error", err)
+		}
+	}
+
+	s.fading = false
+
+	return s.current.decoder.Seek(offset, whence)
+}
+
+// play replaces the current track with t.
+func (s *source) play(t *track) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current != nil && s.current != t {
+		s.current.close()
+	}
+
+	s.current = t
+	s.fading = false
+}
+
+// queue sets the track that follows the current one.
+func (s *source) queue(t *track) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next = t
+}
+
+// setCrossfade sets the length of the crossfade, 0 turns it off.
+func (s *source) setCrossfade(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.crossfade = toOffset(d) / frame
+}
+
+// setChain replaces the DSP chain.
+func (s *source) setChain(c chain) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chain = c
+}
+
+// status returns the current track, how much of its output has been read,
+// its duration and whether its decoder is partial.
+func (s *source) status() (*track, int64, time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.current
+	if t == nil {
+		return nil, 0, 0, false
+	}
+
+	return t, t.decoder.pos, t.duration, t.partial
+}
+
+// regain sets the gain of the current and the queued track.
+func (s *source) regain(factor func(*api.File) float32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range []*track{s.current, s.next} {
+		if t != nil {
+			t.decoder.gain = factor(t.file)
+		}
+	}
+}
+
+// replace swaps the streamer and decoder of t, if it is still playing.
+func (s *source) replace(t *track, streamer *streamer, decoder *converter) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current != t {
+		return false
+	}
+
+	t.streamer.close()
+	decoder.gain = t.decoder.gain
+	t.streamer, t.decoder = streamer, decoder
+	t.partial = false
+	t.duration = duration(t)
+
+	return true
+}
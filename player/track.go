@@ -0,0 +1,455 @@
+package player
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/super"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// track is a file ready to be played.
+type track struct {
+	file *api.File
+	// format is the name of the decoder.
+	format   string
+	streamer *streamer
+	// decoder is the PCM stream of the track in the output format.
+	decoder  *converter
+	duration time.Duration
+	// partial is set while the decoder only knows the part of the file
+	// that was downloaded when it was created.
+	partial bool
+}
+
+func (t *track) close() {
+	t.streamer.close()
+}
+
+// load reads file from its local folder or the cache. Otherwise it starts
+// downloading it and returns once enough of it is there to start playing.
+// Errors are a *TrackError.
+func (p *Player) load(file *api.File) (*track, error) {
+	path := file.Path
+
+	var raw []byte
+	var err error
+	if super.Local(file.Source) {
+		// Local files are read in place, there is nothing to download.
+		raw, err = os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, trackError(file, ErrNotFound, err)
+		}
+
+		if err != nil {
+			p.logger.Error("os.ReadFile failed", "error", err)
+			return nil, trackError(file, ErrUnavailable, err)
+		}
+	} else {
+		var cached *os.File
+		cached, err = p.cache.Open(file)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			p.logger.Error("cache.Open failed", "error", err)
+			return nil, trackError(file, ErrUnavailable, err)
+		}
+
+		if err == nil {
+			raw, err = io.ReadAll(cached)
+			cached.Close()
+			if err != nil {
+				p.logger.Error("io.ReadAll failed", "error", err)
+				return nil, trackError(file, ErrUnavailable, err)
+			}
+		}
+	}
+
+	t := &track{
+		file:     file,
+		streamer: &streamer{Reader: bytes.NewReader(raw)},
+	}
+
+	if errors.Is(err, fs.ErrNotExist) {
+		p.logger.Debug("downloading track", "path", path)
+
+		t.streamer.download = true
+
+		// Cancelling ends the download, the connection is shared.
+		ctx, cancel := context.WithCancel(context.Background())
+
+		client := api.NewLibraryClient(p.conn)
+
+		response, err := client.Download(ctx, &api.DownloadRequest{
+			Path: path,
+		})
+		if err != nil {
+			p.logger.Error("client.Download", "error", err)
+			cancel()
+			return nil, downloadError(file, err)
+		}
+
+		t.streamer.file, err = p.cache.Create(file)
+		if err != nil {
+			p.logger.Error("cache.Create failed", "error", err)
+			cancel()
+			return nil, trackError(file, ErrUnavailable, err)
+		}
+
+		ready := make(chan struct{})
+		go p.download(t, response, cancel, ready)
+		<-ready
+	}
+
+	t.decoder, t.format, err = decode(t.streamer, path)
+	if err != nil {
+		p.logger.Error("decode failed", "path", path, "error", err)
+		t.close()
+
+		// Nothing to decode because the download failed.
+		failed := t.streamer.failed()
+		if failed != nil {
+			return nil, downloadError(file, failed)
+		}
+
+		if errors.Is(err, ErrUnsupported) {
+			return nil, trackError(file, ErrUnsupported, err)
+		}
+
+		return nil, trackError(file, ErrCorrupt, err)
+	}
+
+	t.partial = t.streamer.download && !t.streamer.done()
+	t.duration = duration(t)
+	t.decoder.gain = p.Gain().factor(file)
+
+	// From now on reads wait for the rest of the download, the decoders
+	// only needed to look at what was there already.
+	t.streamer.follow()
+
+	return t, nil
+}
+
+// downloadError classifies a failed download by its gRPC status.
+func downloadError(file *api.File, err error) *TrackError {
+	if status.Code(err) == codes.NotFound {
+		return trackError(file, ErrNotFound, err)
+	}
+
+	return trackError(file, ErrUnavailable, err)
+}
+
+// decode picks the decoder of the stream and returns its PCM in the
+// output format, along with the name of the format.
+func decode(r io.ReadSeeker, path string) (*converter, string, error) {
+	d, err := decoderFor(r, filepath.Ext(path))
+	if err != nil {
+		return nil, "", err
+	}
+
+	pcm, f, err := d.decode(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", d.name, err)
+	}
+
+	return newConverter(pcm, f), d.name, nil
+}
+
+// duration returns how long the track is. The decoder is asked first but
+// it only knows the part of a file that was there when it was created, so
+// while downloading it is the length the server found instead.
+func duration(t *track) time.Duration {
+	if !t.partial {
+		d := t.decoder.duration()
+		if d > 0 {
+			return d
+		}
+	}
+
+	d, err := time.ParseDuration(t.file.Duration)
+	if err != nil {
+		return 0
+	}
+
+	return d
+}
+
+// complete returns a new decoder for the whole file of a partial track,
+// waiting for its download to be over. Decoders built on part of a file
+// can't seek past it. A download that failed is as complete as it gets,
+// what of it was downloaded can be seeked.
+func (p *Player) complete(t *track) (*streamer, *converter, error) {
+	for !t.streamer.done() {
+		time.Sleep(poll)
+	}
+
+	// The partial file stays open until the track is closed, committed to
+	// the cache or not.
+	t.streamer.mu.Lock()
+	raw := make([]byte, t.streamer.written)
+	_, err := t.streamer.file.ReadAt(raw, 0)
+	t.streamer.mu.Unlock()
+
+	// Another track was played in the meantime.
+	if t.streamer.abandoned() {
+		return nil, nil, errAbandoned
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+
+	s := &streamer{Reader: bytes.NewReader(raw)}
+
+	decoder, _, err := decode(s, t.file.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s, decoder, nil
+}
+
+// download writes the incoming data to the partial file the track is
+// streamed from and commits it to the cache once finished. Ready is closed
+// once there is enough data to start decoding. Cancel ends the stream.
+func (p *Player) download(t *track, response api.Library_DownloadClient, cancel context.CancelFunc, ready chan struct{}) {
+	defer cancel()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() { close(ready) })
+	}
+
+	defer release()
+
+	var writeIndex int
+	var releaseCounter int
+	for {
+		releaseCounter++
+		if releaseCounter == 3 {
+			release()
+		}
+
+		// Stop downloading a track nobody is going to play.
+		if t.streamer.abandoned() {
+			p.cache.Abort(t.streamer.file)
+			return
+		}
+
+		data, err := response.Recv()
+		if err != nil && !errors.Is(err, io.EOF) {
+			// What was downloaded so far can still be played, the partial
+			// file is cleaned up by the cache on the next start.
+			p.logger.Error("response.Recv failed", "error", err)
+			t.streamer.fail(err)
+			return
+		}
+
+		if data != nil {
+			n, err := t.streamer.file.WriteAt(data.Data, int64(writeIndex))
+			if err != nil {
+				p.logger.Error("file.WriteAt failed", "error", err)
+				t.streamer.finish()
+				return
+			}
+
+			writeIndex += n
+			t.streamer.wrote(n)
+		}
+
+		if errors.Is(err, io.EOF) {
+			// A download that doesn't match its checksum still plays, it
+			// is only not kept.
+			err = p.cache.Commit(t.file, t.streamer.file)
+			if err != nil {
+				p.logger.Error("cache.Commit failed", "error", err)
+			}
+
+			t.streamer.finish()
+			return
+		}
+	}
+}
+
+const (
+	// poll is how often a read waits for more data while downloading.
+	poll = 20 * time.Millisecond
+	// stall is how long a read waits for more data before giving up on
+	// the download.
+	stall = 2 * time.Second
+	// readAhead is how much has to be downloaded past what was read for
+	// the decoders to read on without waiting. Until then the track plays
+	// silence.
+	readAhead = 256 << 10
+)
+
+var errStalled = errors.New("download stalled")
+
+// errAbandoned is returned for a track closed while its download was
+// waited for.
+var errAbandoned = errors.New("track abandoned")
+
+type streamer struct {
+	*bytes.Reader
+	download bool
+	finished bool
+	closed   bool
+	// wait makes reads wait for more data while downloading.
+	wait bool
+	// err is why the download stopped early.
+	err error
+	// written is how much was downloaded, offset how much was read.
+	written int64
+	offset  int64
+	file    *os.File
+	mu      sync.Mutex
+}
+
+func (s *streamer) Read(p []byte) (n int, err error) {
+	if !s.download {
+		n, err := s.Reader.Read(p)
+		return n, err
+	}
+
+	var waited time.Duration
+	for {
+		n, err = s.file.Read(p)
+
+		s.mu.Lock()
+		s.offset += int64(n)
+		s.mu.Unlock()
+
+		// Wait for more data unless the download is over.
+		if errors.Is(err, io.EOF) && n == 0 && s.waiting() {
+			if waited >= stall {
+				return 0, errStalled
+			}
+
+			time.Sleep(poll)
+			waited += poll
+			continue
+		}
+
+		if errors.Is(err, io.EOF) && n > 0 {
+			err = nil
+		}
+
+		return n, err
+	}
+}
+
+func (s *streamer) Seek(offset int64, whence int) (int64, error) {
+	if !s.download {
+		return s.Reader.Seek(offset, whence)
+	}
+
+	offset, err := s.file.Seek(offset, whence)
+	if err == nil {
+		s.mu.Lock()
+		s.offset = offset
+		s.mu.Unlock()
+	}
+
+	return offset, err
+}
+
+// wrote adds n bytes to what was downloaded.
+func (s *streamer) wrote(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.written += int64(n)
+}
+
+// starved reports whether too little was downloaded past what was read
+// for a decoder to read on without waiting for the network.
+func (s *streamer) starved() bool {
+	if !s.download {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.wait && !s.finished && !s.closed && s.written-s.offset < readAhead
+}
+
+// done reports whether the download is over, or abandoned.
+func (s *streamer) done() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.finished || s.closed
+}
+
+// waiting reports whether reads should wait for more data.
+func (s *streamer) waiting() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.wait && !s.finished && !s.closed
+}
+
+func (s *streamer) follow() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.wait = true
+}
+
+func (s *streamer) abandoned() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+// fail marks the download as over because of err.
+func (s *streamer) fail(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+
+	s.finish()
+}
+
+func (s *streamer) failed() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// finish marks the download as over.
+func (s *streamer) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.finished = true
+	if s.closed {
+		s.file.Close()
+	}
+}
+
+// close releases the partial file once the download is over.
+func (s *streamer) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	if s.download && s.finished {
+		s.file.Close()
+	}
+}
@@ -0,0 +1,126 @@
+package player
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bh90210/super/server/api"
+)
+
+// wavFile returns a 16bit stereo wav at the output rate lasting d.
+func wavFile(d time.Duration) []byte {
+	size := uint32(toOffset(d))
+
+	b := make([]byte, 44, 44+size)
+	copy(b, "RIFF")
+	binary.LittleEndian.PutUint32(b[4:], 36+size)
+	copy(b[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(b[16:], 16)
+	binary.LittleEndian.PutUint16(b[20:], 1)
+	binary.LittleEndian.PutUint16(b[22:], 2)
+	binary.LittleEndian.PutUint32(b[24:], sampleRate)
+	binary.LittleEndian.PutUint32(b[28:], sampleRate*frame)
+	binary.LittleEndian.PutUint16(b[32:], frame)
+	binary.LittleEndian.PutUint16(b[34:], 16)
+	copy(b[36:], "data")
+	binary.LittleEndian.PutUint32(b[40:], size)
+
+	return append(b, make([]byte, size)...)
+}
+
+// downloading returns a track streamed from a partial file that has the
+// first half of raw, and a func that downloads the rest.
+func downloading(t *testing.T, raw []byte) (*track, func(fail bool)) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "track.wav.part"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { f.Close() })
+
+	half := len(raw) / 2
+	_, err = f.WriteAt(raw[:half], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &track{
+		file:     &api.File{Path: "/track.wav"},
+		streamer: &streamer{download: true, file: f},
+		partial:  true,
+	}
+	tr.streamer.wrote(half)
+
+	rest := func(fail bool) {
+		if fail {
+			tr.streamer.fail(io.ErrUnexpectedEOF)
+			return
+		}
+
+		n, err := f.WriteAt(raw[half:], int64(half))
+		if err != nil {
+			t.Error(err)
+		}
+
+		tr.streamer.wrote(n)
+		tr.streamer.finish()
+	}
+
+	return tr, rest
+}
+
+func TestComplete(t *testing.T) {
+	const length = 2 * time.Second
+	raw := wavFile(length)
+
+	tests := []struct {
+		name string
+		fail bool
+		// seek is where the complete track must seek to.
+		seek time.Duration
+	}{
+		{"waits for the download", false, length - 100*time.Millisecond},
+		{"seeks what a failed download got", true, length/2 - 100*time.Millisecond},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tr, rest := downloading(t, raw)
+			time.AfterFunc(5*poll, func() { rest(test.fail) })
+
+			p := &Player{}
+			s, decoder, err := p.complete(tr)
+			if err != nil {
+				t.Fatalf("complete: %v", err)
+			}
+
+			defer s.close()
+
+			offset := toOffset(test.seek)
+			_, err = decoder.Seek(offset, io.SeekStart)
+			if err != nil {
+				t.Fatalf("seeking to %v: %v", test.seek, err)
+			}
+
+			n, err := decoder.read(make([]float32, 2))
+			if err != nil || n == 0 {
+				t.Errorf("read %d samples at %v: %v", n, test.seek, err)
+			}
+		})
+	}
+}
+
+func TestCompleteAbandoned(t *testing.T) {
+	tr, _ := downloading(t, wavFile(time.Second))
+	time.AfterFunc(5*poll, tr.close)
+
+	p := &Player{}
+	_, _, err := p.complete(tr)
+	if err != errAbandoned {
+		t.Errorf("complete of a closed track = %v, want errAbandoned", err)
+	}
+}
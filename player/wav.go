@@ -0,0 +1,29 @@
+package player
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+)
+
+func init() {
+	register(&decoder{
+		name:       "wav",
+		extensions: []string{".wav"},
+		sniff: func(header []byte) bool {
+			return len(header) >= 12 &&
+				bytes.Equal(header[:4], []byte("RIFF")) &&
+				bytes.Equal(header[8:12], []byte("WAVE"))
+		},
+		decode: func(r io.ReadSeeker) (io.ReadSeeker, format, error) {
+			d, err := wav.DecodeWithoutResampling(r)
+			if err != nil {
+				return nil, format{}, err
+			}
+
+			// So does the wav decoder, whatever the file has.
+			return d, format{rate: d.SampleRate(), channels: 2}, nil
+		},
+	})
+}
@@ -0,0 +1,58 @@
+package playlist
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// Pin keeps the playlist with id available offline, or stops. The tracks
+// of pinned playlists are pinned again whenever the playlists change.
+func (p *Playlists) Pin(id string, pinned bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.playlists[id]; !ok && pinned {
+		return ErrNotFound
+	}
+
+	err := p.db.Update(func(txn *badger.Txn) error {
+		if pinned {
+			return txn.Set([]byte(super.Offline+id), nil)
+		}
+
+		return txn.Delete([]byte(super.Offline + id))
+	})
+	if err != nil {
+		slog.Error("badger.Update", "playlist", id, "error", err)
+		return err
+	}
+
+	if pinned {
+		p.offline[id] = struct{}{}
+	} else {
+		delete(p.offline, id)
+	}
+
+	return nil
+}
+
+// Pinned returns the ids of the playlists kept available offline.
+func (p *Playlists) Pinned() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ids := make([]string, 0, len(p.offline))
+	for id := range p.offline {
+		// Deleted, here or on the server, since it was pinned.
+		if _, ok := p.playlists[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.Sort(ids)
+
+	return ids
+}
@@ -0,0 +1,367 @@
+// Package playlist keeps the playlists of the library in badger, and in
+// step with the server for the user set.
+package playlist
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrNotFound    = errors.New("playlist not found")
+	ErrInvalidName = errors.New("invalid playlist name")
+	ErrOutOfRange  = errors.New("index out of range")
+	// ErrReadOnly is returned for changes to a playlist shared with the
+	// user only to view, or deleting one the user doesn't own.
+	ErrReadOnly = errors.New("playlist is read only")
+)
+
+// Playlists are the playlists, each stored under its id. Changes are
+// marked pending until the server has them.
+type Playlists struct {
+	db        *badger.DB
+	conn      *grpc.ClientConn
+	playlists map[string]*api.Playlist
+	// pending are the ids of the playlists changed since they were synced,
+	// set for the ones deleted.
+	pending map[string]bool
+	// offline are the ids of the playlists pinned for offline use.
+	offline map[string]struct{}
+	// user is who the playlists are synced for, none if empty, and token
+	// what they authenticate with. index is how far the server's changes
+	// are applied.
+	user  string
+	token string
+	index uint64
+	// restart stops syncing for the previous user, changed tells the
+	// frontend and kick tells the sync there are pending changes.
+	restart context.CancelFunc
+	changed chan struct{}
+	kick    chan struct{}
+	mu      sync.Mutex
+}
+
+// New loads the playlists from db and syncs them with the server over
+// conn.
+func New(db *badger.DB, conn *grpc.ClientConn) (*Playlists, error) {
+	p := &Playlists{
+		db:        db,
+		conn:      conn,
+		playlists: make(map[string]*api.Playlist),
+		pending:   make(map[string]bool),
+		offline:   make(map[string]struct{}),
+		changed:   make(chan struct{}, 1),
+		kick:      make(chan struct{}, 1),
+	}
+
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(super.Playlist)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			playlist := &api.Playlist{}
+			err := it.Item().Value(func(v []byte) error {
+				return proto.Unmarshal(v, playlist)
+			})
+			if err != nil {
+				return err
+			}
+
+			p.playlists[playlist.Id] = playlist
+		}
+
+		prefix = []byte(super.Pending)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			err := it.Item().Value(func(v []byte) error {
+				p.pending[string(it.Item().Key()[len(prefix):])] = string(v) == deleted
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		prefix = []byte(super.Offline)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			p.offline[string(it.Item().Key()[len(prefix):])] = struct{}{}
+		}
+
+		return nil
+	})
+	if err != nil {
+		slog.Error("loading playlists", "error", err)
+		return nil, err
+	}
+
+	err = p.loadSync()
+	if err != nil {
+		slog.Error("loading playlists sync", "error", err)
+		return nil, err
+	}
+
+	// Sync with the server in the background, playlists can be changed
+	// offline and are sent once it is reachable.
+	p.mu.Lock()
+	p.start()
+	p.mu.Unlock()
+
+	return p, nil
+}
+
+// Changed returns a channel that receives when the server changed the
+// playlists.
+func (p *Playlists) Changed() <-chan struct{} {
+	return p.changed
+}
+
+// List returns every playlist, by name.
+func (p *Playlists) List() []*api.Playlist {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	playlists := make([]*api.Playlist, 0, len(p.playlists))
+	for _, playlist := range p.playlists {
+		playlists = append(playlists, proto.Clone(playlist).(*api.Playlist))
+	}
+
+	slices.SortFunc(playlists, func(a, b *api.Playlist) int {
+		return cmp.Or(
+			strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)),
+			strings.Compare(a.Id, b.Id),
+		)
+	})
+
+	return playlists
+}
+
+// Get returns the playlist with id.
+func (p *Playlists) Get(id string) (*api.Playlist, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	playlist, ok := p.playlists[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return proto.Clone(playlist).(*api.Playlist), nil
+}
+
+// Create creates an empty playlist.
+func (p *Playlists) Create(name string) (*api.Playlist, error) {
+	return p.create(name, nil)
+}
+
+// Duplicate creates a copy of the playlist with id, named after it.
+func (p *Playlists) Duplicate(id string) (*api.Playlist, error) {
+	original, err := p.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.create(original.Name+" (copy)", original.Tracks)
+}
+
+func (p *Playlists) create(name string, tracks []string) (*api.Playlist, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+
+	now := time.Now().Unix()
+	playlist := &api.Playlist{
+		Id:       rand.Text(),
+		Name:     name,
+		Tracks:   slices.Clone(tracks),
+		Created:  now,
+		Modified: now,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := p.store(playlist)
+	if err != nil {
+		return nil, err
+	}
+
+	p.playlists[playlist.Id] = playlist
+
+	return proto.Clone(playlist).(*api.Playlist), nil
+}
+
+// Rename renames the playlist with id.
+func (p *Playlists) Rename(id, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrInvalidName
+	}
+
+	return p.update(id, func(playlist *api.Playlist) error {
+		playlist.Name = name
+		return nil
+	})
+}
+
+// Delete deletes the playlist with id.
+func (p *Playlists) Delete(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	playlist, ok := p.playlists[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if playlist.Role == api.Role_ROLE_VIEWER || playlist.Role == api.Role_ROLE_EDITOR {
+		return ErrReadOnly
+	}
+
+	// Playlists the server never had are only deleted here.
+	synced := playlist.Owner != ""
+
+	err := p.db.Update(func(txn *badger.Txn) error {
+		err := txn.Delete([]byte(super.Playlist + id))
+		if err != nil {
+			return err
+		}
+
+		err = txn.Delete([]byte(super.Offline + id))
+		if err != nil {
+			return err
+		}
+
+		if !synced {
+			return txn.Delete([]byte(super.Pending + id))
+		}
+
+		return txn.Set([]byte(super.Pending+id), []byte(deleted))
+	})
+	if err != nil {
+		slog.Error("badger.Delete", "playlist", id, "error", err)
+		return err
+	}
+
+	delete(p.playlists, id)
+	delete(p.pending, id)
+	delete(p.offline, id)
+	if synced {
+		p.pending[id] = true
+		p.push()
+	}
+
+	return nil
+}
+
+// Add adds tracks, by path, to the end of the playlist with id.
+func (p *Playlists) Add(id string, paths ...string) error {
+	return p.update(id, func(playlist *api.Playlist) error {
+		playlist.Tracks = append(playlist.Tracks, paths...)
+		return nil
+	})
+}
+
+// Remove removes the track at index from the playlist with id.
+func (p *Playlists) Remove(id string, index int) error {
+	return p.update(id, func(playlist *api.Playlist) error {
+		if index < 0 || index >= len(playlist.Tracks) {
+			return ErrOutOfRange
+		}
+
+		playlist.Tracks = slices.Delete(playlist.Tracks, index, index+1)
+		return nil
+	})
+}
+
+// Move moves the track at from to to in the playlist with id.
+func (p *Playlists) Move(id string, from, to int) error {
+	return p.update(id, func(playlist *api.Playlist) error {
+		if from < 0 || from >= len(playlist.Tracks) || to < 0 || to >= len(playlist.Tracks) {
+			return ErrOutOfRange
+		}
+
+		track := playlist.Tracks[from]
+		playlist.Tracks = slices.Delete(playlist.Tracks, from, from+1)
+		playlist.Tracks = slices.Insert(playlist.Tracks, to, track)
+		return nil
+	})
+}
+
+// update changes a copy of the playlist with id and stores it, the
+// playlist is left as it was if change or storing fails.
+func (p *Playlists) update(id string, change func(*api.Playlist) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	playlist, ok := p.playlists[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if playlist.Role == api.Role_ROLE_VIEWER {
+		return ErrReadOnly
+	}
+
+	playlist = proto.Clone(playlist).(*api.Playlist)
+
+	err := change(playlist)
+	if err != nil {
+		return err
+	}
+
+	playlist.Modified = time.Now().Unix()
+
+	err = p.store(playlist)
+	if err != nil {
+		return err
+	}
+
+	p.playlists[id] = playlist
+
+	return nil
+}
+
+// deleted is the pending value of a deleted playlist.
+const deleted = "deleted"
+
+// store stores a playlist changed here, pending until the server has it.
+// Must be called with mu held.
+func (p *Playlists) store(playlist *api.Playlist) error {
+	b, err := proto.Marshal(playlist)
+	if err != nil {
+		slog.Error("proto.Marshal", "error", err)
+		return err
+	}
+
+	err = p.db.Update(func(txn *badger.Txn) error {
+		err := txn.Set([]byte(super.Playlist+playlist.Id), b)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set([]byte(super.Pending+playlist.Id), nil)
+	})
+	if err != nil {
+		slog.Error("badger.Set", "playlist", playlist.Id, "error", err)
+		return err
+	}
+
+	p.pending[playlist.Id] = false
+	p.push()
+
+	return nil
+}
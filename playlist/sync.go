@@ -0,0 +1,554 @@
+package playlist
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	tokenKey = super.Setting + "playlists_token"
+	indexKey = super.Setting + "playlists_index"
+)
+
+const (
+	minBackoff   = time.Second
+	maxBackoff   = time.Minute
+	shareTimeout = 10 * time.Second
+)
+
+var (
+	ErrNoUser       = errors.New("no user to sync playlists for")
+	ErrNotSynced    = errors.New("playlist not synced yet")
+	ErrInvalidToken = errors.New("invalid playlists token")
+)
+
+// tokenUser returns the user token was issued for, none for no token. Only
+// the server can tell if the token is valid.
+func tokenUser(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+
+	encoded, _, ok := strings.Cut(token, ".")
+	user, err := base64.RawURLEncoding.DecodeString(encoded)
+	if !ok || err != nil || len(user) == 0 {
+		return "", ErrInvalidToken
+	}
+
+	return string(user), nil
+}
+
+// loadSync loads the token and how far the server's changes are applied.
+func (p *Playlists) loadSync() error {
+	token := &wrapperspb.StringValue{}
+	index := &wrapperspb.UInt64Value{}
+
+	err := p.db.View(func(txn *badger.Txn) error {
+		for key, m := range map[string]proto.Message{tokenKey: token, indexKey: index} {
+			item, err := txn.Get([]byte(key))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			err = item.Value(func(v []byte) error {
+				return proto.Unmarshal(v, m)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	user, err := tokenUser(token.GetValue())
+	if err != nil {
+		return err
+	}
+
+	p.token = token.GetValue()
+	p.user = user
+	p.index = index.GetValue()
+
+	return nil
+}
+
+// User returns who the playlists are synced for.
+func (p *Playlists) User() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.user
+}
+
+// SetToken syncs the playlists for the user token was issued for from now
+// on, none if empty. Tokens are issued by the server. The playlists synced
+// for a previous user are forgotten, the ones never synced are kept and
+// sent for the new one.
+func (p *Playlists) SetToken(token string) error {
+	user, err := tokenUser(token)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if token == p.token {
+		return nil
+	}
+
+	t, err := proto.Marshal(wrapperspb.String(token))
+	if err != nil {
+		slog.Error("proto.Marshal", "error", err)
+		return err
+	}
+
+	// A new token for the same user keeps what is synced.
+	index := p.index
+	if user != p.user {
+		index = 0
+	}
+
+	i, err := proto.Marshal(wrapperspb.UInt64(index))
+	if err != nil {
+		slog.Error("proto.Marshal", "error", err)
+		return err
+	}
+
+	var forget []string
+	if user != p.user {
+		for id, playlist := range p.playlists {
+			if playlist.Owner != "" {
+				forget = append(forget, id)
+			}
+		}
+
+		for id, deleted := range p.pending {
+			if deleted {
+				forget = append(forget, id)
+			}
+		}
+	}
+
+	err = p.db.Update(func(txn *badger.Txn) error {
+		for _, id := range forget {
+			err := txn.Delete([]byte(super.Playlist + id))
+			if err != nil {
+				return err
+			}
+
+			err = txn.Delete([]byte(super.Pending + id))
+			if err != nil {
+				return err
+			}
+		}
+
+		err := txn.Set([]byte(tokenKey), t)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set([]byte(indexKey), i)
+	})
+	if err != nil {
+		slog.Error("badger.Set", "user", user, "error", err)
+		return err
+	}
+
+	for _, id := range forget {
+		delete(p.playlists, id)
+		delete(p.pending, id)
+	}
+
+	p.token = token
+	p.user = user
+	p.index = index
+	p.start()
+
+	return nil
+}
+
+// Share shares the playlist with id with user as role, ROLE_NONE unshares
+// it. Only the owner can share a playlist, once the server has it.
+func (p *Playlists) Share(id, user string, role api.Role) error {
+	p.mu.Lock()
+	playlist, ok := p.playlists[id]
+	me, token := p.user, p.token
+	p.mu.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	if me == "" {
+		return ErrNoUser
+	}
+
+	if playlist.Owner == "" {
+		return ErrNotSynced
+	}
+
+	if playlist.Owner != me {
+		return ErrReadOnly
+	}
+
+	ctx, cancel := context.WithTimeout(authorize(context.Background(), token), shareTimeout)
+	defer cancel()
+
+	_, err := api.NewPlaylistsClient(p.conn).Share(ctx, &api.ShareRequest{
+		Id:   id,
+		User: user,
+		Role: role,
+	})
+	if err != nil {
+		slog.Error("playlists.Share", "id", id, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// start stops syncing for the previous user and starts for the current
+// one. Must be called with mu held.
+func (p *Playlists) start() {
+	if p.restart != nil {
+		p.restart()
+		p.restart = nil
+	}
+
+	if p.user == "" {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.restart = cancel
+
+	go p.sync(authorize(ctx, p.token))
+}
+
+// authorize returns ctx with token sent along, so the server knows who is
+// calling.
+func authorize(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// push tells the sync there are pending changes.
+func (p *Playlists) push() {
+	select {
+	case p.kick <- struct{}{}:
+	default:
+	}
+}
+
+// sync gets the server's changes and sends the pending ones until ctx is
+// done, reconnecting when the server is unreachable.
+func (p *Playlists) sync(ctx context.Context) {
+	backoff := minBackoff
+	for {
+		started := time.Now()
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		pulled := make(chan error, 1)
+		go func() {
+			pulled <- p.pull(streamCtx)
+		}()
+
+		err := p.pushes(streamCtx, pulled)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A connection that lasted was fine, start over.
+		if time.Since(started) > maxBackoff {
+			backoff = minBackoff
+		}
+
+		slog.Error("playlists sync", "error", err, "retry", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// pull applies the server's changes as they come.
+func (p *Playlists) pull(ctx context.Context) error {
+	p.mu.Lock()
+	index := p.index
+	p.mu.Unlock()
+
+	response, err := api.NewPlaylistsClient(p.conn).Get(ctx, &api.PlaylistsRequest{Index: index})
+	if err != nil {
+		return err
+	}
+
+	for {
+		message, err := response.Recv()
+		if err != nil {
+			return err
+		}
+
+		err = p.apply(ctx, message)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// apply stores the server's changes, except for the playlists changed here
+// since, which are sent instead.
+func (p *Playlists) apply(ctx context.Context, message *api.PlaylistsResponse) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// The user changed while the message was on its way.
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	index, err := proto.Marshal(wrapperspb.UInt64(message.Index))
+	if err != nil {
+		slog.Error("proto.Marshal", "error", err)
+		return err
+	}
+
+	var updates []*api.Playlist
+	for _, playlist := range message.Update {
+		deleted, pending := p.pending[playlist.Id]
+		if deleted {
+			continue
+		}
+
+		if local, ok := p.playlists[playlist.Id]; pending && ok && local.Modified > playlist.Modified {
+			continue
+		}
+
+		updates = append(updates, playlist)
+	}
+
+	err = p.db.Update(func(txn *badger.Txn) error {
+		for _, playlist := range updates {
+			b, err := proto.Marshal(playlist)
+			if err != nil {
+				return err
+			}
+
+			err = txn.Set([]byte(super.Playlist+playlist.Id), b)
+			if err != nil {
+				return err
+			}
+
+			err = txn.Delete([]byte(super.Pending + playlist.Id))
+			if err != nil {
+				return err
+			}
+		}
+
+		// Gone from the server, or unshared, changes here go with it.
+		for _, id := range message.Remove {
+			err := txn.Delete([]byte(super.Playlist + id))
+			if err != nil {
+				return err
+			}
+
+			err = txn.Delete([]byte(super.Pending + id))
+			if err != nil {
+				return err
+			}
+		}
+
+		return txn.Set([]byte(indexKey), index)
+	})
+	if err != nil {
+		slog.Error("badger.Update", "playlists", message.Index, "error", err)
+		return err
+	}
+
+	for _, playlist := range updates {
+		p.playlists[playlist.Id] = playlist
+		delete(p.pending, playlist.Id)
+	}
+
+	for _, id := range message.Remove {
+		delete(p.playlists, id)
+		delete(p.pending, id)
+	}
+
+	p.index = message.Index
+	p.notify()
+
+	return nil
+}
+
+// pushes sends the pending changes, and again every time there are new
+// ones, until pulling stops.
+func (p *Playlists) pushes(ctx context.Context, pulled <-chan error) error {
+	for {
+		err := p.send(ctx)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-p.kick:
+		case err := <-pulled:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// send sends every pending change to the server.
+func (p *Playlists) send(ctx context.Context) error {
+	client := api.NewPlaylistsClient(p.conn)
+
+	p.mu.Lock()
+	sending := make(map[string]*api.Playlist, len(p.pending))
+	for id, deleted := range p.pending {
+		if deleted {
+			sending[id] = nil
+			continue
+		}
+
+		sending[id] = p.playlists[id]
+	}
+	p.mu.Unlock()
+
+	for id, playlist := range sending {
+		if playlist == nil {
+			_, err := client.Delete(ctx, &api.PlaylistDeleteRequest{Id: id})
+			switch status.Code(err) {
+			case codes.OK, codes.NotFound, codes.PermissionDenied, codes.InvalidArgument:
+				err = p.sent(ctx, id, nil, nil)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		saved, err := client.Save(ctx, playlist)
+		switch status.Code(err) {
+		case codes.OK:
+			err = p.sent(ctx, id, playlist, saved)
+		case codes.NotFound, codes.PermissionDenied:
+			// Deleted, or unshared, on the server.
+			err = p.sent(ctx, id, playlist, nil)
+		case codes.InvalidArgument:
+			// Kept here as it is, the server will never take it.
+			err = p.sent(ctx, id, playlist, playlist)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sent stores what the server made of the change sent for the playlist
+// with id, nil for a playlist it doesn't have. Nothing changes if the
+// playlist was changed again since.
+func (p *Playlists) sent(ctx context.Context, id string, sending, saved *api.Playlist) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	deleted, pending := p.pending[id]
+	if !pending || (sending == nil) != deleted || (sending != nil && p.playlists[id] != sending) {
+		return nil
+	}
+
+	// A refused change leaves the playlist as the server has it, or
+	// without it if it has none.
+	refused := sending != nil && saved == nil
+
+	var b []byte
+	if saved != nil {
+		var err error
+		b, err = proto.Marshal(saved)
+		if err != nil {
+			slog.Error("proto.Marshal", "error", err)
+			return err
+		}
+	}
+
+	err := p.db.Update(func(txn *badger.Txn) error {
+		if saved != nil {
+			err := txn.Set([]byte(super.Playlist+id), b)
+			if err != nil {
+				return err
+			}
+		}
+
+		if refused {
+			err := txn.Delete([]byte(super.Playlist + id))
+			if err != nil {
+				return err
+			}
+		}
+
+		return txn.Delete([]byte(super.Pending + id))
+	})
+	if err != nil {
+		slog.Error("badger.Update", "playlist", id, "error", err)
+		return err
+	}
+
+	delete(p.pending, id)
+
+	switch {
+	case saved != nil:
+		p.playlists[id] = saved
+	case refused:
+		delete(p.playlists, id)
+	}
+
+	if sending != nil {
+		p.notify()
+	}
+
+	return nil
+}
+
+// notify tells the frontend the playlists changed.
+func (p *Playlists) notify() {
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
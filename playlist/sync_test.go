@@ -0,0 +1,274 @@
+package playlist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bh90210/super/server/api"
+	badger "github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+func open(t *testing.T) *badger.DB {
+	t.Helper()
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// synced returns playlists with one the server has, at modified, as id.
+func synced(t *testing.T, modified int64) (*Playlists, *api.Playlist) {
+	t.Helper()
+
+	p, err := New(open(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &api.Playlist{
+		Id:       "id",
+		Name:     "server",
+		Owner:    "alice",
+		Role:     api.Role_ROLE_OWNER,
+		Modified: modified,
+	}
+
+	err = p.apply(context.Background(), &api.PlaylistsResponse{Index: 1, Update: []*api.Playlist{server}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p, server
+}
+
+// edit renames the playlist with id here, at modified.
+func edit(t *testing.T, p *Playlists, id string, modified int64) *api.Playlist {
+	t.Helper()
+
+	err := p.Rename(id, "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.playlists[id].Modified = modified
+
+	return p.playlists[id]
+}
+
+// check compares the playlist with id to name, "" for none, and whether
+// it is pending, here and after loading again from the store.
+func check(t *testing.T, p *Playlists, id, name string, pending bool) {
+	t.Helper()
+
+	reloaded, err := New(p.db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []*Playlists{p, reloaded} {
+		playlist, ok := p.playlists[id]
+		if name == "" && ok {
+			t.Errorf("playlist %q is still there", playlist.Name)
+		}
+
+		if name != "" && (!ok || playlist.Name != name) {
+			t.Errorf("playlist %v, want %q", playlist, name)
+		}
+
+		if _, ok := p.pending[id]; ok != pending {
+			t.Errorf("pending %v, want %v", ok, pending)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		// local edits the synced playlist here, at 100, before the
+		// server's copy at server comes.
+		local  func(t *testing.T, p *Playlists)
+		server int64
+		remove bool
+		// want is the name left, pending whether it is still to be sent.
+		want    string
+		pending bool
+	}{
+		{
+			name:   "server copy without a local edit",
+			local:  func(t *testing.T, p *Playlists) {},
+			server: 200,
+			want:   "server",
+		},
+		{
+			name:    "local edit newer than the server copy",
+			local:   func(t *testing.T, p *Playlists) { edit(t, p, "id", 100) },
+			server:  50,
+			want:    "local",
+			pending: true,
+		},
+		{
+			name:   "local edit older than the server copy",
+			local:  func(t *testing.T, p *Playlists) { edit(t, p, "id", 100) },
+			server: 200,
+			want:   "server",
+		},
+		{
+			name: "local delete",
+			local: func(t *testing.T, p *Playlists) {
+				err := p.Delete("id")
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			server:  200,
+			pending: true,
+		},
+		{
+			name:   "remove while an edit is pending",
+			local:  func(t *testing.T, p *Playlists) { edit(t, p, "id", 100) },
+			remove: true,
+		},
+		{
+			name: "remove while a delete is pending",
+			local: func(t *testing.T, p *Playlists) {
+				err := p.Delete("id")
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			remove: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, server := synced(t, 10)
+			test.local(t, p)
+
+			message := &api.PlaylistsResponse{Index: 2}
+			if test.remove {
+				message.Remove = []string{"id"}
+			} else {
+				server = proto.Clone(server).(*api.Playlist)
+				server.Modified = test.server
+				message.Update = []*api.Playlist{server}
+			}
+
+			err := p.apply(context.Background(), message)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			check(t, p, "id", test.want, test.pending)
+
+			if p.index != 2 {
+				t.Errorf("index %d, want 2", p.index)
+			}
+		})
+	}
+}
+
+func TestApplyCancelled(t *testing.T) {
+	p, _ := synced(t, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := p.apply(ctx, &api.PlaylistsResponse{Index: 2, Remove: []string{"id"}})
+	if err == nil {
+		t.Fatal("applied changes of a previous user")
+	}
+
+	check(t, p, "id", "server", false)
+}
+
+func TestSent(t *testing.T) {
+	saved := func(name string) *api.Playlist {
+		return &api.Playlist{Id: "id", Name: name, Owner: "alice", Role: api.Role_ROLE_OWNER, Modified: 300}
+	}
+
+	tests := []struct {
+		name string
+		// send makes the change sent and returns it, nil for a delete.
+		send func(t *testing.T, p *Playlists) *api.Playlist
+		// again changes the playlist while the change is on its way.
+		again   func(t *testing.T, p *Playlists)
+		saved   *api.Playlist
+		want    string
+		pending bool
+	}{
+		{
+			name:  "saved",
+			send:  func(t *testing.T, p *Playlists) *api.Playlist { return edit(t, p, "id", 100) },
+			saved: saved("as saved"),
+			want:  "as saved",
+		},
+		{
+			name: "saved while edited again",
+			send: func(t *testing.T, p *Playlists) *api.Playlist { return edit(t, p, "id", 100) },
+			again: func(t *testing.T, p *Playlists) {
+				err := p.Rename("id", "again")
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			saved:   saved("as saved"),
+			want:    "again",
+			pending: true,
+		},
+		{
+			name: "refused",
+			send: func(t *testing.T, p *Playlists) *api.Playlist { return edit(t, p, "id", 100) },
+		},
+		{
+			name: "deleted",
+			send: func(t *testing.T, p *Playlists) *api.Playlist {
+				err := p.Delete("id")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "edit sent while deleted since",
+			send: func(t *testing.T, p *Playlists) *api.Playlist { return edit(t, p, "id", 100) },
+			again: func(t *testing.T, p *Playlists) {
+				err := p.Delete("id")
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			saved:   saved("as saved"),
+			pending: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, _ := synced(t, 10)
+			sending := test.send(t, p)
+
+			if test.again != nil {
+				test.again(t, p)
+			}
+
+			err := p.sent(context.Background(), "id", sending, test.saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			check(t, p, "id", test.want, test.pending)
+		})
+	}
+}
@@ -0,0 +1,679 @@
+package queue
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrOutOfRange = errors.New("index out of range")
+
+const (
+	// currentKey is the track playing and the settings.
+	currentKey = super.Queue + "current"
+	// Upcoming and played tracks are stored under their position.
+	upcomingKey = super.Queue + "upcoming_"
+	historyKey  = super.Queue + "history_"
+)
+
+// Upcoming positions start in the middle, with gaps to move entries
+// between others without storing the rest again.
+const (
+	firstPosition = 1 << 63
+	spacing       = 1 << 16
+)
+
+// historyLimit is how many played tracks are kept for previous.
+const historyLimit = 1000
+
+// Repeat is what happens once a track ends.
+type Repeat int
+
+const (
+	// Off plays the queue once.
+	Off Repeat = iota
+	// One plays the current track again.
+	One
+	// All puts played tracks back at the end of the queue.
+	All
+)
+
+func (r Repeat) String() string {
+	switch r {
+	case One:
+		return "one"
+	case All:
+		return "all"
+	default:
+		return "off"
+	}
+}
+
+// ParseRepeat returns the Repeat named s, Off if there is none.
+func ParseRepeat(s string) Repeat {
+	switch s {
+	case One.String():
+		return One
+	case All.String():
+		return All
+	default:
+		return Off
+	}
+}
+
+// Entry is a track in the queue.
+type Entry struct {
+	File *api.File
+	// Order is the position the entry was added at, shuffling off puts
+	// entries back in that order.
+	Order uint64
+	// position is where the entry is stored, in the order of the list it
+	// is in.
+	position uint64
+}
+
+// Snapshot is the state of the queue.
+type Snapshot struct {
+	Current  *api.File
+	Upcoming []*api.File
+	History  []*api.File
+	Shuffled bool
+	Repeat   string
+}
+
+// state is the queue.
+type state struct {
+	Playing  *Entry
+	Upcoming []Entry
+	// History is the played tracks, the most recent last.
+	History  []Entry
+	Shuffled bool
+	Repeat   Repeat
+	// Order is the order of the next entry added.
+	Order uint64
+}
+
+// Queue is what plays next, independent of the list shown. Played tracks
+// go on a history stack for previous. It is stored on every change so it
+// survives restarts, each entry under its own key so moving on to the next
+// track only stores the entries that changed.
+type Queue struct {
+	db *badger.DB
+	state
+	// set and deleted are the entries changed since the queue was stored.
+	set     map[string]*api.QueueEntry
+	deleted map[string]struct{}
+	mu      sync.Mutex
+}
+
+// New loads the queue. Tracks are stored by path, files returns them for
+// the paths.
+func New(db *badger.DB, files func(paths []string) []*api.File) (*Queue, error) {
+	q := &Queue{
+		db:      db,
+		set:     make(map[string]*api.QueueEntry),
+		deleted: make(map[string]struct{}),
+	}
+
+	current := &api.QueueState{}
+	var paths []string
+
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(currentKey))
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		if err == nil {
+			err = item.Value(func(v []byte) error {
+				return proto.Unmarshal(v, current)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		q.Upcoming, err = load(txn, upcomingKey)
+		if err != nil {
+			return err
+		}
+
+		q.History, err = load(txn, historyKey)
+		return err
+	})
+	if err != nil {
+		slog.Error("loading queue", "error", err)
+		return nil, err
+	}
+
+	q.Shuffled = current.Shuffled
+	q.Repeat = Repeat(current.Repeat)
+	q.Order = current.Order
+	if current.Playing != nil {
+		q.Playing = &Entry{Order: current.Playing.Order}
+		paths = append(paths, current.Playing.Path)
+	}
+
+	for _, entries := range [][]Entry{q.Upcoming, q.History} {
+		for _, entry := range entries {
+			paths = append(paths, entry.File.Path)
+		}
+	}
+
+	// Entries point to the files of the library from now on.
+	found := files(paths)
+	if q.Playing != nil {
+		q.Playing.File, found = found[0], found[1:]
+	}
+
+	for _, entries := range [][]Entry{q.Upcoming, q.History} {
+		for i := range entries {
+			entries[i].File, found = found[0], found[1:]
+		}
+	}
+
+	return q, nil
+}
+
+// load returns the entries stored under prefix, in the order of their
+// positions. Their files only have the path.
+func load(txn *badger.Txn, prefix string) ([]Entry, error) {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	var entries []Entry
+	for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+		position, err := strconv.ParseUint(string(it.Item().Key()[len(prefix):]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		stored := &api.QueueEntry{}
+		err = it.Item().Value(func(v []byte) error {
+			return proto.Unmarshal(v, stored)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, Entry{
+			File:     &api.File{Path: stored.Path},
+			Order:    stored.Order,
+			position: position,
+		})
+	}
+
+	return entries, nil
+}
+
+// Play replaces the queue with files, starting from the one at index.
+// The ones before it go to the history so previous goes through them.
+func (q *Queue) Play(files []*api.File, index int) (*api.File, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if index < 0 || index >= len(files) {
+		return nil, ErrOutOfRange
+	}
+
+	q.push(files[:index]...)
+
+	for _, entry := range q.Upcoming {
+		q.drop(upcomingKey, entry)
+	}
+
+	entries := q.entries(files[index:])
+	q.Playing, q.Upcoming = &entries[0], entries[1:]
+	if q.Shuffled {
+		q.shuffle()
+	}
+
+	q.renumber()
+
+	return q.Playing.File, q.store()
+}
+
+// PlayNext puts files right after the current track.
+func (q *Queue) PlayNext(files ...*api.File) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.insert(0, q.entries(files)...)
+
+	return q.store()
+}
+
+// Add puts files at the end of the queue.
+func (q *Queue) Add(files ...*api.File) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.insert(len(q.Upcoming), q.entries(files)...)
+
+	return q.store()
+}
+
+// Move moves the upcoming track at from to to.
+func (q *Queue) Move(from, to int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if from < 0 || from >= len(q.Upcoming) || to < 0 || to >= len(q.Upcoming) {
+		return ErrOutOfRange
+	}
+
+	entry := q.Upcoming[from]
+	q.remove(from)
+	q.insert(to, entry)
+
+	return q.store()
+}
+
+// Remove removes the upcoming track at index.
+func (q *Queue) Remove(index int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if index < 0 || index >= len(q.Upcoming) {
+		return ErrOutOfRange
+	}
+
+	q.remove(index)
+
+	return q.store()
+}
+
+// Clear removes the upcoming tracks.
+func (q *Queue) Clear() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, entry := range q.Upcoming {
+		q.drop(upcomingKey, entry)
+	}
+
+	q.Upcoming = nil
+
+	return q.store()
+}
+
+// Shuffle shuffles the upcoming tracks, or puts them back in the order
+// they were added.
+func (q *Queue) Shuffle(shuffled bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.Shuffled = shuffled
+	if shuffled {
+		q.shuffle()
+	} else {
+		slices.SortStableFunc(q.Upcoming, func(a, b Entry) int {
+			return cmp.Compare(a.Order, b.Order)
+		})
+	}
+
+	q.renumber()
+
+	return q.store()
+}
+
+func (q *Queue) SetRepeat(repeat Repeat) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.Repeat = repeat
+
+	return q.store()
+}
+
+// Current returns the track playing.
+func (q *Queue) Current() (*api.File, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.Playing == nil {
+		return nil, false
+	}
+
+	return q.Playing.File, true
+}
+
+// Peek returns the track that plays once the current one ends.
+func (q *Queue) Peek() (*api.File, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.Playing == nil {
+		return nil, false
+	}
+
+	if q.Repeat == One {
+		return q.Playing.File, true
+	}
+
+	if len(q.Upcoming) > 0 {
+		return q.Upcoming[0].File, true
+	}
+
+	// The current track is all that is left to repeat.
+	if q.Repeat == All {
+		return q.Playing.File, true
+	}
+
+	return nil, false
+}
+
+// Advance moves on once the current track ended, repeating it if asked to.
+func (q *Queue) Advance() (*api.File, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.Repeat == One && q.Playing != nil {
+		return q.Playing.File, true
+	}
+
+	return q.next()
+}
+
+// Next skips to the next track, even when repeating one.
+func (q *Queue) Next() (*api.File, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.next()
+}
+
+// Previous goes back to the last track played. The current one is put
+// back at the front of the queue.
+func (q *Queue) Previous() (*api.File, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.History) == 0 {
+		return nil, false
+	}
+
+	last := q.History[len(q.History)-1]
+	q.History = q.History[:len(q.History)-1]
+	q.drop(historyKey, last)
+
+	file := last.File
+
+	// Repeating all put it back at the end as well.
+	if q.Repeat == All {
+		for i := len(q.Upcoming) - 1; i >= 0; i-- {
+			if q.Upcoming[i].File.Path == file.Path {
+				q.remove(i)
+				break
+			}
+		}
+	}
+
+	if q.Playing != nil {
+		q.insert(0, *q.Playing)
+	}
+
+	entry := q.entries([]*api.File{file})[0]
+	q.Playing = &entry
+
+	q.keep()
+
+	return file, true
+}
+
+// HasPrevious reports whether there is history to go back to.
+func (q *Queue) HasPrevious() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.History) > 0
+}
+
+// Snapshot returns the state of the queue.
+func (q *Queue) Snapshot() Snapshot {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	snapshot := Snapshot{
+		Shuffled: q.Shuffled,
+		Repeat:   q.Repeat.String(),
+	}
+
+	for _, entry := range q.History {
+		snapshot.History = append(snapshot.History, entry.File)
+	}
+
+	if q.Playing != nil {
+		snapshot.Current = q.Playing.File
+	}
+
+	for _, entry := range q.Upcoming {
+		snapshot.Upcoming = append(snapshot.Upcoming, entry.File)
+	}
+
+	return snapshot
+}
+
+// next moves on to the first upcoming track. It must be called with q.mu
+// held.
+func (q *Queue) next() (*api.File, bool) {
+	if q.Playing != nil {
+		q.push(q.Playing.File)
+
+		if q.Repeat == All {
+			q.insert(len(q.Upcoming), q.entries([]*api.File{q.Playing.File})...)
+		}
+	}
+
+	if len(q.Upcoming) == 0 {
+		q.Playing = nil
+		q.keep()
+		return nil, false
+	}
+
+	entry := q.Upcoming[0]
+	q.remove(0)
+	q.Playing = &entry
+
+	q.keep()
+
+	return entry.File, true
+}
+
+// push adds files to the history, only the last historyLimit are kept.
+// It must be called with q.mu held.
+func (q *Queue) push(files ...*api.File) {
+	if len(files) > historyLimit {
+		files = files[len(files)-historyLimit:]
+	}
+
+	position := uint64(0)
+	if len(q.History) > 0 {
+		position = q.History[len(q.History)-1].position + 1
+	}
+
+	for _, file := range files {
+		entry := Entry{File: file, position: position}
+		q.History = append(q.History, entry)
+		q.put(historyKey, entry)
+		position++
+	}
+
+	if len(q.History) > historyLimit {
+		for _, entry := range q.History[:len(q.History)-historyLimit] {
+			q.drop(historyKey, entry)
+		}
+
+		q.History = slices.Clone(q.History[len(q.History)-historyLimit:])
+	}
+}
+
+// insert puts entries in the upcoming ones at index, between the positions
+// around it. They are all numbered again if there is no room. It must be
+// called with q.mu held.
+func (q *Queue) insert(index int, entries ...Entry) {
+	q.Upcoming = slices.Insert(q.Upcoming, index, entries...)
+
+	var low, high uint64
+	switch {
+	case len(q.Upcoming) == len(entries):
+		low, high = firstPosition-spacing, firstPosition+uint64(len(entries))*spacing
+	case index == 0:
+		high = q.Upcoming[len(entries)].position
+		low = high - uint64(len(entries)+1)*spacing
+	case index+len(entries) == len(q.Upcoming):
+		low = q.Upcoming[index-1].position
+		high = low + uint64(len(entries)+1)*spacing
+	default:
+		low, high = q.Upcoming[index-1].position, q.Upcoming[index+len(entries)].position
+	}
+
+	step := (high - low) / uint64(len(entries)+1)
+	if step == 0 || low > high {
+		q.renumber()
+		return
+	}
+
+	for i := range entries {
+		q.Upcoming[index+i].position = low + uint64(i+1)*step
+		q.put(upcomingKey, q.Upcoming[index+i])
+	}
+}
+
+// remove removes the upcoming entry at index. It must be called with q.mu
+// held.
+func (q *Queue) remove(index int) {
+	q.drop(upcomingKey, q.Upcoming[index])
+	q.Upcoming = slices.Delete(q.Upcoming, index, index+1)
+}
+
+// renumber spaces the positions of the upcoming entries evenly again,
+// after their order changed. It must be called with q.mu held.
+func (q *Queue) renumber() {
+	for _, entry := range q.Upcoming {
+		q.drop(upcomingKey, entry)
+	}
+
+	for i := range q.Upcoming {
+		q.Upcoming[i].position = firstPosition + uint64(i)*spacing
+		q.put(upcomingKey, q.Upcoming[i])
+	}
+}
+
+// put marks entry to be stored under prefix. It must be called with q.mu
+// held.
+func (q *Queue) put(prefix string, entry Entry) {
+	key := positionKey(prefix, entry.position)
+	delete(q.deleted, key)
+	q.set[key] = &api.QueueEntry{Path: entry.File.Path, Order: entry.Order}
+}
+
+// drop marks entry to be deleted from under prefix. It must be called with
+// q.mu held.
+func (q *Queue) drop(prefix string, entry Entry) {
+	key := positionKey(prefix, entry.position)
+	delete(q.set, key)
+	q.deleted[key] = struct{}{}
+}
+
+func positionKey(prefix string, position uint64) string {
+	return fmt.Sprintf("%s%020d", prefix, position)
+}
+
+// entries wraps files in entries ordered after the existing ones. It must
+// be called with q.mu held.
+func (q *Queue) entries(files []*api.File) []Entry {
+	entries := make([]Entry, 0, len(files))
+	for _, file := range files {
+		entries = append(entries, Entry{File: file, Order: q.Order})
+		q.Order++
+	}
+
+	return entries
+}
+
+// shuffle must be called with q.mu held.
+func (q *Queue) shuffle() {
+	rand.Shuffle(len(q.Upcoming), func(i, j int) {
+		q.Upcoming[i], q.Upcoming[j] = q.Upcoming[j], q.Upcoming[i]
+	})
+}
+
+// store saves the track playing, the settings and the entries changed.
+// Entries that fail to store are tried again with the next change. It must
+// be called with q.mu held.
+func (q *Queue) store() error {
+	current := &api.QueueState{
+		Shuffled: q.Shuffled,
+		Repeat:   uint32(q.Repeat),
+		Order:    q.Order,
+	}
+
+	if q.Playing != nil {
+		current.Playing = &api.QueueEntry{Path: q.Playing.File.Path, Order: q.Playing.Order}
+	}
+
+	b, err := proto.Marshal(current)
+	if err != nil {
+		slog.Error("proto.Marshal", "error", err)
+		return err
+	}
+
+	// Playing a long list stores more entries than fit in a transaction.
+	batch := q.db.NewWriteBatch()
+	defer batch.Cancel()
+
+	err = batch.Set([]byte(currentKey), b)
+	for key := range q.deleted {
+		if err != nil {
+			break
+		}
+
+		err = batch.Delete([]byte(key))
+	}
+
+	for key, entry := range q.set {
+		if err != nil {
+			break
+		}
+
+		var b []byte
+		b, err = proto.Marshal(entry)
+		if err == nil {
+			err = batch.Set([]byte(key), b)
+		}
+	}
+
+	if err == nil {
+		err = batch.Flush()
+	}
+
+	if err != nil {
+		slog.Error("badger.WriteBatch", "error", err)
+		return err
+	}
+
+	clear(q.set)
+	clear(q.deleted)
+
+	return nil
+}
+
+// keep stores the queue after moving between tracks, which goes on even
+// if storing fails.
+func (q *Queue) keep() {
+	err := q.store()
+	if err != nil {
+		slog.Warn("queue not stored, trying again with the next change", "error", err)
+	}
+}
@@ -0,0 +1,251 @@
+package queue
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bh90210/super/server/api"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+func open(t *testing.T) *badger.DB {
+	t.Helper()
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// byPath returns files with only their path, like the library would for
+// paths it doesn't know.
+func byPath(paths []string) []*api.File {
+	files := make([]*api.File, len(paths))
+	for i, path := range paths {
+		files[i] = &api.File{Path: path}
+	}
+
+	return files
+}
+
+func files(paths ...string) []*api.File {
+	return byPath(paths)
+}
+
+func paths(files []*api.File) []string {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+
+	return paths
+}
+
+func path(file *api.File, ok bool) string {
+	if !ok {
+		return ""
+	}
+
+	return file.Path
+}
+
+func TestQueue(t *testing.T) {
+	tests := []struct {
+		name     string
+		do       func(t *testing.T, q *Queue) []string
+		played   []string
+		current  string
+		upcoming []string
+		history  []string
+	}{
+		{
+			name: "play from the middle",
+			do: func(t *testing.T, q *Queue) []string {
+				q.Play(files("a", "b", "c", "d"), 1)
+				return nil
+			},
+			current:  "b",
+			upcoming: []string{"c", "d"},
+			history:  []string{"a"},
+		},
+		{
+			name: "next to the end",
+			do: func(t *testing.T, q *Queue) []string {
+				q.Play(files("a", "b"), 0)
+				return []string{path(q.Next()), path(q.Next())}
+			},
+			played:  []string{"b", ""},
+			history: []string{"a", "b"},
+		},
+		{
+			name: "repeat one advances to the same track but next skips",
+			do: func(t *testing.T, q *Queue) []string {
+				q.Play(files("a", "b"), 0)
+				q.SetRepeat(One)
+				return []string{path(q.Advance()), path(q.Next())}
+			},
+			played:  []string{"a", "b"},
+			current: "b",
+			history: []string{"a"},
+		},
+		{
+			name: "repeat all puts played tracks at the end",
+			do: func(t *testing.T, q *Queue) []string {
+				q.Play(files("a", "b", "c"), 0)
+				q.SetRepeat(All)
+				return []string{path(q.Next()), path(q.Next()), path(q.Next())}
+			},
+			played:   []string{"b", "c", "a"},
+			current:  "a",
+			upcoming: []string{"b", "c"},
+			history:  []string{"a", "b", "c"},
+		},
+		{
+			name: "previous while repeating all takes the track off the end",
+			do: func(t *testing.T, q *Queue) []string {
+				q.Play(files("a", "b", "c"), 0)
+				q.SetRepeat(All)
+				q.Next()
+				return []string{path(q.Previous())}
+			},
+			played:   []string{"a"},
+			current:  "a",
+			upcoming: []string{"b", "c"},
+		},
+		{
+			name: "previous with no history",
+			do: func(t *testing.T, q *Queue) []string {
+				q.Play(files("a", "b"), 0)
+				return []string{path(q.Previous())}
+			},
+			played:   []string{""},
+			current:  "a",
+			upcoming: []string{"b"},
+		},
+		{
+			name: "play next, add, move and remove",
+			do: func(t *testing.T, q *Queue) []string {
+				q.Play(files("a", "b"), 0)
+				q.PlayNext(files("x", "y")...)
+				q.Add(files("z")...)
+				q.Move(3, 0)
+				q.Remove(1)
+				return nil
+			},
+			current:  "a",
+			upcoming: []string{"z", "y", "b"},
+		},
+		{
+			name: "moving the same track back and forth",
+			do: func(t *testing.T, q *Queue) []string {
+				q.Play(files("a", "b", "c", "d"), 0)
+				for range 40 {
+					q.Move(0, 1)
+				}
+				return nil
+			},
+			current:  "a",
+			upcoming: []string{"b", "c", "d"},
+		},
+		{
+			name: "shuffling off puts tracks back in the order added",
+			do: func(t *testing.T, q *Queue) []string {
+				q.Play(files("a", "b", "c", "d", "e"), 0)
+				q.Shuffle(true)
+				q.Shuffle(false)
+				return nil
+			},
+			current:  "a",
+			upcoming: []string{"b", "c", "d", "e"},
+		},
+		{
+			name: "clear",
+			do: func(t *testing.T, q *Queue) []string {
+				q.Play(files("a", "b", "c"), 0)
+				q.Clear()
+				return []string{path(q.Peek())}
+			},
+			played:  []string{""},
+			current: "a",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := open(t)
+
+			q, err := New(db, byPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			played := test.do(t, q)
+			if !slices.Equal(played, test.played) {
+				t.Errorf("played %q, want %q", played, test.played)
+			}
+
+			// What was stored is what was left.
+			loaded, err := New(db, byPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, q := range []*Queue{q, loaded} {
+				snapshot := q.Snapshot()
+
+				var current string
+				if snapshot.Current != nil {
+					current = snapshot.Current.Path
+				}
+
+				if current != test.current {
+					t.Errorf("current %q, want %q", current, test.current)
+				}
+
+				if got := paths(snapshot.Upcoming); !slices.Equal(got, test.upcoming) {
+					t.Errorf("upcoming %q, want %q", got, test.upcoming)
+				}
+
+				if got := paths(snapshot.History); !slices.Equal(got, test.history) {
+					t.Errorf("history %q, want %q", got, test.history)
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	db := open(t)
+
+	q, err := New(db, byPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := make([]string, historyLimit+10)
+	for i := range list {
+		list[i] = string(rune('a' + i%26))
+	}
+
+	_, err = q.Play(byPath(list), len(list)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Next()
+
+	loaded, err := New(db, byPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := paths(loaded.Snapshot().History)
+	want := list[len(list)-historyLimit:]
+	if !slices.Equal(history, want) {
+		t.Errorf("history has %d tracks ending in %q, want %d ending in %q", len(history), history[len(history)-1], len(want), want[len(want)-1])
+	}
+}
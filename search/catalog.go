@@ -0,0 +1,198 @@
+package search
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bh90210/super/server/api"
+)
+
+// Order is an order the library can be listed in.
+type Order int
+
+const (
+	ByPath Order = iota
+	// ByArtist orders by artist, album and track number.
+	ByArtist
+)
+
+// catalog is the library in memory. Files are looked up by path, and the
+// indexes keep them sorted in every order as files come and go, so listing
+// doesn't sort. It is not safe for concurrent use, Search guards it.
+type catalog struct {
+	files map[string]*api.File
+	// sources are the paths of the files of each source.
+	sources map[string]map[string]struct{}
+	indexes [2]index
+}
+
+// index is the files in one order.
+type index struct {
+	files   []*api.File
+	compare func(a, b *api.File) int
+}
+
+func newCatalog() *catalog {
+	return &catalog{
+		files:   make(map[string]*api.File),
+		sources: make(map[string]map[string]struct{}),
+		indexes: [2]index{
+			ByPath:   {compare: comparePath},
+			ByArtist: {compare: compareArtist},
+		},
+	}
+}
+
+func comparePath(a, b *api.File) int {
+	return strings.Compare(a.Path, b.Path)
+}
+
+// compareArtist falls back to the path so no two files are equal, which
+// finds the exact file when searching the index.
+func compareArtist(a, b *api.File) int {
+	return cmp.Or(
+		compareFold(a.Artist, b.Artist),
+		compareFold(a.Album, b.Album),
+		cmp.Compare(a.TrackNumber, b.TrackNumber),
+		strings.Compare(a.Path, b.Path),
+	)
+}
+
+// compareFold compares a and b ignoring case, without allocating.
+func compareFold(a, b string) int {
+	for a != "" && b != "" {
+		ra, sa := utf8.DecodeRuneInString(a)
+		rb, sb := utf8.DecodeRuneInString(b)
+
+		if c := cmp.Compare(unicode.ToLower(ra), unicode.ToLower(rb)); c != 0 {
+			return c
+		}
+
+		a, b = a[sa:], b[sb:]
+	}
+
+	return cmp.Compare(len(a), len(b))
+}
+
+func (c *catalog) get(path string) (*api.File, bool) {
+	file, ok := c.files[path]
+	return file, ok
+}
+
+func (c *catalog) len() int {
+	return len(c.files)
+}
+
+// list returns the files in order. The files are shared, they must not be
+// modified.
+func (c *catalog) list(order Order) []*api.File {
+	return slices.Clone(c.indexes[order].files)
+}
+
+// source returns the files of a source.
+func (c *catalog) source(source string) []*api.File {
+	var files []*api.File
+	for path := range c.sources[source] {
+		files = append(files, c.files[path])
+	}
+
+	return files
+}
+
+// bulk is the share of the library a change has to reach for the indexes
+// to be sorted again rather than changed one file at a time.
+const bulk = 16
+
+// put adds files, replacing the ones with the same path. Of files with the
+// same path the last one is kept.
+func (c *catalog) put(files ...*api.File) {
+	unique := make(map[string]int, len(files))
+	for i, file := range files {
+		unique[file.Path] = i
+	}
+
+	if len(unique) < len(files) {
+		files = slices.DeleteFunc(slices.Clone(files), func(file *api.File) bool {
+			return files[unique[file.Path]] != file
+		})
+	}
+
+	var replaced []string
+	for _, file := range files {
+		if _, ok := c.files[file.Path]; ok {
+			replaced = append(replaced, file.Path)
+		}
+	}
+
+	c.remove(replaced...)
+
+	resort := len(files) > c.len()/bulk
+	for _, file := range files {
+		c.files[file.Path] = file
+
+		paths, ok := c.sources[file.Source]
+		if !ok {
+			paths = make(map[string]struct{})
+			c.sources[file.Source] = paths
+		}
+
+		paths[file.Path] = struct{}{}
+	}
+
+	for i := range c.indexes {
+		x := &c.indexes[i]
+		if resort {
+			x.files = append(x.files, files...)
+			slices.SortFunc(x.files, x.compare)
+			continue
+		}
+
+		for _, file := range files {
+			at, _ := slices.BinarySearchFunc(x.files, file, x.compare)
+			x.files = slices.Insert(x.files, at, file)
+		}
+	}
+}
+
+// remove removes the files with these paths, if there are any.
+func (c *catalog) remove(paths ...string) {
+	var removed []*api.File
+	for _, path := range paths {
+		file, ok := c.files[path]
+		if !ok {
+			continue
+		}
+
+		removed = append(removed, file)
+
+		delete(c.files, path)
+		delete(c.sources[file.Source], path)
+		if len(c.sources[file.Source]) == 0 {
+			delete(c.sources, file.Source)
+		}
+	}
+
+	if len(removed) == 0 {
+		return
+	}
+
+	for i := range c.indexes {
+		x := &c.indexes[i]
+		if len(removed) > len(x.files)/bulk {
+			x.files = slices.DeleteFunc(x.files, func(file *api.File) bool {
+				return c.files[file.Path] != file
+			})
+			continue
+		}
+
+		for _, file := range removed {
+			at, ok := slices.BinarySearchFunc(x.files, file, x.compare)
+			if ok {
+				x.files = slices.Delete(x.files, at, at+1)
+			}
+		}
+	}
+}
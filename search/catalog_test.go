@@ -0,0 +1,185 @@
+package search
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/bh90210/super/server/api"
+)
+
+// collection returns n files from source, spread over a few artists and
+// albums, named so they don't come in any order.
+func collection(source string, n int) []*api.File {
+	files := make([]*api.File, n)
+	for i := range files {
+		files[i] = &api.File{
+			Path:        fmt.Sprintf("/%s/%03d.flac", source, (i*37)%n),
+			Source:      source,
+			Artist:      fmt.Sprintf("Artist %d", i%5),
+			Album:       fmt.Sprintf("album %d", i%3),
+			TrackNumber: uint32(i % 11),
+		}
+	}
+
+	return files
+}
+
+func pathsOf(files []*api.File) []string {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+
+	return paths
+}
+
+func TestCatalog(t *testing.T) {
+	tests := []struct {
+		name string
+		do   func(c *catalog)
+		// want is the path of every file left and its artist, sources the
+		// number of files of each source.
+		want    map[string]string
+		sources map[string]int
+	}{
+		{
+			name: "bulk put into an empty catalog",
+			do: func(c *catalog) {
+				c.put(collection("a", 100)...)
+			},
+			sources: map[string]int{"a": 100},
+		},
+		{
+			name: "incremental puts",
+			do: func(c *catalog) {
+				c.put(collection("a", 100)...)
+				for _, file := range collection("b", 5) {
+					c.put(file)
+				}
+			},
+			sources: map[string]int{"a": 100, "b": 5},
+		},
+		{
+			name: "incremental put replacing a file",
+			do: func(c *catalog) {
+				c.put(collection("a", 100)...)
+				c.put(&api.File{Path: "/a/042.flac", Source: "a", Artist: "Zz"})
+			},
+			want:    map[string]string{"/a/042.flac": "Zz"},
+			sources: map[string]int{"a": 100},
+		},
+		{
+			name: "bulk put replacing files",
+			do: func(c *catalog) {
+				c.put(collection("a", 100)...)
+
+				replaced := collection("a", 50)
+				for _, file := range replaced {
+					file.Artist = "Aa"
+				}
+				c.put(replaced...)
+			},
+			want:    map[string]string{"/a/000.flac": "Aa", "/a/049.flac": "Aa"},
+			sources: map[string]int{"a": 100},
+		},
+		{
+			name: "put keeps the last of the same path",
+			do: func(c *catalog) {
+				c.put(collection("a", 100)...)
+				c.put(
+					&api.File{Path: "/a/001.flac", Source: "a", Artist: "First"},
+					&api.File{Path: "/new.flac", Source: "b", Artist: "First"},
+					&api.File{Path: "/a/001.flac", Source: "a", Artist: "Last"},
+					&api.File{Path: "/new.flac", Source: "b", Artist: "Last"},
+				)
+			},
+			want:    map[string]string{"/a/001.flac": "Last", "/new.flac": "Last"},
+			sources: map[string]int{"a": 100, "b": 1},
+		},
+		{
+			name: "put moving a file to another source",
+			do: func(c *catalog) {
+				c.put(collection("a", 100)...)
+				c.put(&api.File{Path: "/a/007.flac", Source: "b"})
+			},
+			sources: map[string]int{"a": 99, "b": 1},
+		},
+		{
+			name: "incremental removes",
+			do: func(c *catalog) {
+				c.put(collection("a", 100)...)
+				c.remove("/a/000.flac", "/a/099.flac")
+				c.remove("/a/050.flac")
+			},
+			sources: map[string]int{"a": 97},
+		},
+		{
+			name: "bulk remove",
+			do: func(c *catalog) {
+				c.put(collection("a", 100)...)
+				c.put(collection("b", 10)...)
+				c.remove(pathsOf(collection("a", 100))...)
+			},
+			sources: map[string]int{"b": 10},
+		},
+		{
+			name: "remove unknown paths",
+			do: func(c *catalog) {
+				c.put(collection("a", 10)...)
+				c.remove("/nope.flac", "/a/100.flac")
+			},
+			sources: map[string]int{"a": 10},
+		},
+		{
+			name: "remove everything",
+			do: func(c *catalog) {
+				c.put(collection("a", 10)...)
+				c.put(collection("b", 10)...)
+				c.remove(pathsOf(collection("b", 10))...)
+				c.remove(pathsOf(collection("a", 10))...)
+			},
+			sources: map[string]int{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCatalog()
+			test.do(c)
+
+			for path, artist := range test.want {
+				file, ok := c.get(path)
+				if !ok || file.Artist != artist {
+					t.Errorf("get(%q) = %v, %v, want artist %q", path, file, ok, artist)
+				}
+			}
+
+			sources := make(map[string]int)
+			for source := range c.sources {
+				files := c.source(source)
+				sources[source] = len(files)
+
+				for _, file := range files {
+					if file == nil || file.Source != source {
+						t.Errorf("source %q has %v", source, file)
+					}
+				}
+			}
+
+			if !maps.Equal(sources, test.sources) {
+				t.Errorf("sources %v, want %v", sources, test.sources)
+			}
+
+			// Every index has exactly the files, in order.
+			all := slices.Collect(maps.Values(c.files))
+			for _, order := range []Order{ByPath, ByArtist} {
+				want := slices.SortedFunc(slices.Values(all), c.indexes[order].compare)
+				if got := c.list(order); !slices.Equal(got, want) {
+					t.Errorf("order %d lists %q, want %q", order, pathsOf(got), pathsOf(want))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package search
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/blevesearch/bleve"
+	bleveQuery "github.com/blevesearch/bleve/search/query"
+)
+
+// filters are the fields that can be filtered on with field:value, by the
+// name typed. Text fields match the value as a phrase, format has to match
+// exactly and the rest take comparisons like year:>1995 and ranges like
+// year:1990..1999.
+var filters = map[string]func(value string) bleveQuery.Query{
+	"artist":   phraseFilter(fieldArtist),
+	"album":    phraseFilter(fieldAlbum),
+	"title":    phraseFilter(fieldTitle),
+	"track":    phraseFilter(fieldTitle),
+	"genre":    phraseFilter(fieldGenre),
+	"path":     phraseFilter(fieldPath),
+	"format":   formatFilter,
+	"year":     numericFilter(fieldYear, parseNumber),
+	"number":   numericFilter(fieldNumber, parseNumber),
+	"duration": numericFilter(fieldDuration, parseSeconds),
+	"added":    dateFilter(fieldAdded),
+}
+
+// sorts are the fields that can be sorted by with sort:field, or
+// sort:-field for descending, by the name typed.
+var sorts = map[string]string{
+	"artist":   fieldArtistKeyword,
+	"album":    fieldAlbumKeyword,
+	"title":    fieldTitleKeyword,
+	"track":    fieldTitleKeyword,
+	"genre":    fieldGenre,
+	"format":   fieldFormat,
+	"year":     fieldYear,
+	"number":   fieldNumber,
+	"duration": fieldDuration,
+	"added":    fieldAdded,
+	"score":    "_score",
+}
+
+// defaultSort orders the results of filters without any text to match,
+// there is no score to go by.
+var defaultSort = []string{fieldArtistKeyword, fieldAlbumKeyword, fieldNumber}
+
+// parsed is what was typed, split into the text to match, the filters and
+// the order asked for.
+type parsed struct {
+	text    string
+	filters []bleveQuery.Query
+	sort    []string
+}
+
+// parse picks the filters and sort order out of input. A filter of an
+// unknown field, or with a value that doesn't parse yet because it is
+// still being typed, is left out rather than failing the search.
+func parse(input string) parsed {
+	var p parsed
+	var text []string
+	for _, token := range split(input) {
+		name, value, ok := strings.Cut(token, ":")
+		name = strings.ToLower(name)
+		value = strings.Trim(value, `"`)
+
+		if ok && name == "sort" {
+			field := strings.TrimPrefix(value, "-")
+			if sorted, ok := sorts[strings.ToLower(field)]; ok {
+				if strings.HasPrefix(value, "-") {
+					sorted = "-" + sorted
+				}
+
+				p.sort = append(p.sort, sorted)
+			}
+			continue
+		}
+
+		filter, known := filters[name]
+		if !ok || !known {
+			text = append(text, token)
+			continue
+		}
+
+		if q := filter(value); q != nil {
+			p.filters = append(p.filters, q)
+		}
+	}
+
+	p.text = strings.Join(text, " ")
+
+	return p
+}
+
+// split splits input on spaces, except inside double quotes.
+func split(input string) []string {
+	var tokens []string
+	var quoted bool
+	start := -1
+	for i, r := range input {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			if start < 0 {
+				start = i
+			}
+
+		case unicode.IsSpace(r) && !quoted:
+			if start >= 0 {
+				tokens = append(tokens, input[start:i])
+				start = -1
+			}
+
+		case start < 0:
+			start = i
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, input[start:])
+	}
+
+	return tokens
+}
+
+func phraseFilter(field string) func(string) bleveQuery.Query {
+	return func(value string) bleveQuery.Query {
+		if strings.TrimSpace(value) == "" {
+			return nil
+		}
+
+		q := bleve.NewMatchPhraseQuery(value)
+		q.SetField(field)
+
+		return q
+	}
+}
+
+func formatFilter(value string) bleveQuery.Query {
+	if value == "" {
+		return nil
+	}
+
+	q := bleve.NewTermQuery(strings.ToLower(value))
+	q.SetField(fieldFormat)
+
+	return q
+}
+
+// numericFilter compares field to a value parsed by parse.
+func numericFilter(field string, parse func(string) (float64, bool)) func(string) bleveQuery.Query {
+	return func(value string) bleveQuery.Query {
+		op, value := comparison(value)
+
+		if op == ".." {
+			from, to, _ := strings.Cut(value, "..")
+			min, okMin := parse(from)
+			max, okMax := parse(to)
+			if !okMin || !okMax {
+				return nil
+			}
+
+			return numericRange(field, &min, &max, true, true)
+		}
+
+		v, ok := parse(value)
+		if !ok {
+			return nil
+		}
+
+		switch op {
+		case ">":
+			return numericRange(field, &v, nil, false, false)
+		case ">=":
+			return numericRange(field, &v, nil, true, false)
+		case "<":
+			return numericRange(field, nil, &v, false, false)
+		case "<=":
+			return numericRange(field, nil, &v, false, true)
+		default:
+			return numericRange(field, &v, &v, true, true)
+		}
+	}
+}
+
+func numericRange(field string, min, max *float64, minInclusive, maxInclusive bool) bleveQuery.Query {
+	q := bleve.NewNumericRangeInclusiveQuery(min, max, &minInclusive, &maxInclusive)
+	q.SetField(field)
+
+	return q
+}
+
+// dateFilter compares field to a date, a year, a month or a day. Equal
+// means within it.
+func dateFilter(field string) func(string) bleveQuery.Query {
+	return func(value string) bleveQuery.Query {
+		op, value := comparison(value)
+
+		if op == ".." {
+			from, to, _ := strings.Cut(value, "..")
+			start, _, okStart := parseDate(from)
+			_, end, okEnd := parseDate(to)
+			if !okStart || !okEnd {
+				return nil
+			}
+
+			return dateRange(field, start, end)
+		}
+
+		start, end, ok := parseDate(value)
+		if !ok {
+			return nil
+		}
+
+		switch op {
+		case ">":
+			return dateRange(field, end, time.Time{})
+		case ">=":
+			return dateRange(field, start, time.Time{})
+		case "<":
+			return dateRange(field, time.Time{}, start)
+		case "<=":
+			return dateRange(field, time.Time{}, end)
+		default:
+			return dateRange(field, start, end)
+		}
+	}
+}
+
+// dateRange matches from start up to but not including end, a zero time
+// leaves that side open.
+func dateRange(field string, start, end time.Time) bleveQuery.Query {
+	q := bleve.NewDateRangeQuery(start, end)
+	q.SetField(field)
+
+	return q
+}
+
+// comparison splits the operator off a value: >, >=, <, <=, .. for a range
+// or none for equal.
+func comparison(value string) (string, string) {
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if v, ok := strings.CutPrefix(value, op); ok {
+			return op, v
+		}
+	}
+
+	if strings.Contains(value, "..") {
+		return "..", value
+	}
+
+	return "", value
+}
+
+func parseNumber(value string) (float64, bool) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+
+	return v, true
+}
+
+// parseSeconds reads a duration as 5m, 3m30s, 4:30 or plain seconds.
+func parseSeconds(value string) (float64, bool) {
+	if v, ok := parseNumber(value); ok {
+		return v, true
+	}
+
+	if minutes, seconds, ok := strings.Cut(value, ":"); ok {
+		m, okM := parseNumber(minutes)
+		s, okS := parseNumber(seconds)
+		return m*60 + s, okM && okS
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return d.Seconds(), true
+}
+
+// parseDate reads a year, a month or a day and returns when it starts and
+// when the next one does.
+func parseDate(value string) (time.Time, time.Time, bool) {
+	for _, layout := range []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	} {
+		t, err := time.ParseInLocation(layout.layout, value, time.Local)
+		// The index stores dates as nanoseconds, which only go so far.
+		if err == nil && t.Year() > 1677 && t.Year() < 2262 {
+			return t, t.AddDate(layout.years, layout.months, layout.days), true
+		}
+	}
+
+	return time.Time{}, time.Time{}, false
+}
@@ -0,0 +1,165 @@
+package search
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	bleveQuery "github.com/blevesearch/bleve/search/query"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"daft punk", []string{"daft", "punk"}},
+		{"  daft \t punk  ", []string{"daft", "punk"}},
+		{`"daft punk"`, []string{`"daft punk"`}},
+		{`artist:"daft punk" year:2001`, []string{`artist:"daft punk"`, "year:2001"}},
+		{`title:"one more time`, []string{`title:"one more time`}},
+		{`a"b c"d e`, []string{`a"b c"d`, "e"}},
+	}
+
+	for _, test := range tests {
+		got := split(test.input)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("split(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		text    string
+		filters []bleveQuery.Query
+		sort    []string
+	}{
+		{
+			input: "daft punk",
+			text:  "daft punk",
+		},
+		{
+			input:   `artist:"daft punk" discovery`,
+			text:    "discovery",
+			filters: []bleveQuery.Query{phraseFilter(fieldArtist)("daft punk")},
+		},
+		{
+			input:   "Year:1990..1999",
+			filters: []bleveQuery.Query{numericRange(fieldYear, float(1990), float(1999), true, true)},
+		},
+		{
+			input:   "year:>=1995 number:<3",
+			filters: []bleveQuery.Query{numericRange(fieldYear, float(1995), nil, true, false), numericRange(fieldNumber, nil, float(3), false, false)},
+		},
+		{
+			input:   "duration:3m..4:30",
+			filters: []bleveQuery.Query{numericRange(fieldDuration, float(180), float(270), true, true)},
+		},
+		{
+			input:   "added:<=2024-03",
+			filters: []bleveQuery.Query{dateRange(fieldAdded, time.Time{}, date(2024, time.April, 1))},
+		},
+		{
+			input:   "added:2023..2024-02-28",
+			filters: []bleveQuery.Query{dateRange(fieldAdded, date(2023, time.January, 1), date(2024, time.February, 29))},
+		},
+		{
+			input:   "format:FLAC",
+			filters: []bleveQuery.Query{formatFilter("flac")},
+		},
+		{
+			// Still being typed.
+			input: "year:19.. added:20 artist:",
+		},
+		{
+			input: "time:late sort:-year sort:Artist sort:bogus",
+			text:  "time:late",
+			sort:  []string{"-" + fieldYear, fieldArtistKeyword},
+		},
+	}
+
+	for _, test := range tests {
+		got := parse(test.input)
+
+		if got.text != test.text {
+			t.Errorf("parse(%q) text %q, want %q", test.input, got.text, test.text)
+		}
+
+		if !reflect.DeepEqual(got.filters, test.filters) {
+			t.Errorf("parse(%q) filters %+v, want %+v", test.input, got.filters, test.filters)
+		}
+
+		if !slices.Equal(got.sort, test.sort) {
+			t.Errorf("parse(%q) sort %q, want %q", test.input, got.sort, test.sort)
+		}
+	}
+}
+
+func TestParseSeconds(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+		ok    bool
+	}{
+		{"90", 90, true},
+		{"2.5", 2.5, true},
+		{"4:30", 270, true},
+		{"0:05", 5, true},
+		{"5m", 300, true},
+		{"3m30s", 210, true},
+		{"1h", 3600, true},
+		{"", 0, false},
+		{"4:", 240, false},
+		{":30", 30, false},
+		{"NaN", 0, false},
+		{"Inf", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, test := range tests {
+		got, ok := parseSeconds(test.input)
+		if ok != test.ok || (ok && got != test.want) {
+			t.Errorf("parseSeconds(%q) = %v, %v, want %v, %v", test.input, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		input string
+		start time.Time
+		end   time.Time
+		ok    bool
+	}{
+		{"2024", date(2024, time.January, 1), date(2025, time.January, 1), true},
+		{"2024-02", date(2024, time.February, 1), date(2024, time.March, 1), true},
+		{"2024-12", date(2024, time.December, 1), date(2025, time.January, 1), true},
+		{"2024-02-29", date(2024, time.February, 29), date(2024, time.March, 1), true},
+		{"2023-02-29", time.Time{}, time.Time{}, false},
+		{"2024-13", time.Time{}, time.Time{}, false},
+		{"1500", time.Time{}, time.Time{}, false},
+		{"2300", time.Time{}, time.Time{}, false},
+		{"24", time.Time{}, time.Time{}, false},
+		{"", time.Time{}, time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		start, end, ok := parseDate(test.input)
+		if ok != test.ok || !start.Equal(test.start) || !end.Equal(test.end) {
+			t.Errorf("parseDate(%q) = %v, %v, %v, want %v, %v, %v", test.input, start, end, ok, test.start, test.end, test.ok)
+		}
+	}
+}
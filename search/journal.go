@@ -0,0 +1,265 @@
+package search
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// The search index follows the files stored in Badger through a journal.
+// Every change to the files writes, in the same transaction, an entry with
+// the paths that changed. The index then takes those files from the
+// catalog in one batch, which also records the last entry it has, and the
+// entries are deleted. Entries left behind by a crash or a failed batch are
+// applied by the next change, or on start.
+
+// appliedKey is where the index stores the last journal entry it has.
+const appliedKey = "applied"
+
+func journalKey(seq uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", super.Journal, seq))
+}
+
+func encodeSeq(seq uint64) []byte {
+	return []byte(strconv.FormatUint(seq, 10))
+}
+
+// journal writes an entry with the paths changed in txn.
+func (s *Search) journal(txn *badger.Txn, paths []string) error {
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(paths)
+	if err != nil {
+		slog.Error("gob.Encode", "error", err)
+		return err
+	}
+
+	s.seq++
+
+	return txn.Set(journalKey(s.seq), buf.Bytes())
+}
+
+// entries returns the paths of every journal entry, by entry.
+func (s *Search) entries() (map[uint64][]string, error) {
+	entries := make(map[uint64][]string)
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(super.Journal)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			seq, err := strconv.ParseUint(string(item.Key()[len(prefix):]), 10, 64)
+			if err != nil {
+				return err
+			}
+
+			err = item.Value(func(v []byte) error {
+				var paths []string
+				err := gob.NewDecoder(bytes.NewReader(v)).Decode(&paths)
+				entries[seq] = paths
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		slog.Error("reading the journal", "error", err)
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// applied returns the last journal entry the index has.
+func (s *Search) applied() (uint64, error) {
+	v, err := s.index.GetInternal([]byte(appliedKey))
+	if err != nil {
+		slog.Error("index.GetInternal", "error", err)
+		return 0, err
+	}
+
+	if v == nil {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(string(v), 10, 64)
+}
+
+// replay applies the journal entries the index doesn't have yet and deletes
+// them. Must be called with applying held.
+func (s *Search) replay() error {
+	entries, err := s.entries()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	applied, err := s.applied()
+	if err != nil {
+		return err
+	}
+
+	last := applied
+	paths := make(map[string]struct{})
+	for seq, changed := range entries {
+		if seq <= applied {
+			continue
+		}
+
+		for _, path := range changed {
+			paths[path] = struct{}{}
+		}
+
+		last = max(last, seq)
+	}
+
+	if last > applied {
+		err = s.commit(last, paths)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.forget(entries)
+}
+
+// commit brings the paths up to date in the index, as they are in the
+// catalog, and records seq as applied in the same batch.
+func (s *Search) commit(seq uint64, paths map[string]struct{}) error {
+	batch := s.index.NewBatch()
+
+	s.mu.Lock()
+	for path := range paths {
+		file, ok := s.catalog.get(path)
+		if !ok {
+			batch.Delete(path)
+			continue
+		}
+
+		err := batch.Index(path, newDocument(file))
+		if err != nil {
+			s.mu.Unlock()
+			slog.Error("batch.Index", "path", path, "error", err)
+			return err
+		}
+	}
+	s.mu.Unlock()
+
+	batch.SetInternal([]byte(appliedKey), encodeSeq(seq))
+
+	err := s.index.Batch(batch)
+	if err != nil {
+		slog.Error("index.Batch", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// forget deletes journal entries the index has.
+func (s *Search) forget(entries map[uint64][]string) error {
+	err := s.db.Update(func(txn *badger.Txn) error {
+		for seq := range entries {
+			err := txn.Delete(journalKey(seq))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		slog.Error("badger.Delete", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// recover brings the index in step with Badger on start. The journal is
+// replayed, and if that fails or the index still doesn't have the files of
+// the catalog it is rebuilt from it.
+func (s *Search) recover(rebuilt bool) error {
+	s.applying.Lock()
+	defer s.applying.Unlock()
+
+	entries, err := s.entries()
+	if err != nil {
+		return err
+	}
+
+	s.seq, err = s.applied()
+	if err != nil {
+		return err
+	}
+
+	for seq := range entries {
+		s.seq = max(s.seq, seq)
+	}
+
+	if rebuilt {
+		return s.restore(entries)
+	}
+
+	err = s.replay()
+	if err != nil {
+		slog.Warn("replaying the search journal failed, rebuilding", "error", err)
+		return s.rebuild()
+	}
+
+	count, err := s.index.DocCount()
+	if err != nil {
+		slog.Error("index.DocCount", "error", err)
+		return err
+	}
+
+	s.mu.Lock()
+	files := s.catalog.len()
+	s.mu.Unlock()
+
+	if count != uint64(files) {
+		slog.Warn("search index out of step, rebuilding", "indexed", count, "files", files)
+		return s.rebuild()
+	}
+
+	return nil
+}
+
+// rebuild replaces the index with a new one of every file in the catalog.
+func (s *Search) rebuild() error {
+	s.index.Close()
+
+	index, err := newIndex(super.LocalStorage(super.SearchStore))
+	if err != nil {
+		return err
+	}
+
+	s.index = index
+
+	entries, err := s.entries()
+	if err != nil {
+		return err
+	}
+
+	return s.restore(entries)
+}
+
+// restore indexes every file in an empty index, which makes the journal
+// entries obsolete.
+func (s *Search) restore(entries map[uint64][]string) error {
+	err := s.reindex(s.seq)
+	if err != nil {
+		return err
+	}
+
+	return s.forget(entries)
+}
@@ -0,0 +1,179 @@
+package search
+
+import (
+	"os"
+	"testing"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+// start starts search on db the way the app does, with the index under a
+// HOME of the test and a server that is never reached.
+func start(t *testing.T, db *badger.DB) *Search {
+	t.Helper()
+
+	conn, err := grpc.NewClient("passthrough:///127.0.0.1:1", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	s, err := NewSearch(db, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// crash stores add and removes remove in Badger, journaled, and stops
+// before the index has them, as if the app died in between.
+func crash(t *testing.T, s *Search, add []*api.File, remove []string) {
+	t.Helper()
+
+	s.applying.Lock()
+	defer s.applying.Unlock()
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		for _, path := range remove {
+			err := txn.Delete([]byte(super.File + path))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, file := range add {
+			b, err := proto.Marshal(file)
+			if err != nil {
+				return err
+			}
+
+			err = txn.Set([]byte(super.File+file.Path), b)
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.journal(txn, append(remove, pathsOf(add)...))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.index.Close()
+}
+
+// indexed reports whether the index has a document for path.
+func indexed(t *testing.T, s *Search, path string) bool {
+	t.Helper()
+
+	doc, err := s.index.Document(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return doc != nil
+}
+
+func TestJournalReplay(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// The app makes its storage before search starts.
+	err := os.MkdirAll(super.LocalStorage(), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	s := start(t, db)
+
+	files := collection("server", 20)
+	err = s.apply(files, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := s.applied()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A rebuilt index wouldn't have it.
+	err = s.index.SetInternal([]byte("marker"), []byte("kept"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added := &api.File{Path: "/server/new.flac", Source: "server", Artist: "New"}
+	crash(t, s, []*api.File{added}, []string{files[0].Path})
+
+	s = start(t, db)
+	defer s.index.Close()
+
+	if !indexed(t, s, added.Path) {
+		t.Errorf("%s, stored before the crash, isn't indexed", added.Path)
+	}
+
+	if indexed(t, s, files[0].Path) {
+		t.Errorf("%s, removed before the crash, is still indexed", files[0].Path)
+	}
+
+	count, err := s.index.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != uint64(len(files)) {
+		t.Errorf("%d documents, want %d", count, len(files))
+	}
+
+	// Replayed rather than rebuilt, the entry is recorded as applied.
+	marker, err := s.index.GetInternal([]byte("marker"))
+	if err != nil || string(marker) != "kept" {
+		t.Errorf("the index was rebuilt, marker %q, %v", marker, err)
+	}
+
+	got, err := s.applied()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != applied+1 {
+		t.Errorf("applied entry %d, want %d", got, applied+1)
+	}
+
+	entries, err := s.entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("%d journal entries left", len(entries))
+	}
+
+	// Changes after the replay carry on from the entry it applied.
+	err = s.apply(nil, []*api.File{added}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if indexed(t, s, added.Path) {
+		t.Errorf("%s is still indexed after removing it", added.Path)
+	}
+
+	if got, _ := s.applied(); got != applied+2 {
+		t.Errorf("applied entry %d, want %d", got, applied+2)
+	}
+}
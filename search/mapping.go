@@ -0,0 +1,247 @@
+package search
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/analysis/char/asciifolding"
+	"github.com/blevesearch/bleve/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/mapping"
+)
+
+// indexVersion is the version of the mapping below. Bump it whenever the
+// mapping changes, indexes of another version are rebuilt on start.
+const indexVersion = 3
+
+// versionKey is where the version is stored inside the index.
+const versionKey = "version"
+
+// folded is the analyzer of the text fields. Accents are folded away and
+// everything is lowercased, so "Björk" matches "bjork".
+const folded = "folded"
+
+// The fields of the index and how much a match in each of them counts.
+const (
+	fieldTitle  = "title"
+	fieldArtist = "artist"
+	fieldAlbum  = "album"
+	fieldPath   = "path"
+	// fieldArtistKeyword and fieldAlbumKeyword are the artist and album
+	// unanalysed, to facet by.
+	fieldArtistKeyword = "artist_keyword"
+	fieldAlbumKeyword  = "album_keyword"
+	// fieldTitleKeyword is the title unanalysed, to sort by.
+	fieldTitleKeyword = "title_keyword"
+	fieldGenre        = "genre"
+	fieldFormat       = "format"
+	fieldYear         = "year"
+	fieldNumber       = "number"
+	// fieldDuration is in seconds.
+	fieldDuration = "duration"
+	fieldAdded    = "added"
+)
+
+var boosts = map[string]float64{
+	fieldTitle:  4,
+	fieldArtist: 3,
+	fieldAlbum:  2,
+	fieldPath:   1,
+}
+
+// newDocument returns what gets indexed for a file, keyed by its path.
+// Fields the file doesn't have are left out, so an untagged year doesn't
+// match year:<2000.
+func newDocument(file *api.File) map[string]interface{} {
+	doc := map[string]interface{}{
+		fieldTitle:  file.Track,
+		fieldArtist: file.Artist,
+		fieldAlbum:  file.Album,
+		fieldPath:   file.Path,
+	}
+
+	if file.Genre != "" {
+		doc[fieldGenre] = file.Genre
+	}
+
+	if file.Format != "" {
+		doc[fieldFormat] = file.Format
+	}
+
+	if file.Year > 0 {
+		doc[fieldYear] = float64(file.Year)
+	}
+
+	if file.TrackNumber > 0 {
+		doc[fieldNumber] = float64(file.TrackNumber)
+	}
+
+	if file.DurationSeconds > 0 {
+		doc[fieldDuration] = file.DurationSeconds
+	}
+
+	if file.Added > 0 {
+		doc[fieldAdded] = time.Unix(file.Added, 0)
+	}
+
+	return doc
+}
+
+func newMapping() (mapping.IndexMapping, error) {
+	m := bleve.NewIndexMapping()
+
+	err := m.AddCustomAnalyzer(folded, map[string]interface{}{
+		"type":          custom.Name,
+		"char_filters":  []string{asciifolding.Name},
+		"tokenizer":     unicode.Name,
+		"token_filters": []string{lowercase.Name},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	text := func(includeInAll bool) *mapping.FieldMapping {
+		f := bleve.NewTextFieldMapping()
+		f.Analyzer = folded
+		f.IncludeInAll = includeInAll
+		return f
+	}
+
+	facet := func(name string) *mapping.FieldMapping {
+		f := bleve.NewTextFieldMapping()
+		f.Name = name
+		f.Analyzer = keyword.Name
+		f.IncludeInAll = false
+		f.Store = false
+		f.IncludeTermVectors = false
+		return f
+	}
+
+	number := func() *mapping.FieldMapping {
+		f := bleve.NewNumericFieldMapping()
+		f.IncludeInAll = false
+		return f
+	}
+
+	added := bleve.NewDateTimeFieldMapping()
+	added.IncludeInAll = false
+
+	doc := bleve.NewDocumentStaticMapping()
+	doc.AddFieldMappingsAt(fieldTitle, text(true), facet(fieldTitleKeyword))
+	doc.AddFieldMappingsAt(fieldArtist, text(true), facet(fieldArtistKeyword))
+	doc.AddFieldMappingsAt(fieldAlbum, text(true), facet(fieldAlbumKeyword))
+	// The path is mostly noise, it is only searched on its own field.
+	doc.AddFieldMappingsAt(fieldPath, text(false))
+	doc.AddFieldMappingsAt(fieldGenre, text(false))
+	doc.AddFieldMappingsAt(fieldFormat, facet(fieldFormat))
+	doc.AddFieldMappingsAt(fieldYear, number())
+	doc.AddFieldMappingsAt(fieldNumber, number())
+	doc.AddFieldMappingsAt(fieldDuration, number())
+	doc.AddFieldMappingsAt(fieldAdded, added)
+
+	m.DefaultMapping = doc
+	m.DefaultAnalyzer = folded
+
+	return m, nil
+}
+
+// openIndex opens the index at path, creating it if it doesn't exist or is
+// of another version. rebuilt is set when the caller has to index every
+// file again.
+func openIndex(path string) (index bleve.Index, rebuilt bool, err error) {
+	index, err = bleve.Open(path)
+	if err != nil && !errors.Is(err, bleve.ErrorIndexMetaMissing) {
+		slog.Error("bleve.Open", "error", err)
+		return nil, false, err
+	}
+
+	if err == nil {
+		version, err := index.GetInternal([]byte(versionKey))
+		if err != nil {
+			slog.Error("index.GetInternal", "error", err)
+			return nil, false, err
+		}
+
+		if string(version) == strconv.Itoa(indexVersion) {
+			return index, false, nil
+		}
+
+		slog.Info("search index out of date, rebuilding", "version", string(version), "want", indexVersion)
+
+		index.Close()
+	}
+
+	index, err = newIndex(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return index, true, nil
+}
+
+// newIndex creates an empty index at path, removing whatever is there.
+func newIndex(path string) (bleve.Index, error) {
+	err := os.RemoveAll(path)
+	if err != nil {
+		slog.Error("os.RemoveAll", "error", err)
+		return nil, err
+	}
+
+	slog.Info("creating new search index")
+
+	m, err := newMapping()
+	if err != nil {
+		slog.Error("search mapping", "error", err)
+		return nil, err
+	}
+
+	index, err := bleve.New(path, m)
+	if err != nil {
+		slog.Error("bleve.New", "error", err)
+		return nil, err
+	}
+
+	err = index.SetInternal([]byte(versionKey), []byte(strconv.Itoa(indexVersion)))
+	if err != nil {
+		slog.Error("index.SetInternal", "error", err)
+		index.Close()
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// reindex indexes every file in the catalog, after the index was rebuilt.
+// The journal is applied up to seq, the catalog already has its changes.
+func (s *Search) reindex(seq uint64) error {
+	batch := s.index.NewBatch()
+
+	s.mu.Lock()
+	files := s.catalog.list(ByPath)
+	s.mu.Unlock()
+
+	for _, file := range files {
+		err := batch.Index(file.Path, newDocument(file))
+		if err != nil {
+			slog.Error("batch.Index", "path", file.Path, "error", err)
+			return err
+		}
+	}
+
+	batch.SetInternal([]byte(appliedKey), encodeSeq(seq))
+
+	err := s.index.Batch(batch)
+	if err != nil {
+		slog.Error("index.Batch", "error", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis"
+	bleveQuery "github.com/blevesearch/bleve/search/query"
+)
+
+const (
+	// fuzzyBoost and prefixBoost scale how much a fuzzy or a prefix match
+	// counts against an exact one.
+	fuzzyBoost  = 0.3
+	prefixBoost = 0.6
+	// phraseBoost is how much more the words in the order typed count.
+	phraseBoost = 2
+)
+
+// plan turns what was typed into a query and the order of its results,
+// the score if nil. It never fails, anything that isn't a word or a filter
+// is dropped, so it can run on every key stroke. Every word has to match
+// one of the fields, exactly or within a typo or two. The last word is
+// matched as a prefix too since it is likely still being typed. Matching
+// the whole text as a phrase ranks higher. Filters such as year:>1995
+// narrow the results down. A nil query means there is nothing to search
+// for.
+func plan(input string, analyzer *analysis.Analyzer) (bleveQuery.Query, []string) {
+	parsed := parse(input)
+	text := sanitise(parsed.text)
+
+	var words []string
+	for _, token := range analyzer.Analyze([]byte(text)) {
+		words = append(words, string(token.Term))
+	}
+
+	if len(words) == 0 && len(parsed.filters) == 0 {
+		return nil, nil
+	}
+
+	q := bleve.NewBooleanQuery()
+	for i, word := range words {
+		q.AddMust(wordQuery(word, i == len(words)-1))
+	}
+
+	q.AddMust(parsed.filters...)
+
+	if len(words) > 1 {
+		for field, boost := range boosts {
+			phrase := bleve.NewMatchPhraseQuery(text)
+			phrase.SetField(field)
+			phrase.SetBoost(boost * phraseBoost)
+			q.AddShould(phrase)
+		}
+	}
+
+	sort := parsed.sort
+	if len(sort) == 0 && len(words) == 0 {
+		sort = defaultSort
+	}
+
+	return q, sort
+}
+
+// wordQuery matches word in any of the fields.
+func wordQuery(word string, last bool) bleveQuery.Query {
+	var queries []bleveQuery.Query
+	for field, boost := range boosts {
+		term := bleve.NewTermQuery(word)
+		term.SetField(field)
+		term.SetBoost(boost)
+		queries = append(queries, term)
+
+		if fuzziness := fuzziness(word); fuzziness > 0 {
+			fuzzy := bleve.NewFuzzyQuery(word)
+			fuzzy.SetField(field)
+			fuzzy.SetFuzziness(fuzziness)
+			fuzzy.SetBoost(boost * fuzzyBoost)
+			queries = append(queries, fuzzy)
+		}
+
+		if last {
+			prefix := bleve.NewPrefixQuery(word)
+			prefix.SetField(field)
+			prefix.SetBoost(boost * prefixBoost)
+			queries = append(queries, prefix)
+		}
+	}
+
+	return bleve.NewDisjunctionQuery(queries...)
+}
+
+// fuzziness is the edit distance allowed for word. Short words would match
+// nearly everything, longer ones are allowed two so swapped letters match.
+func fuzziness(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 3:
+		return 0
+	case n < 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// sanitise keeps letters, digits and spaces. Query syntax, quotes and
+// control characters are left for the analyzer to split on.
+func sanitise(input string) string {
+	return strings.Join(strings.FieldsFunc(input, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	}), " ")
+}
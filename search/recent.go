@@ -0,0 +1,166 @@
+package search
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrInvalidKind = errors.New("invalid kind")
+
+// recentKey is where the recent searches are stored.
+const recentKey = super.Recent + "searches"
+
+// recentLimit is how many recent searches are kept.
+const recentLimit = 50
+
+// Kind is what a recent search is of.
+type Kind string
+
+const (
+	// Query is something typed.
+	Query  Kind = "query"
+	Song   Kind = "song"
+	Artist Kind = "artist"
+	Album  Kind = "album"
+)
+
+// Recent is something searched for, or a result picked from a search.
+type Recent struct {
+	Kind Kind
+	// Name is what was typed, the artist, the album or the path of the song.
+	Name string
+	// File is the song, only set for songs.
+	File *api.File
+	At   time.Time
+}
+
+// recent is a recent search as stored, songs are kept by path.
+type recent struct {
+	Kind Kind
+	Name string
+	At   time.Time
+}
+
+func (s *Search) loadRecent() error {
+	return s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(recentKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(v []byte) error {
+			return gob.NewDecoder(bytes.NewReader(v)).Decode(&s.recent)
+		})
+	})
+}
+
+// AddRecent puts a search at the top of the recent ones, moving it there
+// if it was searched before.
+func (s *Search) AddRecent(kind Kind, name string) error {
+	switch kind {
+	case Query, Song, Artist, Album:
+	default:
+		return ErrInvalidKind
+	}
+
+	if name == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recent = slices.DeleteFunc(s.recent, func(r recent) bool {
+		return r.Kind == kind && r.Name == name
+	})
+
+	s.recent = slices.Insert(s.recent, 0, recent{Kind: kind, Name: name, At: time.Now()})
+	if len(s.recent) > recentLimit {
+		s.recent = s.recent[:recentLimit]
+	}
+
+	return s.storeRecent()
+}
+
+// Recent returns the recent searches, the latest first. Songs no longer in
+// the library are left out.
+func (s *Search) Recent() []Recent {
+	cached := s.cachedFiles()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var searches []Recent
+	for _, r := range s.recent {
+		search := Recent{Kind: r.Kind, Name: r.Name, At: r.At}
+
+		if r.Kind == Song {
+			known, ok := s.catalog.get(r.Name)
+			if !ok {
+				continue
+			}
+
+			search.File = proto.Clone(known).(*api.File)
+			search.File.Cached = cached(search.File)
+		}
+
+		searches = append(searches, search)
+	}
+
+	return searches
+}
+
+// RemoveRecent removes a search from the recent ones.
+func (s *Search) RemoveRecent(kind Kind, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recent = slices.DeleteFunc(s.recent, func(r recent) bool {
+		return r.Kind == kind && r.Name == name
+	})
+
+	return s.storeRecent()
+}
+
+// ClearRecent removes every recent search.
+func (s *Search) ClearRecent() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recent = nil
+
+	return s.storeRecent()
+}
+
+// storeRecent must be called with mu held.
+func (s *Search) storeRecent() error {
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(s.recent)
+	if err != nil {
+		slog.Error("gob.Encode", "error", err)
+		return err
+	}
+
+	err = s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(recentKey), buf.Bytes())
+	})
+	if err != nil {
+		slog.Error("badger.Set", "error", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package search
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/search"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultSize is how many entries a section has unless asked otherwise.
+const defaultSize = 10
+
+// Page is the part of a section to return.
+type Page struct {
+	Offset int
+	// Size is the number of entries, defaultSize if 0.
+	Size int
+}
+
+func (p Page) size() int {
+	if p.Size <= 0 {
+		return defaultSize
+	}
+
+	return p.Size
+}
+
+// Pages are the pages asked for, per section.
+type Pages struct {
+	Artists   Page
+	Albums    Page
+	Songs     Page
+	Playlists Page
+}
+
+// Hit is a song that matched.
+type Hit struct {
+	File  *api.File
+	Score float64
+	// Highlights are the fragments of the fields that matched, with the
+	// matches marked.
+	Highlights map[string][]string
+}
+
+// Group is an artist or album that matched, with the number of songs.
+type Group struct {
+	Name  string
+	Count int
+}
+
+// Songs is a page of songs.
+type Songs struct {
+	Hits   []Hit
+	Offset int
+	Total  uint64
+}
+
+// PlaylistGroups is a page of playlists.
+type PlaylistGroups struct {
+	Playlists []*api.Playlist
+	Offset    int
+	// More is set if there are more after this page.
+	More bool
+}
+
+// Groups is a page of artists or albums.
+type Groups struct {
+	Groups []Group
+	Offset int
+	// More is set if there are more after this page.
+	More bool
+}
+
+// Results is a search grouped into the sections the frontend shows.
+type Results struct {
+	Query string
+	// Top is the best song, only set on the first page of songs.
+	Top     *Hit
+	Artists Groups
+	Albums  Groups
+	Songs   Songs
+	// Playlists are the ones named after what was typed.
+	Playlists PlaylistGroups
+}
+
+// SetPlaylists sets where the playlists searched by name come from.
+func (s *Search) SetPlaylists(playlists func() []*api.Playlist) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.playlists = playlists
+}
+
+// Grouped searches for what was typed and groups the hits into sections,
+// each paged on its own. Artists and albums are aggregated over every
+// song that matched.
+func (s *Search) Grouped(query string, pages Pages) (*Results, error) {
+	results := &Results{
+		Query:     query,
+		Artists:   Groups{Offset: pages.Artists.Offset},
+		Albums:    Groups{Offset: pages.Albums.Offset},
+		Songs:     Songs{Offset: pages.Songs.Offset},
+		Playlists: PlaylistGroups{Offset: pages.Playlists.Offset},
+	}
+
+	analyzer := s.index.Mapping().AnalyzerNamed(folded)
+
+	q, sort := plan(query, analyzer)
+	if q == nil {
+		return results, nil
+	}
+
+	results.Playlists = s.named(query, analyzer, pages.Playlists)
+
+	request := bleve.NewSearchRequestOptions(q, pages.Songs.size(), pages.Songs.Offset, false)
+	if len(sort) > 0 {
+		request.SortBy(sort)
+	}
+	request.Highlight = bleve.NewHighlightWithStyle("html")
+	request.Highlight.Fields = []string{fieldTitle, fieldArtist, fieldAlbum}
+
+	// One more than asked for tells whether there are more.
+	request.AddFacet(fieldArtist, bleve.NewFacetRequest(fieldArtistKeyword, pages.Artists.Offset+pages.Artists.size()+1))
+	request.AddFacet(fieldAlbum, bleve.NewFacetRequest(fieldAlbumKeyword, pages.Albums.Offset+pages.Albums.size()+1))
+
+	result, err := s.index.Search(request)
+	if err != nil {
+		slog.Error("index.Search", "query", query, "error", err)
+		return nil, err
+	}
+
+	results.Songs.Total = result.Total
+	results.Songs.Hits = s.hits(result.Hits)
+	if pages.Songs.Offset == 0 && len(results.Songs.Hits) > 0 {
+		top := results.Songs.Hits[0]
+		results.Top = &top
+	}
+
+	results.Artists = groups(result.Facets[fieldArtist], pages.Artists)
+	results.Albums = groups(result.Facets[fieldAlbum], pages.Albums)
+
+	return results, nil
+}
+
+// named pages the playlists with every word typed at the start of a word
+// of their name, folded like the text fields. Playlists have nothing to
+// filter on, there are none for filters.
+func (s *Search) named(query string, analyzer *analysis.Analyzer, page Page) PlaylistGroups {
+	g := PlaylistGroups{Offset: page.Offset}
+
+	s.mu.Lock()
+	playlists := s.playlists
+	s.mu.Unlock()
+
+	parsed := parse(query)
+	if playlists == nil || len(parsed.filters) > 0 {
+		return g
+	}
+
+	words := terms(analyzer, sanitise(parsed.text))
+	if len(words) == 0 {
+		return g
+	}
+
+	var matched []*api.Playlist
+	for _, playlist := range playlists() {
+		name := terms(analyzer, playlist.Name)
+		if every(words, func(word string) bool {
+			return slices.ContainsFunc(name, func(term string) bool {
+				return strings.HasPrefix(term, word)
+			})
+		}) {
+			matched = append(matched, playlist)
+		}
+	}
+
+	if page.Offset >= len(matched) {
+		return g
+	}
+
+	matched = matched[page.Offset:]
+	if len(matched) > page.size() {
+		matched, g.More = matched[:page.size()], true
+	}
+
+	g.Playlists = matched
+
+	return g
+}
+
+func terms(analyzer *analysis.Analyzer, text string) []string {
+	var terms []string
+	for _, token := range analyzer.Analyze([]byte(text)) {
+		terms = append(terms, string(token.Term))
+	}
+
+	return terms
+}
+
+func every(words []string, fn func(string) bool) bool {
+	for _, word := range words {
+		if !fn(word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hits pairs the matches with copies of their files. Matches of files
+// removed in the meantime are left out.
+func (s *Search) hits(matches search.DocumentMatchCollection) []Hit {
+	cached := s.cachedFiles()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var hits []Hit
+	for _, match := range matches {
+		known, ok := s.catalog.get(match.ID)
+		if !ok {
+			continue
+		}
+
+		file := proto.Clone(known).(*api.File)
+		file.Cached = cached(file)
+
+		hits = append(hits, Hit{
+			File:       file,
+			Score:      match.Score,
+			Highlights: highlights(match.Fragments),
+		})
+	}
+
+	return hits
+}
+
+// highlights keeps the fragments with a match in them, bleve returns the
+// other fields asked for as they are.
+func highlights(fragments search.FieldFragmentMap) map[string][]string {
+	marked := make(map[string][]string)
+	for field, values := range fragments {
+		for _, value := range values {
+			if strings.Contains(value, "<mark>") {
+				marked[field] = append(marked[field], value)
+			}
+		}
+	}
+
+	return marked
+}
+
+// groups pages the terms of a facet. Files without the field are not a
+// group.
+func groups(facet *search.FacetResult, page Page) Groups {
+	g := Groups{Offset: page.Offset}
+	if facet == nil {
+		return g
+	}
+
+	var terms []Group
+	for _, term := range facet.Terms {
+		if term.Term == "" {
+			continue
+		}
+
+		terms = append(terms, Group{Name: term.Term, Count: term.Count})
+	}
+
+	if page.Offset >= len(terms) {
+		return g
+	}
+
+	terms = terms[page.Offset:]
+	if len(terms) > page.size() {
+		terms, g.More = terms[:page.size()], true
+	}
+
+	g.Groups = terms
+
+	return g
+}
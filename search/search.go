@@ -1,78 +1,102 @@
 package search
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
 	"errors"
 	"io"
 	"io/fs"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/server/library"
 	"github.com/bh90210/super/super"
 	"github.com/blevesearch/bleve"
 	badger "github.com/dgraph-io/badger/v4"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
-type Search struct {
-	index bleve.Index
-	conn  *grpc.ClientConn
-	db    *badger.DB
-	list  []api.File
-	mu    sync.Mutex
-}
+var ErrInvalidSource = errors.New("invalid source")
 
-func NewSearch() (s *Search, err error) {
-	s = &Search{}
+const (
+	minBackoff   = time.Second
+	maxBackoff   = time.Minute
+	syncInterval = 30 * time.Second
+)
 
-	// We need to create the local cache directory, if not already created.
-	err = os.Mkdir(super.LocalStorage(super.SearchStore), 0755)
-	if err != nil && !errors.Is(err, fs.ErrExist) {
-		slog.Error("creating local cache", err)
-		return
-	}
+// Connection is the state of the connection to the server.
+type Connection int
 
-	// Then try opening the search index.
-	blindex, err := bleve.Open(super.LocalStorage(super.SearchStore))
-	if err != nil && !errors.Is(err, bleve.ErrorIndexMetaMissing) {
-		slog.Error("bleve.Open", err)
-		return
-	}
+const (
+	Offline Connection = iota
+	Connecting
+	Online
+)
 
-	// If the index doesn't exist, create it.
-	if errors.Is(err, bleve.ErrorIndexMetaMissing) {
-		slog.Info("creating new search index")
-		mapping := bleve.NewIndexMapping()
-		blindex, err = bleve.New(super.LocalStorage(super.SearchStore), mapping)
-		if err != nil {
-			slog.Error("bleve.New", err)
-			return
-		}
+func (c Connection) String() string {
+	switch c {
+	case Connecting:
+		return "connecting"
+	case Online:
+		return "online"
+	default:
+		return "offline"
 	}
+}
 
-	s.index = blindex
+type Search struct {
+	index   bleve.Index
+	conn    *grpc.ClientConn
+	db      *badger.DB
+	catalog *catalog
+	recent  []recent
+	sources []string
+	// playlists returns the playlists to search by name, if set.
+	playlists func() []*api.Playlist
+	// cached reports whether a file was downloaded, if set.
+	cached      func(*api.File) bool
+	connection  Connection
+	connections chan Connection
+	mu          sync.Mutex
+	// seq is the last journal entry written, applying serialises changes
+	// so the index takes them in order.
+	seq      uint64
+	applying sync.Mutex
+}
 
-	// Try to create local data storage directory, if not already created.
-	err = os.Mkdir(super.LocalStorage(super.DataStore), 0755)
+func NewSearch(db *badger.DB, conn *grpc.ClientConn) (s *Search, err error) {
+	s = &Search{
+		db:          db,
+		conn:        conn,
+		catalog:     newCatalog(),
+		connections: make(chan Connection, 16),
+	}
+
+	// We need to create the local cache directory, if not already created.
+	err = os.Mkdir(super.LocalStorage(super.SearchStore), 0755)
 	if err != nil && !errors.Is(err, fs.ErrExist) {
-		slog.Error("creating local cache", err)
+		slog.Error("creating local cache", "error", err)
 		return
 	}
 
-	// Then try opening badger (local data storage).
-	s.db, err = badger.Open(badger.DefaultOptions(super.LocalStorage(super.DataStore)))
+	// Then open the search index, it is created or rebuilt if the
+	// mapping changed since it was written.
+	blindex, rebuilt, err := openIndex(super.LocalStorage(super.SearchStore))
 	if err != nil {
-		slog.Error("badger.Open", err)
 		return
 	}
 
+	s.index = blindex
+
 	// Load the current list from local storage.
+	var stored []*api.File
 	s.db.View(func(txn *badger.Txn) error {
 		it := txn.NewIterator(badger.DefaultIteratorOptions)
 		defer it.Close()
@@ -81,16 +105,14 @@ func NewSearch() (s *Search, err error) {
 		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
 			item := it.Item()
 			err := item.Value(func(v []byte) error {
-				var file api.File
-				buf := bytes.NewBuffer(v)
-				g := gob.NewDecoder(buf)
-				err = g.Decode(&file)
+				file := &api.File{}
+				err = proto.Unmarshal(v, file)
 				if err != nil {
-					slog.Error("gob.Decode", err)
+					slog.Error("proto.Unmarshal", "error", err)
 					return err
 				}
 
-				s.list = append(s.list, file)
+				stored = append(stored, file)
 
 				return nil
 			})
@@ -101,60 +123,106 @@ func NewSearch() (s *Search, err error) {
 		return nil
 	})
 
-	// Create a new gRPC connection to the server.
-	s.conn, err = grpc.NewClient(super.SuperServer, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// Sorted once, as a whole.
+	s.catalog.put(stored...)
+
+	err = s.recover(rebuilt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.loadRecent()
 	if err != nil {
+		slog.Error("loading recent searches", "error", err)
 		return nil, err
 	}
 
+	// Load the local folders and rescan them in the background,
+	// they might have changed since the last run.
+	s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(super.Source)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			s.sources = append(s.sources, string(it.Item().Key()[len(prefix):]))
+		}
+		return nil
+	})
+
+	go s.scan(s.sources...)
+
 	// Get the current index from local storage.
-	var index uint64
+	index := &wrapperspb.UInt64Value{}
 	err = s.db.View(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte("index"))
 		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
-			slog.Error("badger.Get", err)
+			slog.Error("badger.Get", "error", err)
 			return err
 		}
 
 		if errors.Is(err, badger.ErrKeyNotFound) {
-			index = 0
-		} else {
-			valCopy, err := item.ValueCopy(nil)
-			if err != nil {
-				slog.Error("badger.ValueCopy", err)
-				return err
-			}
+			return nil
+		}
 
-			buf := bytes.NewBuffer(valCopy)
-			g := gob.NewDecoder(buf)
-			err = g.Decode(&index)
+		return item.Value(func(v []byte) error {
+			err := proto.Unmarshal(v, index)
 			if err != nil {
-				slog.Error("gob.Decode", err)
-				return err
+				slog.Error("proto.Unmarshal", "error", err)
 			}
-		}
-
-		return nil
-	})
 
-	// Connect to the server and get the library.
-	library := api.NewLibraryClient(s.conn)
-	// Send the current index to the server.
-	response, err := library.Get(context.Background(), &api.LibraryRequest{
-		Index: index,
+			return err
+		})
 	})
-	if err != nil {
-		slog.Error("library.Get", err)
-		return
-	}
 
-	// Server will respond with the current index and all the updates.
-	go s.incoming(response, index)
+	// Sync with the server in the background, the local
+	// library is usable while offline.
+	go s.sync(index.GetValue())
 
 	return
 }
 
-func (s *Search) incoming(response api.Library_GetClient, index uint64) {
+// sync keeps the local library up to date with the server. When the server
+// can't be reached it retries with an exponential backoff.
+func (s *Search) sync(index uint64) {
+	backoff := minBackoff
+	for {
+		if s.Connection() != Online {
+			s.setConnection(Connecting)
+		}
+
+		// Connect to the server and get the library.
+		client := api.NewLibraryClient(s.conn)
+		// Send the current index to the server.
+		response, err := client.Get(context.Background(), &api.LibraryRequest{
+			Index: index,
+		})
+		if err == nil {
+			// Server will respond with the current index and all the updates.
+			index, err = s.incoming(response, index)
+		}
+
+		if err != nil {
+			slog.Error("library.Get", "error", err, "retry", backoff)
+			s.setConnection(Offline)
+
+			time.Sleep(backoff)
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+
+		s.setConnection(Online)
+		backoff = minBackoff
+
+		// The server closes the stream once we are up to date,
+		// ask again for updates later on.
+		time.Sleep(syncInterval)
+	}
+}
+
+func (s *Search) incoming(response api.Library_GetClient, index uint64) (uint64, error) {
 	for {
 		// Message contains the current index and all files that
 		// need to be added or removed from the search index and
@@ -162,138 +230,368 @@ func (s *Search) incoming(response api.Library_GetClient, index uint64) {
 		message, err := response.Recv()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
-				break
+				return index, nil
 			}
-			slog.Error("library.Recv", err)
-			return
+			slog.Error("library.Recv", "error", err)
+			return index, err
+		}
+
+		// Files coming from the server are tagged with it as their source.
+		for _, file := range message.AddIndex {
+			file.Source = super.SuperServer
+		}
+
+		err = s.apply(message.AddIndex, message.RemoveIndex, &message.Index)
+		if err != nil {
+			return index, err
 		}
 
 		// Assign the new index value.
 		index = message.Index
+	}
+}
 
-		// Update the local storage & index.
-		err = s.db.Update(func(txn *badger.Txn) error {
-			// Add new files.
-			for _, file := range message.AddIndex {
-				buf := bytes.NewBuffer(nil)
-				g := gob.NewEncoder(buf)
-				err = g.Encode(file)
-				if err != nil {
-					slog.Error("gob.Encode", err)
-					return err
-				}
+// Connection returns the current state of the connection to the server.
+func (s *Search) Connection() Connection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-				err = txn.Set([]byte(super.File+file.Path), buf.Bytes())
-				if err != nil {
-					slog.Error("badger.Set", err)
-					return err
-				}
+	return s.connection
+}
+
+// Connections returns a channel that receives every connection state change.
+func (s *Search) Connections() <-chan Connection {
+	return s.connections
+}
+
+func (s *Search) setConnection(c Connection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.connection == c {
+		return
+	}
+
+	s.connection = c
+
+	select {
+	case s.connections <- c:
+	default:
+		slog.Warn("connection state change dropped", "state", c)
+	}
+}
+
+// apply updates the local storage, the catalog and the search index.
+// Obsolete files are removed before new ones are added so that a changed
+// file can be sent as both. If index is not nil it is stored alongside.
+// Once stored the change is journaled, if updating the search index fails
+// it is only logged, the next change or start tries again.
+func (s *Search) apply(add, remove []*api.File, index *uint64) error {
+	s.applying.Lock()
+	defer s.applying.Unlock()
+
+	paths := make([]string, 0, len(remove)+len(add))
+	for _, file := range remove {
+		paths = append(paths, file.Path)
+	}
+
+	for _, file := range add {
+		paths = append(paths, file.Path)
+	}
+
+	// Update the local storage & journal.
+	err := s.db.Update(func(txn *badger.Txn) error {
+		// Remove obsolete files.
+		for _, file := range remove {
+			err := txn.Delete([]byte(super.File + file.Path))
+			if err != nil {
+				slog.Error("badger.Delete", "error", err)
+				return err
 			}
+		}
 
-			// Remove obsolete files.
-			for _, file := range message.RemoveIndex {
-				err = txn.Delete([]byte(super.File + file.Path))
-				if err != nil {
-					slog.Error("badger.Delete", err)
-					return err
-				}
+		// Add new files.
+		for _, file := range add {
+			b, err := proto.Marshal(file)
+			if err != nil {
+				slog.Error("proto.Marshal", "error", err)
+				return err
 			}
 
-			// Update the index.
-			buf := bytes.NewBuffer(nil)
-			g := gob.NewEncoder(buf)
-			err = g.Encode(index)
+			err = txn.Set([]byte(super.File+file.Path), b)
 			if err != nil {
-				slog.Error("gob.Encode", err)
+				slog.Error("badger.Set", "error", err)
 				return err
 			}
+		}
 
-			err = txn.Set([]byte("index"), buf.Bytes())
+		err := s.journal(txn, paths)
+		if err != nil {
 			return err
-		})
+		}
+
+		if index == nil {
+			return nil
+		}
+
+		// Update the index.
+		b, err := proto.Marshal(wrapperspb.UInt64(*index))
 		if err != nil {
-			slog.Error("badger.Set", err)
-			return
+			slog.Error("proto.Marshal", "error", err)
+			return err
 		}
 
-		// Add new files to the search index and s.list field.
-		for _, file := range message.AddIndex {
-			err = s.index.Index(file.Path, file)
-			if err != nil {
-				slog.Error("index.Index", "file", file.Path, "error", err)
-				return
-			}
+		return txn.Set([]byte("index"), b)
+	})
+	if err != nil {
+		slog.Error("badger.Set", "error", err)
+		return err
+	}
+
+	s.mu.Lock()
+	s.catalog.remove(paths[:len(remove)]...)
+	s.catalog.put(add...)
+	s.mu.Unlock()
+
+	// Bring the search index up to date with the journal.
+	err = s.replay()
+	if err != nil {
+		slog.Warn("search index behind, the journal keeps the change", "error", err)
+	}
+
+	return nil
+}
+
+// Sources returns the local folders that are part of the library.
+func (s *Search) Sources() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sources := slices.Clone(s.sources)
+	sort.Strings(sources)
+
+	return sources
+}
+
+// AddSource adds a local folder to the library. Its files are
+// indexed in the background.
+func (s *Search) AddSource(root string) error {
+	root = filepath.Clean(root)
+
+	info, err := os.Stat(root)
+	if err != nil {
+		slog.Error("os.Stat", "root", root, "error", err)
+		return err
+	}
+
+	if !filepath.IsAbs(root) || !info.IsDir() {
+		return ErrInvalidSource
+	}
+
+	err = s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(super.Source+root), nil)
+	})
+	if err != nil {
+		slog.Error("badger.Set", "root", root, "error", err)
+		return err
+	}
+
+	s.mu.Lock()
+	if !slices.Contains(s.sources, root) {
+		s.sources = append(s.sources, root)
+	}
+	s.mu.Unlock()
+
+	go s.scan(root)
+
+	return nil
+}
+
+// RemoveSource removes a local folder and all its files from the library.
+func (s *Search) RemoveSource(root string) error {
+	root = filepath.Clean(root)
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(super.Source + root))
+	})
+	if err != nil {
+		slog.Error("badger.Delete", "root", root, "error", err)
+		return err
+	}
 
-			s.mu.Lock()
-			s.list = append(s.list, *file)
-			s.mu.Unlock()
+	var remove []*api.File
+	s.mu.Lock()
+	s.sources = slices.DeleteFunc(s.sources, func(source string) bool {
+		return source == root
+	})
+
+	for _, file := range s.catalog.source(root) {
+		remove = append(remove, &api.File{Path: file.Path})
+	}
+	s.mu.Unlock()
+
+	return s.apply(nil, remove, nil)
+}
+
+// scan indexes the files found under each local folder and removes
+// the ones that are no longer there.
+func (s *Search) scan(roots ...string) {
+	for _, root := range roots {
+		files, err := library.Scan(root)
+		if err != nil {
+			slog.Error("library.Scan", "root", root, "error", err)
+			continue
 		}
 
-		// Remove obsolete files from the search index and s.list field.
-		for _, file := range message.RemoveIndex {
-			err = s.index.Delete(file.Path)
-			if err != nil {
-				slog.Error("index.Delete", err)
-				return
-			}
+		// Local files are keyed by their absolute path.
+		found := make(map[string]*api.File)
+		for _, file := range files {
+			file.Path = filepath.Join(root, file.Path)
+			file.Source = root
+			found[file.Path] = file
+		}
 
-			for i, f := range s.list {
-				if f.Path == file.Path {
-					s.mu.Lock()
-					s.list = append(s.list[:i], s.list[i+1:]...)
-					s.mu.Unlock()
-					break
-				}
+		var remove []*api.File
+		s.mu.Lock()
+		for _, known := range s.catalog.source(root) {
+			file, ok := found[known.Path]
+			switch {
+			case !ok:
+				remove = append(remove, &api.File{Path: known.Path})
+
+			case proto.Equal(file, known):
+				delete(found, file.Path)
+
+			default:
+				// Changed, it gets removed and added again.
+				remove = append(remove, &api.File{Path: known.Path})
 			}
 		}
+		s.mu.Unlock()
+
+		var add []*api.File
+		for _, file := range found {
+			add = append(add, file)
+		}
+
+		err = s.apply(add, remove, nil)
+		if err != nil {
+			slog.Error("apply", "root", root, "error", err)
+		}
 	}
 }
 
+// List returns the library ordered by path.
 func (s *Search) List() []api.File {
-	mapped := make(map[string]*api.File)
+	return s.ListBy(ByPath)
+}
 
+// ListBy returns the library in order. The catalog keeps it sorted, so
+// this only copies it.
+func (s *Search) ListBy(order Order) []api.File {
 	s.mu.Lock()
-	for _, v := range s.list {
-		mapped[v.Path] = &v
-	}
+	files := s.catalog.list(order)
 	s.mu.Unlock()
 
-	sorted := []string{}
-	for k := range mapped {
-		sorted = append(sorted, k)
+	list := make([]api.File, len(files))
+	for i, file := range files {
+		proto.Merge(&list[i], file)
 	}
 
-	sort.Strings(sorted)
+	s.markCached(list)
+
+	return list
+}
+
+// Files returns copies of the files with these paths, in the same order.
+// Paths no longer in the library get a file with only the path, so they
+// keep their place.
+func (s *Search) Files(paths []string) []*api.File {
+	cached := s.cachedFiles()
 
-	var list []api.File
-	for _, k := range sorted {
-		list = append(list, *mapped[k])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := make([]*api.File, len(paths))
+	for i, path := range paths {
+		known, ok := s.catalog.get(path)
+		if !ok {
+			files[i] = &api.File{Path: path}
+			continue
+		}
+
+		files[i] = proto.Clone(known).(*api.File)
+		files[i].Cached = cached(files[i])
 	}
 
-	return list
+	return files
 }
 
+// Search returns the files matching what was typed, best first. It is
+// meant to run as the user types.
 func (s *Search) Search(query string) ([]api.File, error) {
-	q := bleve.NewQueryStringQuery(query)
+	q, sort := plan(query, s.index.Mapping().AnalyzerNamed(folded))
+	if q == nil {
+		return nil, nil
+	}
+
 	searchRequest := bleve.NewSearchRequest(q)
 	searchRequest.Size = 100
+	if len(sort) > 0 {
+		searchRequest.SortBy(sort)
+	}
 	searchResult, err := s.index.Search(searchRequest)
 	if err != nil {
-		slog.Error("index.Search", err)
+		slog.Error("index.Search", "error", err)
 		return nil, err
 	}
 
-	var files []api.File
-	for _, v := range searchResult.Hits {
-		for _, f := range s.list {
-			if f.Path == v.ID {
-				files = append(files, f)
-				break
-			}
+	s.mu.Lock()
+	files := make([]api.File, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		file, ok := s.catalog.get(hit.ID)
+		if !ok {
+			continue
 		}
+
+		files = append(files, api.File{})
+		proto.Merge(&files[len(files)-1], file)
 	}
+	s.mu.Unlock()
+
+	s.markCached(files)
 
 	slog.Info("search.Search", "query", query, "result", searchResult)
 
 	return files, nil
 }
+
+// SetCached sets what tells whether a file was downloaded, until then only
+// local files can be played offline.
+func (s *Search) SetCached(cached func(*api.File) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cached = cached
+}
+
+// markCached sets the Cached field of the files that can be played
+// offline, local files and the ones already downloaded.
+func (s *Search) markCached(files []api.File) {
+	cached := s.cachedFiles()
+	for i := range files {
+		files[i].Cached = cached(&files[i])
+	}
+}
+
+// cachedFiles returns a function that reports whether a file can be played
+// offline.
+func (s *Search) cachedFiles() func(*api.File) bool {
+	s.mu.Lock()
+	downloaded := s.cached
+	s.mu.Unlock()
+
+	return func(file *api.File) bool {
+		return super.Local(file.Source) || (downloaded != nil && downloaded(file))
+	}
+}
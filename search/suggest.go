@@ -0,0 +1,111 @@
+package search
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+
+	bleveIndex "github.com/blevesearch/bleve/index"
+)
+
+// suggested are the fields words are completed from.
+var suggested = []string{fieldTitle, fieldArtist, fieldAlbum}
+
+// Suggestion is what was typed with the last word completed.
+type Suggestion struct {
+	Text string
+	// Term is the word it was completed with, as indexed.
+	Term string
+	// Fields are the fields the word is in.
+	Fields []string
+	// Count is how many times the word is in the fields, over every song.
+	Count uint64
+}
+
+// Suggest completes the last word of input with the words of the titles,
+// artists and albums that start with it, the most common first. Nothing is
+// suggested while a filter is typed or after a space.
+func (s *Search) Suggest(input string, size int) ([]Suggestion, error) {
+	if size <= 0 {
+		size = defaultSize
+	}
+
+	start := strings.LastIndexFunc(input, unicode.IsSpace) + 1
+	word := input[start:]
+	if word == "" || strings.Contains(word, ":") {
+		return nil, nil
+	}
+
+	// The word is folded the way the index is, it may even split.
+	tokens := s.index.Mapping().AnalyzerNamed(folded).Analyze([]byte(word))
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	prefix := tokens[len(tokens)-1].Term
+
+	terms := make(map[string]*Suggestion)
+	for _, field := range suggested {
+		dict, err := s.index.FieldDictPrefix(field, prefix)
+		if err != nil {
+			slog.Error("index.FieldDictPrefix", "field", field, "error", err)
+			return nil, err
+		}
+
+		err = visit(dict, func(entry *bleveIndex.DictEntry) {
+			suggestion, ok := terms[entry.Term]
+			if !ok {
+				suggestion = &Suggestion{
+					Text: input[:start] + entry.Term,
+					Term: entry.Term,
+				}
+				terms[entry.Term] = suggestion
+			}
+
+			suggestion.Fields = append(suggestion.Fields, field)
+			suggestion.Count += entry.Count
+		})
+		if err != nil {
+			slog.Error("dict.Next", "field", field, "error", err)
+			return nil, err
+		}
+	}
+
+	suggestions := make([]Suggestion, 0, len(terms))
+	for _, suggestion := range terms {
+		suggestions = append(suggestions, *suggestion)
+	}
+
+	slices.SortFunc(suggestions, func(a, b Suggestion) int {
+		return cmp.Or(
+			cmp.Compare(b.Count, a.Count),
+			strings.Compare(a.Term, b.Term),
+		)
+	})
+
+	if len(suggestions) > size {
+		suggestions = suggestions[:size]
+	}
+
+	return suggestions, nil
+}
+
+// visit calls fn with every entry of dict and closes it.
+func visit(dict bleveIndex.FieldDict, fn func(*bleveIndex.DictEntry)) error {
+	defer dict.Close()
+
+	for {
+		entry, err := dict.Next()
+		if err != nil {
+			return err
+		}
+
+		if entry == nil {
+			return nil
+		}
+
+		fn(entry)
+	}
+}
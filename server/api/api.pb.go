@@ -21,6 +21,58 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type Role int32
+
+const (
+	Role_ROLE_NONE   Role = 0
+	Role_ROLE_VIEWER Role = 1
+	Role_ROLE_EDITOR Role = 2
+	Role_ROLE_OWNER  Role = 3
+)
+
+// Enum value maps for Role.
+var (
+	Role_name = map[int32]string{
+		0: "ROLE_NONE",
+		1: "ROLE_VIEWER",
+		2: "ROLE_EDITOR",
+		3: "ROLE_OWNER",
+	}
+	Role_value = map[string]int32{
+		"ROLE_NONE":   0,
+		"ROLE_VIEWER": 1,
+		"ROLE_EDITOR": 2,
+		"ROLE_OWNER":  3,
+	}
+)
+
+func (x Role) Enum() *Role {
+	p := new(Role)
+	*p = x
+	return p
+}
+
+func (x Role) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Role) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_api_proto_enumTypes[0].Descriptor()
+}
+
+func (Role) Type() protoreflect.EnumType {
+	return &file_api_api_proto_enumTypes[0]
+}
+
+func (x Role) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Role.Descriptor instead.
+func (Role) EnumDescriptor() ([]byte, []int) {
+	return file_api_api_proto_rawDescGZIP(), []int{0}
+}
+
 type UploadStatus_Status int32
 
 const (
@@ -57,11 +109,11 @@ func (x UploadStatus_Status) String() string {
 }
 
 func (UploadStatus_Status) Descriptor() protoreflect.EnumDescriptor {
-	return file_api_api_proto_enumTypes[0].Descriptor()
+	return file_api_api_proto_enumTypes[1].Descriptor()
 }
 
 func (UploadStatus_Status) Type() protoreflect.EnumType {
-	return &file_api_api_proto_enumTypes[0]
+	return &file_api_api_proto_enumTypes[1]
 }
 
 func (x UploadStatus_Status) Number() protoreflect.EnumNumber {
@@ -70,7 +122,7 @@ func (x UploadStatus_Status) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use UploadStatus_Status.Descriptor instead.
 func (UploadStatus_Status) EnumDescriptor() ([]byte, []int) {
-	return file_api_api_proto_rawDescGZIP(), []int{6, 0}
+	return file_api_api_proto_rawDescGZIP(), []int{14, 0}
 }
 
 type LibraryRequest struct {
@@ -178,12 +230,32 @@ func (x *LibraryResponse) GetRemoveIndex() []*File {
 }
 
 type File struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Artist        string                 `protobuf:"bytes,1,opt,name=artist,proto3" json:"artist,omitempty"`
-	Album         string                 `protobuf:"bytes,2,opt,name=album,proto3" json:"album,omitempty"`
-	Track         string                 `protobuf:"bytes,3,opt,name=track,proto3" json:"track,omitempty"`
-	Duration      string                 `protobuf:"bytes,4,opt,name=duration,proto3" json:"duration,omitempty"`
-	Path          string                 `protobuf:"bytes,5,opt,name=path,proto3" json:"path,omitempty"`
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Artist   string                 `protobuf:"bytes,1,opt,name=artist,proto3" json:"artist,omitempty"`
+	Album    string                 `protobuf:"bytes,2,opt,name=album,proto3" json:"album,omitempty"`
+	Track    string                 `protobuf:"bytes,3,opt,name=track,proto3" json:"track,omitempty"`
+	Duration string                 `protobuf:"bytes,4,opt,name=duration,proto3" json:"duration,omitempty"`
+	Path     string                 `protobuf:"bytes,5,opt,name=path,proto3" json:"path,omitempty"`
+	// Source is where the file lives: the server address for remote files or
+	// the local folder a file was found under.
+	Source string `protobuf:"bytes,6,opt,name=source,proto3" json:"source,omitempty"`
+	// Cached is set by the client for files that can be played offline.
+	Cached bool `protobuf:"varint,7,opt,name=cached,proto3" json:"cached,omitempty"`
+	// Checksum is the hex encoded sha256 of the file contents.
+	Checksum string `protobuf:"bytes,8,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	// ReplayGain is unset for files without ReplayGain or R128 tags.
+	ReplayGain *ReplayGain `protobuf:"bytes,9,opt,name=replay_gain,json=replayGain,proto3" json:"replay_gain,omitempty"`
+	// Year, genre and track number are 0 or empty when not tagged.
+	Year  uint32 `protobuf:"varint,10,opt,name=year,proto3" json:"year,omitempty"`
+	Genre string `protobuf:"bytes,11,opt,name=genre,proto3" json:"genre,omitempty"`
+	// Format is the codec: mp3, flac, vorbis or opus.
+	Format      string `protobuf:"bytes,12,opt,name=format,proto3" json:"format,omitempty"`
+	TrackNumber uint32 `protobuf:"varint,13,opt,name=track_number,json=trackNumber,proto3" json:"track_number,omitempty"`
+	// DurationSeconds is the duration as a number, to filter and sort by.
+	DurationSeconds float64 `protobuf:"fixed64,14,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+	// Added is when the file joined the library, in unix seconds. It is the
+	// modification time of the file.
+	Added         int64 `protobuf:"varint,15,opt,name=added,proto3" json:"added,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -253,16 +325,158 @@ func (x *File) GetPath() string {
 	return ""
 }
 
-type DownloadRequest struct {
+func (x *File) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *File) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+func (x *File) GetChecksum() string {
+	if x != nil {
+		return x.Checksum
+	}
+	return ""
+}
+
+func (x *File) GetReplayGain() *ReplayGain {
+	if x != nil {
+		return x.ReplayGain
+	}
+	return nil
+}
+
+func (x *File) GetYear() uint32 {
+	if x != nil {
+		return x.Year
+	}
+	return 0
+}
+
+func (x *File) GetGenre() string {
+	if x != nil {
+		return x.Genre
+	}
+	return ""
+}
+
+func (x *File) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *File) GetTrackNumber() uint32 {
+	if x != nil {
+		return x.TrackNumber
+	}
+	return 0
+}
+
+func (x *File) GetDurationSeconds() float64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+func (x *File) GetAdded() int64 {
+	if x != nil {
+		return x.Added
+	}
+	return 0
+}
+
+// ReplayGain holds the gains in dB and the peaks as linear sample values.
+// Files without album values carry the track ones.
+type ReplayGain struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	TrackGain     float64                `protobuf:"fixed64,1,opt,name=track_gain,json=trackGain,proto3" json:"track_gain,omitempty"`
+	TrackPeak     float64                `protobuf:"fixed64,2,opt,name=track_peak,json=trackPeak,proto3" json:"track_peak,omitempty"`
+	AlbumGain     float64                `protobuf:"fixed64,3,opt,name=album_gain,json=albumGain,proto3" json:"album_gain,omitempty"`
+	AlbumPeak     float64                `protobuf:"fixed64,4,opt,name=album_peak,json=albumPeak,proto3" json:"album_peak,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReplayGain) Reset() {
+	*x = ReplayGain{}
+	mi := &file_api_api_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReplayGain) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplayGain) ProtoMessage() {}
+
+func (x *ReplayGain) ProtoReflect() protoreflect.Message {
+	mi := &file_api_api_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplayGain.ProtoReflect.Descriptor instead.
+func (*ReplayGain) Descriptor() ([]byte, []int) {
+	return file_api_api_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ReplayGain) GetTrackGain() float64 {
+	if x != nil {
+		return x.TrackGain
+	}
+	return 0
+}
+
+func (x *ReplayGain) GetTrackPeak() float64 {
+	if x != nil {
+		return x.TrackPeak
+	}
+	return 0
+}
+
+func (x *ReplayGain) GetAlbumGain() float64 {
+	if x != nil {
+		return x.AlbumGain
+	}
+	return 0
+}
+
+func (x *ReplayGain) GetAlbumPeak() float64 {
+	if x != nil {
+		return x.AlbumPeak
+	}
+	return 0
+}
+
+type DownloadRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// Offset is where to resume an interrupted download from.
+	Offset        int64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *DownloadRequest) Reset() {
 	*x = DownloadRequest{}
-	mi := &file_api_api_proto_msgTypes[3]
+	mi := &file_api_api_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -274,7 +488,7 @@ func (x *DownloadRequest) String() string {
 func (*DownloadRequest) ProtoMessage() {}
 
 func (x *DownloadRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_api_proto_msgTypes[3]
+	mi := &file_api_api_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -287,7 +501,7 @@ func (x *DownloadRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DownloadRequest.ProtoReflect.Descriptor instead.
 func (*DownloadRequest) Descriptor() ([]byte, []int) {
-	return file_api_api_proto_rawDescGZIP(), []int{3}
+	return file_api_api_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *DownloadRequest) GetPath() string {
@@ -297,6 +511,13 @@ func (x *DownloadRequest) GetPath() string {
 	return ""
 }
 
+func (x *DownloadRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
 type DownloadResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
@@ -306,7 +527,7 @@ type DownloadResponse struct {
 
 func (x *DownloadResponse) Reset() {
 	*x = DownloadResponse{}
-	mi := &file_api_api_proto_msgTypes[4]
+	mi := &file_api_api_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -318,7 +539,7 @@ func (x *DownloadResponse) String() string {
 func (*DownloadResponse) ProtoMessage() {}
 
 func (x *DownloadResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_api_proto_msgTypes[4]
+	mi := &file_api_api_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -331,7 +552,7 @@ func (x *DownloadResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DownloadResponse.ProtoReflect.Descriptor instead.
 func (*DownloadResponse) Descriptor() ([]byte, []int) {
-	return file_api_api_proto_rawDescGZIP(), []int{4}
+	return file_api_api_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *DownloadResponse) GetData() []byte {
@@ -341,6 +562,385 @@ func (x *DownloadResponse) GetData() []byte {
 	return nil
 }
 
+// Playlist is a named list of tracks, referenced by path. A track can be in
+// it more than once.
+type Playlist struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Id     string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name   string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Tracks []string               `protobuf:"bytes,3,rep,name=tracks,proto3" json:"tracks,omitempty"`
+	// Created and modified are in unix seconds.
+	Created  int64 `protobuf:"varint,4,opt,name=created,proto3" json:"created,omitempty"`
+	Modified int64 `protobuf:"varint,5,opt,name=modified,proto3" json:"modified,omitempty"`
+	// Owner and role are set by the server, role is the one of the caller.
+	// Playlists that were never synced have neither.
+	Owner         string `protobuf:"bytes,6,opt,name=owner,proto3" json:"owner,omitempty"`
+	Role          Role   `protobuf:"varint,7,opt,name=role,proto3,enum=api.Role" json:"role,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Playlist) Reset() {
+	*x = Playlist{}
+	mi := &file_api_api_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Playlist) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Playlist) ProtoMessage() {}
+
+func (x *Playlist) ProtoReflect() protoreflect.Message {
+	mi := &file_api_api_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Playlist.ProtoReflect.Descriptor instead.
+func (*Playlist) Descriptor() ([]byte, []int) {
+	return file_api_api_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Playlist) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Playlist) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Playlist) GetTracks() []string {
+	if x != nil {
+		return x.Tracks
+	}
+	return nil
+}
+
+func (x *Playlist) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *Playlist) GetModified() int64 {
+	if x != nil {
+		return x.Modified
+	}
+	return 0
+}
+
+func (x *Playlist) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *Playlist) GetRole() Role {
+	if x != nil {
+		return x.Role
+	}
+	return Role_ROLE_NONE
+}
+
+type PlaylistsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         uint64                 `protobuf:"fixed64,1,opt,name=index,proto3" json:"index,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlaylistsRequest) Reset() {
+	*x = PlaylistsRequest{}
+	mi := &file_api_api_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlaylistsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlaylistsRequest) ProtoMessage() {}
+
+func (x *PlaylistsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_api_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlaylistsRequest.ProtoReflect.Descriptor instead.
+func (*PlaylistsRequest) Descriptor() ([]byte, []int) {
+	return file_api_api_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PlaylistsRequest) GetIndex() uint64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+type PlaylistsResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Index  uint64                 `protobuf:"fixed64,1,opt,name=index,proto3" json:"index,omitempty"`
+	Update []*Playlist            `protobuf:"bytes,2,rep,name=update,proto3" json:"update,omitempty"`
+	// Remove are the ids of the playlists deleted or no longer shared with
+	// the caller.
+	Remove        []string `protobuf:"bytes,3,rep,name=remove,proto3" json:"remove,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlaylistsResponse) Reset() {
+	*x = PlaylistsResponse{}
+	mi := &file_api_api_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlaylistsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlaylistsResponse) ProtoMessage() {}
+
+func (x *PlaylistsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_api_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlaylistsResponse.ProtoReflect.Descriptor instead.
+func (*PlaylistsResponse) Descriptor() ([]byte, []int) {
+	return file_api_api_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *PlaylistsResponse) GetIndex() uint64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *PlaylistsResponse) GetUpdate() []*Playlist {
+	if x != nil {
+		return x.Update
+	}
+	return nil
+}
+
+func (x *PlaylistsResponse) GetRemove() []string {
+	if x != nil {
+		return x.Remove
+	}
+	return nil
+}
+
+type PlaylistDeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlaylistDeleteRequest) Reset() {
+	*x = PlaylistDeleteRequest{}
+	mi := &file_api_api_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlaylistDeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlaylistDeleteRequest) ProtoMessage() {}
+
+func (x *PlaylistDeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_api_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlaylistDeleteRequest.ProtoReflect.Descriptor instead.
+func (*PlaylistDeleteRequest) Descriptor() ([]byte, []int) {
+	return file_api_api_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *PlaylistDeleteRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type PlaylistDeleteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlaylistDeleteResponse) Reset() {
+	*x = PlaylistDeleteResponse{}
+	mi := &file_api_api_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlaylistDeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlaylistDeleteResponse) ProtoMessage() {}
+
+func (x *PlaylistDeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_api_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlaylistDeleteResponse.ProtoReflect.Descriptor instead.
+func (*PlaylistDeleteResponse) Descriptor() ([]byte, []int) {
+	return file_api_api_proto_rawDescGZIP(), []int{10}
+}
+
+type ShareRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	User          string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Role          Role                   `protobuf:"varint,3,opt,name=role,proto3,enum=api.Role" json:"role,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShareRequest) Reset() {
+	*x = ShareRequest{}
+	mi := &file_api_api_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShareRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShareRequest) ProtoMessage() {}
+
+func (x *ShareRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_api_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShareRequest.ProtoReflect.Descriptor instead.
+func (*ShareRequest) Descriptor() ([]byte, []int) {
+	return file_api_api_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ShareRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ShareRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *ShareRequest) GetRole() Role {
+	if x != nil {
+		return x.Role
+	}
+	return Role_ROLE_NONE
+}
+
+type ShareResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShareResponse) Reset() {
+	*x = ShareResponse{}
+	mi := &file_api_api_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShareResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShareResponse) ProtoMessage() {}
+
+func (x *ShareResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_api_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShareResponse.ProtoReflect.Descriptor instead.
+func (*ShareResponse) Descriptor() ([]byte, []int) {
+	return file_api_api_proto_rawDescGZIP(), []int{12}
+}
+
 type UploadRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Request:
@@ -354,7 +954,7 @@ type UploadRequest struct {
 
 func (x *UploadRequest) Reset() {
 	*x = UploadRequest{}
-	mi := &file_api_api_proto_msgTypes[5]
+	mi := &file_api_api_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -366,7 +966,7 @@ func (x *UploadRequest) String() string {
 func (*UploadRequest) ProtoMessage() {}
 
 func (x *UploadRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_api_proto_msgTypes[5]
+	mi := &file_api_api_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -379,7 +979,7 @@ func (x *UploadRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UploadRequest.ProtoReflect.Descriptor instead.
 func (*UploadRequest) Descriptor() ([]byte, []int) {
-	return file_api_api_proto_rawDescGZIP(), []int{5}
+	return file_api_api_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *UploadRequest) GetRequest() isUploadRequest_Request {
@@ -432,7 +1032,7 @@ type UploadStatus struct {
 
 func (x *UploadStatus) Reset() {
 	*x = UploadStatus{}
-	mi := &file_api_api_proto_msgTypes[6]
+	mi := &file_api_api_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -444,7 +1044,7 @@ func (x *UploadStatus) String() string {
 func (*UploadStatus) ProtoMessage() {}
 
 func (x *UploadStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_api_api_proto_msgTypes[6]
+	mi := &file_api_api_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -457,7 +1057,7 @@ func (x *UploadStatus) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UploadStatus.ProtoReflect.Descriptor instead.
 func (*UploadStatus) Descriptor() ([]byte, []int) {
-	return file_api_api_proto_rawDescGZIP(), []int{6}
+	return file_api_api_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *UploadStatus) GetStatus() UploadStatus_Status {
@@ -480,7 +1080,7 @@ type UploadResponse struct {
 
 func (x *UploadResponse) Reset() {
 	*x = UploadResponse{}
-	mi := &file_api_api_proto_msgTypes[7]
+	mi := &file_api_api_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -492,7 +1092,7 @@ func (x *UploadResponse) String() string {
 func (*UploadResponse) ProtoMessage() {}
 
 func (x *UploadResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_api_proto_msgTypes[7]
+	mi := &file_api_api_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -505,7 +1105,7 @@ func (x *UploadResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UploadResponse.ProtoReflect.Descriptor instead.
 func (*UploadResponse) Descriptor() ([]byte, []int) {
-	return file_api_api_proto_rawDescGZIP(), []int{7}
+	return file_api_api_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *UploadResponse) GetResponse() isUploadResponse_Response {
@@ -549,6 +1149,132 @@ func (*UploadResponse_Status) isUploadResponse_Response() {}
 
 func (*UploadResponse_Progress) isUploadResponse_Response() {}
 
+// QueueEntry is a track of the play queue, as the client stores it. Tracks
+// are referenced by path and looked up in the library when loaded.
+type QueueEntry struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// Order is the position the entry was added at.
+	Order         uint64 `protobuf:"varint,2,opt,name=order,proto3" json:"order,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueueEntry) Reset() {
+	*x = QueueEntry{}
+	mi := &file_api_api_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueueEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueueEntry) ProtoMessage() {}
+
+func (x *QueueEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_api_api_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueueEntry.ProtoReflect.Descriptor instead.
+func (*QueueEntry) Descriptor() ([]byte, []int) {
+	return file_api_api_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *QueueEntry) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *QueueEntry) GetOrder() uint64 {
+	if x != nil {
+		return x.Order
+	}
+	return 0
+}
+
+// QueueState is the track playing and the settings of the play queue. The
+// upcoming and played tracks are stored as entries of their own.
+type QueueState struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Playing  *QueueEntry            `protobuf:"bytes,1,opt,name=playing,proto3" json:"playing,omitempty"`
+	Shuffled bool                   `protobuf:"varint,2,opt,name=shuffled,proto3" json:"shuffled,omitempty"`
+	Repeat   uint32                 `protobuf:"varint,3,opt,name=repeat,proto3" json:"repeat,omitempty"`
+	// Order is the order of the next entry added.
+	Order         uint64 `protobuf:"varint,4,opt,name=order,proto3" json:"order,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueueState) Reset() {
+	*x = QueueState{}
+	mi := &file_api_api_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueueState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueueState) ProtoMessage() {}
+
+func (x *QueueState) ProtoReflect() protoreflect.Message {
+	mi := &file_api_api_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueueState.ProtoReflect.Descriptor instead.
+func (*QueueState) Descriptor() ([]byte, []int) {
+	return file_api_api_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *QueueState) GetPlaying() *QueueEntry {
+	if x != nil {
+		return x.Playing
+	}
+	return nil
+}
+
+func (x *QueueState) GetShuffled() bool {
+	if x != nil {
+		return x.Shuffled
+	}
+	return false
+}
+
+func (x *QueueState) GetRepeat() uint32 {
+	if x != nil {
+		return x.Repeat
+	}
+	return 0
+}
+
+func (x *QueueState) GetOrder() uint64 {
+	if x != nil {
+		return x.Order
+	}
+	return 0
+}
+
 var File_api_api_proto protoreflect.FileDescriptor
 
 const file_api_api_proto_rawDesc = "" +
@@ -559,17 +1285,62 @@ const file_api_api_proto_rawDesc = "" +
 	"\x0fLibraryResponse\x12\x14\n" +
 	"\x05index\x18\x01 \x01(\x06R\x05index\x12&\n" +
 	"\tadd_index\x18\x02 \x03(\v2\t.api.FileR\baddIndex\x12,\n" +
-	"\fremove_index\x18\x03 \x03(\v2\t.api.FileR\vremoveIndex\"z\n" +
+	"\fremove_index\x18\x03 \x03(\v2\t.api.FileR\vremoveIndex\"\x9e\x03\n" +
 	"\x04File\x12\x16\n" +
 	"\x06artist\x18\x01 \x01(\tR\x06artist\x12\x14\n" +
 	"\x05album\x18\x02 \x01(\tR\x05album\x12\x14\n" +
 	"\x05track\x18\x03 \x01(\tR\x05track\x12\x1a\n" +
 	"\bduration\x18\x04 \x01(\tR\bduration\x12\x12\n" +
-	"\x04path\x18\x05 \x01(\tR\x04path\"%\n" +
+	"\x04path\x18\x05 \x01(\tR\x04path\x12\x16\n" +
+	"\x06source\x18\x06 \x01(\tR\x06source\x12\x16\n" +
+	"\x06cached\x18\a \x01(\bR\x06cached\x12\x1a\n" +
+	"\bchecksum\x18\b \x01(\tR\bchecksum\x120\n" +
+	"\vreplay_gain\x18\t \x01(\v2\x0f.api.ReplayGainR\n" +
+	"replayGain\x12\x12\n" +
+	"\x04year\x18\n" +
+	" \x01(\rR\x04year\x12\x14\n" +
+	"\x05genre\x18\v \x01(\tR\x05genre\x12\x16\n" +
+	"\x06format\x18\f \x01(\tR\x06format\x12!\n" +
+	"\ftrack_number\x18\r \x01(\rR\vtrackNumber\x12)\n" +
+	"\x10duration_seconds\x18\x0e \x01(\x01R\x0fdurationSeconds\x12\x14\n" +
+	"\x05added\x18\x0f \x01(\x03R\x05added\"\x88\x01\n" +
+	"\n" +
+	"ReplayGain\x12\x1d\n" +
+	"\n" +
+	"track_gain\x18\x01 \x01(\x01R\ttrackGain\x12\x1d\n" +
+	"\n" +
+	"track_peak\x18\x02 \x01(\x01R\ttrackPeak\x12\x1d\n" +
+	"\n" +
+	"album_gain\x18\x03 \x01(\x01R\talbumGain\x12\x1d\n" +
+	"\n" +
+	"album_peak\x18\x04 \x01(\x01R\talbumPeak\"=\n" +
 	"\x0fDownloadRequest\x12\x12\n" +
-	"\x04path\x18\x01 \x01(\tR\x04path\"&\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\"&\n" +
 	"\x10DownloadResponse\x12\x12\n" +
-	"\x04data\x18\x01 \x01(\fR\x04data\"F\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"\xb1\x01\n" +
+	"\bPlaylist\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06tracks\x18\x03 \x03(\tR\x06tracks\x12\x18\n" +
+	"\acreated\x18\x04 \x01(\x03R\acreated\x12\x1a\n" +
+	"\bmodified\x18\x05 \x01(\x03R\bmodified\x12\x14\n" +
+	"\x05owner\x18\x06 \x01(\tR\x05owner\x12\x1d\n" +
+	"\x04role\x18\a \x01(\x0e2\t.api.RoleR\x04role\"(\n" +
+	"\x10PlaylistsRequest\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x06R\x05index\"h\n" +
+	"\x11PlaylistsResponse\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x06R\x05index\x12%\n" +
+	"\x06update\x18\x02 \x03(\v2\r.api.PlaylistR\x06update\x12\x16\n" +
+	"\x06remove\x18\x03 \x03(\tR\x06remove\"'\n" +
+	"\x15PlaylistDeleteRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x18\n" +
+	"\x16PlaylistDeleteResponse\"Q\n" +
+	"\fShareRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x1d\n" +
+	"\x04role\x18\x03 \x01(\x0e2\t.api.RoleR\x04role\"\x0f\n" +
+	"\rShareResponse\"F\n" +
 	"\rUploadRequest\x12\x14\n" +
 	"\x04path\x18\x01 \x01(\tH\x00R\x04path\x12\x14\n" +
 	"\x04data\x18\x02 \x01(\fH\x00R\x04dataB\t\n" +
@@ -585,10 +1356,31 @@ const file_api_api_proto_rawDesc = "" +
 	"\x06status\x18\x01 \x01(\v2\x11.api.UploadStatusH\x00R\x06status\x12\x1c\n" +
 	"\bprogress\x18\x02 \x01(\x03H\x00R\bprogressB\n" +
 	"\n" +
-	"\bresponse2|\n" +
+	"\bresponse\"6\n" +
+	"\n" +
+	"QueueEntry\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x14\n" +
+	"\x05order\x18\x02 \x01(\x04R\x05order\"\x81\x01\n" +
+	"\n" +
+	"QueueState\x12)\n" +
+	"\aplaying\x18\x01 \x01(\v2\x0f.api.QueueEntryR\aplaying\x12\x1a\n" +
+	"\bshuffled\x18\x02 \x01(\bR\bshuffled\x12\x16\n" +
+	"\x06repeat\x18\x03 \x01(\rR\x06repeat\x12\x14\n" +
+	"\x05order\x18\x04 \x01(\x04R\x05order*G\n" +
+	"\x04Role\x12\r\n" +
+	"\tROLE_NONE\x10\x00\x12\x0f\n" +
+	"\vROLE_VIEWER\x10\x01\x12\x0f\n" +
+	"\vROLE_EDITOR\x10\x02\x12\x0e\n" +
+	"\n" +
+	"ROLE_OWNER\x10\x032|\n" +
 	"\aLibrary\x124\n" +
 	"\x03Get\x12\x13.api.LibraryRequest\x1a\x14.api.LibraryResponse\"\x000\x01\x12;\n" +
-	"\bDownload\x12\x14.api.DownloadRequest\x1a\x15.api.DownloadResponse\"\x000\x012B\n" +
+	"\bDownload\x12\x14.api.DownloadRequest\x1a\x15.api.DownloadResponse\"\x000\x012\xe4\x01\n" +
+	"\tPlaylists\x128\n" +
+	"\x03Get\x12\x15.api.PlaylistsRequest\x1a\x16.api.PlaylistsResponse\"\x000\x01\x12&\n" +
+	"\x04Save\x12\r.api.Playlist\x1a\r.api.Playlist\"\x00\x12C\n" +
+	"\x06Delete\x12\x1a.api.PlaylistDeleteRequest\x1a\x1b.api.PlaylistDeleteResponse\"\x00\x120\n" +
+	"\x05Share\x12\x11.api.ShareRequest\x1a\x12.api.ShareResponse\"\x002B\n" +
 	"\aDupload\x127\n" +
 	"\x06Upload\x12\x12.api.UploadRequest\x1a\x13.api.UploadResponse\"\x00(\x010\x01B\x1eZ\x1cgithub.com/bh90210/super/apib\x06proto3"
 
@@ -604,35 +1396,59 @@ func file_api_api_proto_rawDescGZIP() []byte {
 	return file_api_api_proto_rawDescData
 }
 
-var file_api_api_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_api_api_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_api_api_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_api_api_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
 var file_api_api_proto_goTypes = []any{
-	(UploadStatus_Status)(0), // 0: api.UploadStatus.Status
-	(*LibraryRequest)(nil),   // 1: api.LibraryRequest
-	(*LibraryResponse)(nil),  // 2: api.LibraryResponse
-	(*File)(nil),             // 3: api.File
-	(*DownloadRequest)(nil),  // 4: api.DownloadRequest
-	(*DownloadResponse)(nil), // 5: api.DownloadResponse
-	(*UploadRequest)(nil),    // 6: api.UploadRequest
-	(*UploadStatus)(nil),     // 7: api.UploadStatus
-	(*UploadResponse)(nil),   // 8: api.UploadResponse
+	(Role)(0),                      // 0: api.Role
+	(UploadStatus_Status)(0),       // 1: api.UploadStatus.Status
+	(*LibraryRequest)(nil),         // 2: api.LibraryRequest
+	(*LibraryResponse)(nil),        // 3: api.LibraryResponse
+	(*File)(nil),                   // 4: api.File
+	(*ReplayGain)(nil),             // 5: api.ReplayGain
+	(*DownloadRequest)(nil),        // 6: api.DownloadRequest
+	(*DownloadResponse)(nil),       // 7: api.DownloadResponse
+	(*Playlist)(nil),               // 8: api.Playlist
+	(*PlaylistsRequest)(nil),       // 9: api.PlaylistsRequest
+	(*PlaylistsResponse)(nil),      // 10: api.PlaylistsResponse
+	(*PlaylistDeleteRequest)(nil),  // 11: api.PlaylistDeleteRequest
+	(*PlaylistDeleteResponse)(nil), // 12: api.PlaylistDeleteResponse
+	(*ShareRequest)(nil),           // 13: api.ShareRequest
+	(*ShareResponse)(nil),          // 14: api.ShareResponse
+	(*UploadRequest)(nil),          // 15: api.UploadRequest
+	(*UploadStatus)(nil),           // 16: api.UploadStatus
+	(*UploadResponse)(nil),         // 17: api.UploadResponse
+	(*QueueEntry)(nil),             // 18: api.QueueEntry
+	(*QueueState)(nil),             // 19: api.QueueState
 }
 var file_api_api_proto_depIdxs = []int32{
-	3, // 0: api.LibraryResponse.add_index:type_name -> api.File
-	3, // 1: api.LibraryResponse.remove_index:type_name -> api.File
-	0, // 2: api.UploadStatus.status:type_name -> api.UploadStatus.Status
-	7, // 3: api.UploadResponse.status:type_name -> api.UploadStatus
-	1, // 4: api.Library.Get:input_type -> api.LibraryRequest
-	4, // 5: api.Library.Download:input_type -> api.DownloadRequest
-	6, // 6: api.Dupload.Upload:input_type -> api.UploadRequest
-	2, // 7: api.Library.Get:output_type -> api.LibraryResponse
-	5, // 8: api.Library.Download:output_type -> api.DownloadResponse
-	8, // 9: api.Dupload.Upload:output_type -> api.UploadResponse
-	7, // [7:10] is the sub-list for method output_type
-	4, // [4:7] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	4,  // 0: api.LibraryResponse.add_index:type_name -> api.File
+	4,  // 1: api.LibraryResponse.remove_index:type_name -> api.File
+	5,  // 2: api.File.replay_gain:type_name -> api.ReplayGain
+	0,  // 3: api.Playlist.role:type_name -> api.Role
+	8,  // 4: api.PlaylistsResponse.update:type_name -> api.Playlist
+	0,  // 5: api.ShareRequest.role:type_name -> api.Role
+	1,  // 6: api.UploadStatus.status:type_name -> api.UploadStatus.Status
+	16, // 7: api.UploadResponse.status:type_name -> api.UploadStatus
+	18, // 8: api.QueueState.playing:type_name -> api.QueueEntry
+	2,  // 9: api.Library.Get:input_type -> api.LibraryRequest
+	6,  // 10: api.Library.Download:input_type -> api.DownloadRequest
+	9,  // 11: api.Playlists.Get:input_type -> api.PlaylistsRequest
+	8,  // 12: api.Playlists.Save:input_type -> api.Playlist
+	11, // 13: api.Playlists.Delete:input_type -> api.PlaylistDeleteRequest
+	13, // 14: api.Playlists.Share:input_type -> api.ShareRequest
+	15, // 15: api.Dupload.Upload:input_type -> api.UploadRequest
+	3,  // 16: api.Library.Get:output_type -> api.LibraryResponse
+	7,  // 17: api.Library.Download:output_type -> api.DownloadResponse
+	10, // 18: api.Playlists.Get:output_type -> api.PlaylistsResponse
+	8,  // 19: api.Playlists.Save:output_type -> api.Playlist
+	12, // 20: api.Playlists.Delete:output_type -> api.PlaylistDeleteResponse
+	14, // 21: api.Playlists.Share:output_type -> api.ShareResponse
+	17, // 22: api.Dupload.Upload:output_type -> api.UploadResponse
+	16, // [16:23] is the sub-list for method output_type
+	9,  // [9:16] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_api_api_proto_init() }
@@ -640,11 +1456,11 @@ func file_api_api_proto_init() {
 	if File_api_api_proto != nil {
 		return
 	}
-	file_api_api_proto_msgTypes[5].OneofWrappers = []any{
+	file_api_api_proto_msgTypes[13].OneofWrappers = []any{
 		(*UploadRequest_Path)(nil),
 		(*UploadRequest_Data)(nil),
 	}
-	file_api_api_proto_msgTypes[7].OneofWrappers = []any{
+	file_api_api_proto_msgTypes[15].OneofWrappers = []any{
 		(*UploadResponse_Status)(nil),
 		(*UploadResponse_Progress)(nil),
 	}
@@ -653,10 +1469,10 @@ func file_api_api_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_api_proto_rawDesc), len(file_api_api_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   8,
+			NumEnums:      2,
+			NumMessages:   18,
 			NumExtensions: 0,
-			NumServices:   2,
+			NumServices:   3,
 		},
 		GoTypes:           file_api_api_proto_goTypes,
 		DependencyIndexes: file_api_api_proto_depIdxs,
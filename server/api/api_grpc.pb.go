@@ -164,6 +164,248 @@ var Library_ServiceDesc = grpc.ServiceDesc{
 	Metadata: "api/api.proto",
 }
 
+const (
+	Playlists_Get_FullMethodName    = "/api.Playlists/Get"
+	Playlists_Save_FullMethodName   = "/api.Playlists/Save"
+	Playlists_Delete_FullMethodName = "/api.Playlists/Delete"
+	Playlists_Share_FullMethodName  = "/api.Playlists/Share"
+)
+
+// PlaylistsClient is the client API for Playlists service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Playlists keeps playlists on the server, so they are the same on every
+// client of a user. Callers authenticate with a token issued by the server,
+// sent in the "authorization" metadata as "Bearer <token>".
+type PlaylistsClient interface {
+	// Get sends the playlists changed since the index, and then every change
+	// as it happens until the client hangs up.
+	Get(ctx context.Context, in *PlaylistsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PlaylistsResponse], error)
+	// Save creates a playlist, owned by the caller, or changes one the caller
+	// owns or edits. Of two versions the one modified last is kept.
+	Save(ctx context.Context, in *Playlist, opts ...grpc.CallOption) (*Playlist, error)
+	// Delete deletes a playlist, only its owner can.
+	Delete(ctx context.Context, in *PlaylistDeleteRequest, opts ...grpc.CallOption) (*PlaylistDeleteResponse, error)
+	// Share gives a user a role on a playlist, or takes it away with
+	// ROLE_NONE. Only the owner can share.
+	Share(ctx context.Context, in *ShareRequest, opts ...grpc.CallOption) (*ShareResponse, error)
+}
+
+type playlistsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPlaylistsClient(cc grpc.ClientConnInterface) PlaylistsClient {
+	return &playlistsClient{cc}
+}
+
+func (c *playlistsClient) Get(ctx context.Context, in *PlaylistsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PlaylistsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Playlists_ServiceDesc.Streams[0], Playlists_Get_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PlaylistsRequest, PlaylistsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Playlists_GetClient = grpc.ServerStreamingClient[PlaylistsResponse]
+
+func (c *playlistsClient) Save(ctx context.Context, in *Playlist, opts ...grpc.CallOption) (*Playlist, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Playlist)
+	err := c.cc.Invoke(ctx, Playlists_Save_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *playlistsClient) Delete(ctx context.Context, in *PlaylistDeleteRequest, opts ...grpc.CallOption) (*PlaylistDeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PlaylistDeleteResponse)
+	err := c.cc.Invoke(ctx, Playlists_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *playlistsClient) Share(ctx context.Context, in *ShareRequest, opts ...grpc.CallOption) (*ShareResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ShareResponse)
+	err := c.cc.Invoke(ctx, Playlists_Share_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PlaylistsServer is the server API for Playlists service.
+// All implementations must embed UnimplementedPlaylistsServer
+// for forward compatibility.
+//
+// Playlists keeps playlists on the server, so they are the same on every
+// client of a user. Callers authenticate with a token issued by the server,
+// sent in the "authorization" metadata as "Bearer <token>".
+type PlaylistsServer interface {
+	// Get sends the playlists changed since the index, and then every change
+	// as it happens until the client hangs up.
+	Get(*PlaylistsRequest, grpc.ServerStreamingServer[PlaylistsResponse]) error
+	// Save creates a playlist, owned by the caller, or changes one the caller
+	// owns or edits. Of two versions the one modified last is kept.
+	Save(context.Context, *Playlist) (*Playlist, error)
+	// Delete deletes a playlist, only its owner can.
+	Delete(context.Context, *PlaylistDeleteRequest) (*PlaylistDeleteResponse, error)
+	// Share gives a user a role on a playlist, or takes it away with
+	// ROLE_NONE. Only the owner can share.
+	Share(context.Context, *ShareRequest) (*ShareResponse, error)
+	mustEmbedUnimplementedPlaylistsServer()
+}
+
+// UnimplementedPlaylistsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPlaylistsServer struct{}
+
+func (UnimplementedPlaylistsServer) Get(*PlaylistsRequest, grpc.ServerStreamingServer[PlaylistsResponse]) error {
+	return status.Error(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedPlaylistsServer) Save(context.Context, *Playlist) (*Playlist, error) {
+	return nil, status.Error(codes.Unimplemented, "method Save not implemented")
+}
+func (UnimplementedPlaylistsServer) Delete(context.Context, *PlaylistDeleteRequest) (*PlaylistDeleteResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedPlaylistsServer) Share(context.Context, *ShareRequest) (*ShareResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Share not implemented")
+}
+func (UnimplementedPlaylistsServer) mustEmbedUnimplementedPlaylistsServer() {}
+func (UnimplementedPlaylistsServer) testEmbeddedByValue()                   {}
+
+// UnsafePlaylistsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PlaylistsServer will
+// result in compilation errors.
+type UnsafePlaylistsServer interface {
+	mustEmbedUnimplementedPlaylistsServer()
+}
+
+func RegisterPlaylistsServer(s grpc.ServiceRegistrar, srv PlaylistsServer) {
+	// If the following call panics, it indicates UnimplementedPlaylistsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Playlists_ServiceDesc, srv)
+}
+
+func _Playlists_Get_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PlaylistsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PlaylistsServer).Get(m, &grpc.GenericServerStream[PlaylistsRequest, PlaylistsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Playlists_GetServer = grpc.ServerStreamingServer[PlaylistsResponse]
+
+func _Playlists_Save_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Playlist)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlaylistsServer).Save(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Playlists_Save_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlaylistsServer).Save(ctx, req.(*Playlist))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Playlists_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PlaylistDeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlaylistsServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Playlists_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlaylistsServer).Delete(ctx, req.(*PlaylistDeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Playlists_Share_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlaylistsServer).Share(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Playlists_Share_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlaylistsServer).Share(ctx, req.(*ShareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Playlists_ServiceDesc is the grpc.ServiceDesc for Playlists service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Playlists_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "api.Playlists",
+	HandlerType: (*PlaylistsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Save",
+			Handler:    _Playlists_Save_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Playlists_Delete_Handler,
+		},
+		{
+			MethodName: "Share",
+			Handler:    _Playlists_Share_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Get",
+			Handler:       _Playlists_Get_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/api.proto",
+}
+
 const (
 	Dupload_Upload_FullMethodName = "/api.Dupload/Upload"
 )
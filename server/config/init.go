@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"github.com/bh90210/super/server/api"
 	"github.com/bh90210/super/server/dupload"
 	"github.com/bh90210/super/server/library"
+	"github.com/bh90210/super/server/playlists"
 	dgo "github.com/dgraph-io/dgo/v250"
 	min "github.com/minio/minio-go/v7"
 	miniocreds "github.com/minio/minio-go/v7/pkg/credentials"
@@ -49,20 +51,44 @@ type Config struct {
 }
 
 func Init(configPath string) error {
+	config, err := load(configPath)
+	if err != nil {
+		return err
+	}
+
+	// Start backend services.
+	return start(config)
+}
+
+// Token returns the token user authenticates to the playlists with, on
+// the server configured at configPath.
+func Token(configPath, user string) (string, error) {
+	config, err := load(configPath)
+	if err != nil {
+		return "", err
+	}
+
+	if config.Server.TokenSecret == "" || user == "" {
+		return "", errors.New("token_secret and user are required")
+	}
+
+	return playlists.Token([]byte(config.Server.TokenSecret), user), nil
+}
+
+func load(configPath string) (*Config, error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		slog.Error("failed to read config file", slog.String("error", err.Error()))
-		return err
+		return nil, err
 	}
 
 	var config Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		slog.Error("failed to unmarshal config", slog.String("error", err.Error()))
-		return err
+		return nil, err
 	}
 
-	// Start backend services.
-	return start(&config)
+	return &config, nil
 }
 
 func start(c *Config) error {
@@ -94,17 +120,7 @@ func start(c *Config) error {
 		return err
 	}
 
-	// Keto client.
-	ketoRead, ketoWrite, err := c.Keto.connect()
-	if err != nil {
-		slog.Error("keto client", slog.String("error", err.Error()))
-		return err
-	}
-
-	_ = dgraphClient
 	_ = minioClient
-	_ = ketoRead
-	_ = ketoWrite
 
 	libraryService, err := library.NewService(c.Server.LibraryPath)
 	if err != nil {
@@ -118,6 +134,25 @@ func start(c *Config) error {
 		return err
 	}
 
+	// The playlists are only served with a secret to sign their tokens.
+	var playlistsService *playlists.Service
+	if c.Server.TokenSecret == "" {
+		slog.Warn("token_secret is not set, the playlists are not served")
+	} else {
+		// Keto client.
+		ketoRead, ketoWrite, err := c.Keto.connect()
+		if err != nil {
+			slog.Error("keto client", slog.String("error", err.Error()))
+			return err
+		}
+
+		playlistsService, err = playlists.NewService(dgraphClient, ketoRead, ketoWrite, []byte(c.Server.TokenSecret))
+		if err != nil {
+			slog.Error("failed to create playlists service", slog.String("error", err.Error()))
+			return err
+		}
+	}
+
 	// Create SSL credentials.
 	creds, err := credentials.NewServerTLSFromFile(c.Server.SSLCertPath, c.Server.SSLKeyPath)
 	if err != nil {
@@ -125,12 +160,23 @@ func start(c *Config) error {
 		return err
 	}
 
-	// Use Credentials in gRPC server options.
-	serverOption := grpc.Creds(creds)
-	grpcServer := grpc.NewServer(serverOption)
+	// Use Credentials in gRPC server options, and authenticate the
+	// playlists callers before they reach the service.
+	options := []grpc.ServerOption{grpc.Creds(creds)}
+	if playlistsService != nil {
+		options = append(options,
+			grpc.UnaryInterceptor(playlistsService.UnaryInterceptor),
+			grpc.StreamInterceptor(playlistsService.StreamInterceptor),
+		)
+	}
+
+	grpcServer := grpc.NewServer(options...)
 
 	api.RegisterLibraryServer(grpcServer, libraryService)
 	api.RegisterDuploadServer(grpcServer, duploadService)
+	if playlistsService != nil {
+		api.RegisterPlaylistsServer(grpcServer, playlistsService)
+	}
 
 	lis, err := net.Listen("tcp", c.Server.ListenAddress+":"+c.Server.ListenPort)
 	if err != nil {
@@ -149,9 +195,13 @@ func start(c *Config) error {
 }
 
 type server struct {
-	LibraryPath   string `yaml:"library_path"`
-	SSLCertPath   string `yaml:"ssl_cert_path"`
-	SSLKeyPath    string `yaml:"ssl_key_path"`
+	LibraryPath string `yaml:"library_path"`
+	SSLCertPath string `yaml:"ssl_cert_path"`
+	SSLKeyPath  string `yaml:"ssl_key_path"`
+	// TokenSecret signs the tokens users authenticate to the playlists
+	// with, changing it invalidates every token. Without it the playlists
+	// are not served.
+	TokenSecret   string `yaml:"token_secret"`
 	ListenPort    string `yaml:"listen_port"`
 	MetricsPort   string `yaml:"metrics_port"`
 	ListenAddress string `yaml:"listen_address"`
@@ -241,14 +291,13 @@ func (k *keto) connect() (*grpc.ClientConn, *grpc.ClientConn, error) {
 		slog.Error("failed to connect to keto read server", slog.String("error", err.Error()))
 		return nil, nil, err
 	}
-	defer readConn.Close()
 
 	writeConn, err := grpc.NewClient(k.WriteAddress, dialOpts...)
 	if err != nil {
 		slog.Error("failed to connect to keto write server", slog.String("error", err.Error()))
+		readConn.Close()
 		return nil, nil, err
 	}
-	defer writeConn.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -0,0 +1,118 @@
+package library
+
+import (
+	"encoding/gob"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// checksumsFile keeps the checksums of the library between scans, at its
+// root. Hashing every file on every start takes long for a big library.
+const checksumsFile = ".super-checksums"
+
+// checksum is the SHA-256 of a file as it was when hashed.
+type checksum struct {
+	Size    int64
+	ModTime int64
+	Sum     string
+}
+
+// checksums are the ones known from the last scan, by path, and the ones
+// of this scan, which replace them once it is over.
+type checksums struct {
+	path string
+	last map[string]checksum
+	scan map[string]checksum
+	mu   sync.Mutex
+}
+
+// loadChecksums reads the checksums the last scan of root left. Without
+// them every file is hashed.
+func loadChecksums(root string) *checksums {
+	c := &checksums{
+		path: filepath.Join(root, checksumsFile),
+		last: make(map[string]checksum),
+		scan: make(map[string]checksum),
+	}
+
+	f, err := os.Open(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c
+	}
+
+	if err != nil {
+		slog.Error("os.Open", "path", c.path, "error", err)
+		return c
+	}
+
+	defer f.Close()
+
+	err = gob.NewDecoder(f).Decode(&c.last)
+	if err != nil {
+		slog.Error("reading the library checksums", "path", c.path, "error", err)
+		c.last = make(map[string]checksum)
+	}
+
+	return c
+}
+
+// get returns the checksum of the file at path, if it didn't change since
+// it was hashed.
+func (c *checksums) get(path string, info fs.FileInfo) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sum, ok := c.last[path]
+	if !ok || sum.Size != info.Size() || sum.ModTime != info.ModTime().UnixNano() {
+		return "", false
+	}
+
+	c.scan[path] = sum
+
+	return sum.Sum, true
+}
+
+// put keeps the checksum of the file at path for the next scan.
+func (c *checksums) put(path string, info fs.FileInfo, sum string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.scan[path] = checksum{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Sum:     sum,
+	}
+}
+
+// save writes the checksums of this scan, the files gone since the last
+// one are dropped. It is written aside first, a scan interrupted halfway
+// leaves the last checksums.
+func (c *checksums) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tmp := c.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	err = gob.NewEncoder(f).Encode(c.scan)
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, c.path)
+}
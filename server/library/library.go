@@ -1,6 +1,7 @@
 package library
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
@@ -15,7 +16,8 @@ import (
 	"github.com/bh90210/super/server/api"
 	"github.com/charlievieth/fastwalk"
 	"github.com/dhowden/tag"
-	"github.com/hajimehoshi/go-mp3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var _ api.LibraryServer = (*Service)(nil)
@@ -29,14 +31,31 @@ type Service struct {
 }
 
 func NewService(libraryPath string) (*Service, error) {
+	files, err := Scan(libraryPath)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &Service{
 		LibraryPath: libraryPath,
 		CachedLibrary: &api.LibraryResponse{
-			AddIndex:    []*api.File{},
+			AddIndex:    files,
 			RemoveIndex: []*api.File{},
 		},
 	}
 
+	return s, nil
+}
+
+// Scan walks root and returns every supported audio file found under it.
+// Paths are relative to root. Only files new or changed since the last
+// scan are hashed.
+func Scan(root string) ([]*api.File, error) {
+	var files []*api.File
+	var mu sync.Mutex
+
+	sums := loadChecksums(root)
+
 	walkFn := func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			fmt.Println("walk", "path", path, "error", err)
@@ -44,69 +63,112 @@ func NewService(libraryPath string) (*Service, error) {
 		}
 
 		if !d.IsDir() {
-			if filepath.Ext(path) != ".mp3" {
+			probe, ok := probes[strings.ToLower(filepath.Ext(path))]
+			if !ok {
+				return nil
+			}
+
+			// A file that can't be read is skipped, the rest of the
+			// library is still scanned.
+			info, err := d.Info()
+			if err != nil {
+				fmt.Println("d.Info", "path", path, "error", err)
 				return nil
 			}
 
 			f, err := os.Open(path)
 			if err != nil {
 				fmt.Println("os.Open", "path", path, "error", err)
-				return err
+				return nil
 			}
 
 			defer f.Close()
 
-			decodedMp3, err := mp3.NewDecoder(f)
+			format, length, err := probe(f)
 			if err != nil {
-				fmt.Println("mp3.NewDecoder", "path", path, "error", err)
-				return err
+				fmt.Println("probe", "path", path, "error", err)
+				return nil
 			}
 
-			samples := decodedMp3.Length() / 4
-			length := int(samples) / decodedMp3.SampleRate()
+			// Whole seconds, as always shown.
+			d := length.Truncate(time.Second)
+
+			cleanPath := strings.Replace(path, root, "", 1)
+
+			checksum, ok := sums.get(cleanPath, info)
+			if !ok {
+				h := sha256.New()
+				_, err = f.Seek(0, io.SeekStart)
+				if err == nil {
+					_, err = io.Copy(h, f)
+				}
+				if err != nil {
+					fmt.Println("checksum", "path", path, "error", err)
+					return nil
+				}
+
+				checksum = fmt.Sprintf("%x", h.Sum(nil))
+				sums.put(cleanPath, info, checksum)
+			}
 
-			d := time.Duration(length * int(time.Second))
+			// Tags are read from the start, the probe and the checksum
+			// read on.
+			_, err = f.Seek(0, io.SeekStart)
+			if err != nil {
+				fmt.Println("f.Seek", "path", path, "error", err)
+				return nil
+			}
 
 			m, err := tag.ReadFrom(f)
 			if err != nil && !errors.Is(err, tag.ErrNoTagsFound) {
 				fmt.Println("tag.ReadFrom", "path", path, "error", err)
-				return err
+				return nil
 			}
 
-			cleanPath := strings.Replace(path, s.LibraryPath, "", 1)
-
-			if errors.Is(err, tag.ErrNoTagsFound) {
-				s.mu.Lock()
-				s.CachedLibrary.AddIndex = append(s.CachedLibrary.AddIndex, &api.File{
-					Artist: filepath.Base(path),
-					Path:   cleanPath,
-				})
-				s.mu.Unlock()
+			file := &api.File{
+				Artist:          filepath.Base(path),
+				Duration:        d.String(),
+				Path:            cleanPath,
+				Checksum:        checksum,
+				Format:          format,
+				DurationSeconds: length.Seconds(),
+				Added:           info.ModTime().Unix(),
+			}
 
-				return nil
+			if err == nil {
+				number, _ := m.Track()
+
+				file.Artist = strings.ToValidUTF8(m.Artist(), "")
+				file.Album = strings.ToValidUTF8(m.Album(), "")
+				file.Track = strings.ToValidUTF8(m.Title(), "")
+				file.Genre = strings.ToValidUTF8(m.Genre(), "")
+				file.Year = uint32(max(0, m.Year()))
+				file.TrackNumber = uint32(max(0, number))
+				// Applied by the player when normalising loudness.
+				file.ReplayGain = replayGain(m)
 			}
 
-			s.mu.Lock()
-			s.CachedLibrary.AddIndex = append(s.CachedLibrary.AddIndex, &api.File{
-				Artist:   strings.ToValidUTF8(m.Artist(), ""),
-				Album:    strings.ToValidUTF8(m.Album(), ""),
-				Track:    strings.ToValidUTF8(m.Title(), ""),
-				Duration: strings.ToValidUTF8(d.String(), ""),
-				Path:     cleanPath,
-			})
-			s.mu.Unlock()
+			mu.Lock()
+			files = append(files, file)
+			mu.Unlock()
 		}
 
 		return nil
 	}
 
-	err := fastwalk.Walk(&fastwalk.DefaultConfig, s.LibraryPath, walkFn)
+	err := fastwalk.Walk(&fastwalk.DefaultConfig, root, walkFn)
 	if err != nil {
-		fmt.Println("fastwalk.Walk", "path", s.LibraryPath, "error", err)
+		fmt.Println("fastwalk.Walk", "path", root, "error", err)
 		return nil, err
 	}
 
-	return s, nil
+	// Without them the next scan hashes every file again.
+	err = sums.save()
+	if err != nil {
+		slog.Error("saving the library checksums", "path", root, "error", err)
+	}
+
+	return files, nil
 }
 
 func (s *Service) Get(request *api.LibraryRequest, response api.Library_GetServer) (err error) {
@@ -144,6 +206,11 @@ func (s *Service) Download(request *api.DownloadRequest, response api.Library_Do
 	slog.Info("Download", "request", request)
 
 	f, err := os.Open(filepath.Join(s.LibraryPath, request.Path))
+	if errors.Is(err, fs.ErrNotExist) {
+		// Clients skip tracks that are gone rather than retrying them.
+		return status.Error(codes.NotFound, request.Path)
+	}
+
 	if err != nil {
 		fmt.Println("os.ReadFile", "path", request.Path, "error", err)
 		return err
@@ -151,6 +218,14 @@ func (s *Service) Download(request *api.DownloadRequest, response api.Library_Do
 
 	defer f.Close()
 
+	if request.Offset > 0 {
+		_, err = f.Seek(request.Offset, io.SeekStart)
+		if err != nil {
+			fmt.Println("f.Seek", "path", request.Path, "offset", request.Offset, "error", err)
+			return err
+		}
+	}
+
 	for {
 		buf := make([]byte, 1024*1024)
 		n, err := f.Read(buf)
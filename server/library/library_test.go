@@ -0,0 +1,124 @@
+package library
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// wav returns a 16bit stereo wav of seconds of silence at 44.1kHz, with a
+// list chunk before the data like most encoders write.
+func wav(seconds int) []byte {
+	const byteRate = 44100 * 4
+	size := uint32(seconds * byteRate)
+
+	b := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint32(b, 44100)
+	b = binary.LittleEndian.AppendUint32(b, byteRate)
+	b = binary.LittleEndian.AppendUint16(b, 4)
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	// Odd, so padded.
+	b = append(b, "LIST"...)
+	b = binary.LittleEndian.AppendUint32(b, 3)
+	b = append(b, "abc\x00"...)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, size)
+	b = append(b, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	return b
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "silence.wav")
+
+	err := os.WriteFile(path, wav(3), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scan := func() string {
+		t.Helper()
+
+		files, err := Scan(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(files) != 1 {
+			t.Fatalf("scanned %d files, want 1", len(files))
+		}
+
+		if files[0].Format != "wav" || files[0].Duration != "3s" {
+			t.Errorf("scanned %s of %s, want wav of 3s", files[0].Format, files[0].Duration)
+		}
+
+		return files[0].Checksum
+	}
+
+	first := scan()
+
+	// The same size and time are taken for the same file, it isn't hashed
+	// again.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := wav(3)
+	changed[len(changed)-1] = 1
+	err = os.WriteFile(path, changed, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chtimes(path, info.ModTime(), info.ModTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := scan(); got != first {
+		t.Errorf("checksum %s of the unchanged file, want %s", got, first)
+	}
+
+	err = os.Chtimes(path, info.ModTime(), info.ModTime().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := scan(); got == first {
+		t.Error("a changed file kept its checksum")
+	}
+}
+
+func TestProbeWAV(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "*.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	_, err = f.Write([]byte("RIFF\x0c\x00\x00\x00WAVEdata\x00\x00\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.Seek(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Data before the format can't be timed.
+	_, _, err = probeWAV(f)
+	if !errors.Is(err, errWAV) {
+		t.Errorf("probing a wav without a format: %v, want errWAV", err)
+	}
+}
@@ -0,0 +1,151 @@
+package library
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/jfreymuth/oggvorbis"
+	"github.com/mewkiz/flac"
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+// probes find the format and duration of a file, by extension. Files of
+// other extensions are not part of the library.
+var probes = map[string]func(io.ReadSeeker) (string, time.Duration, error){
+	".mp3":  probeMP3,
+	".flac": probeFLAC,
+	".ogg":  probeOgg,
+	".oga":  probeOgg,
+	".opus": probeOpus,
+	".wav":  probeWAV,
+}
+
+func probeMP3(r io.ReadSeeker) (string, time.Duration, error) {
+	d, err := mp3.NewDecoder(r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// 16 bit stereo.
+	samples := d.Length() / 4
+
+	return "mp3", seconds(samples, d.SampleRate()), nil
+}
+
+func probeFLAC(r io.ReadSeeker) (string, time.Duration, error) {
+	stream, err := flac.New(r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return "flac", seconds(int64(stream.Info.NSamples), int(stream.Info.SampleRate)), nil
+}
+
+// probeOgg tells Vorbis from Opus, both come as .ogg.
+func probeOgg(r io.ReadSeeker) (string, time.Duration, error) {
+	length, format, err := oggvorbis.GetLength(r)
+	if err == nil {
+		return "vorbis", seconds(length, format.SampleRate), nil
+	}
+
+	_, seekErr := r.Seek(0, io.SeekStart)
+	if seekErr != nil {
+		return "", 0, seekErr
+	}
+
+	return probeOpus(r)
+}
+
+// probeOpus reads the granule position of the last page, which counts
+// 48kHz samples including the pre-skip.
+func probeOpus(r io.ReadSeeker) (string, time.Duration, error) {
+	reader, header, err := oggreader.NewWith(r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var granule uint64
+	for {
+		_, page, err := reader.ParseNextPage()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return "", 0, err
+		}
+
+		granule = page.GranulePosition
+	}
+
+	samples := max(0, int64(granule)-int64(header.PreSkip))
+
+	return "opus", seconds(samples, 48000), nil
+}
+
+var errWAV = errors.New("not a wav file")
+
+// probeWAV reads the chunks of a RIFF file up to the data, whose length
+// over the byte rate of the format is the duration.
+func probeWAV(r io.ReadSeeker) (string, time.Duration, error) {
+	var header [12]byte
+	_, err := io.ReadFull(r, header[:])
+	if err != nil {
+		return "", 0, err
+	}
+
+	if string(header[:4]) != "RIFF" || string(header[8:]) != "WAVE" {
+		return "", 0, errWAV
+	}
+
+	var byteRate uint32
+	for {
+		var chunk [8]byte
+		_, err := io.ReadFull(r, chunk[:])
+		if err != nil {
+			return "", 0, err
+		}
+
+		size := binary.LittleEndian.Uint32(chunk[4:])
+
+		switch string(chunk[:4]) {
+		case "fmt ":
+			var format [16]byte
+			if size < uint32(len(format)) {
+				return "", 0, errWAV
+			}
+
+			_, err = io.ReadFull(r, format[:])
+			if err != nil {
+				return "", 0, err
+			}
+
+			byteRate = binary.LittleEndian.Uint32(format[8:])
+			size -= uint32(len(format))
+
+		case "data":
+			if byteRate == 0 {
+				return "", 0, errWAV
+			}
+
+			return "wav", time.Duration(size) * time.Second / time.Duration(byteRate), nil
+		}
+
+		// Chunks are padded to an even size.
+		_, err = r.Seek(int64(size+size%2), io.SeekCurrent)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+}
+
+func seconds(samples int64, rate int) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(samples) * time.Second / time.Duration(rate)
+}
@@ -0,0 +1,97 @@
+package library
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/dhowden/tag"
+)
+
+// r128Offset is how much lower the R128 reference of -23 LUFS is than
+// the ReplayGain one of -18 LUFS, in dB.
+const r128Offset = 5
+
+// replayGain reads the ReplayGain tags of m, or the R128 ones of Opus
+// files. It returns nil if there are none.
+func replayGain(m tag.Metadata) *api.ReplayGain {
+	values := make(map[string]string)
+	for k, v := range m.Raw() {
+		switch v := v.(type) {
+		case string:
+			// Vorbis comments, keyed by their lowercase name.
+			values[strings.ToLower(k)] = v
+
+		case *tag.Comm:
+			// ID3v2 TXXX frames, keyed by their description.
+			if strings.HasPrefix(k, "TXX") {
+				values[strings.ToLower(v.Description)] = v.Text
+			}
+		}
+	}
+
+	rg := &api.ReplayGain{}
+
+	trackGain, track := decibels(values["replaygain_track_gain"])
+	albumGain, album := decibels(values["replaygain_album_gain"])
+
+	// R128 gains are Q7.8 fixed point numbers.
+	if !track {
+		q, err := strconv.Atoi(strings.TrimSpace(values["r128_track_gain"]))
+		if err == nil {
+			trackGain, track = float64(q)/256+r128Offset, true
+		}
+	}
+
+	if !album {
+		q, err := strconv.Atoi(strings.TrimSpace(values["r128_album_gain"]))
+		if err == nil {
+			albumGain, album = float64(q)/256+r128Offset, true
+		}
+	}
+
+	if !track && !album {
+		return nil
+	}
+
+	rg.TrackGain, rg.AlbumGain = trackGain, albumGain
+	rg.TrackPeak = peak(values["replaygain_track_peak"])
+	rg.AlbumPeak = peak(values["replaygain_album_peak"])
+
+	// Fill in whichever is missing with the other.
+	if !track {
+		rg.TrackGain, rg.TrackPeak = rg.AlbumGain, rg.AlbumPeak
+	}
+
+	if !album {
+		rg.AlbumGain, rg.AlbumPeak = rg.TrackGain, rg.TrackPeak
+	}
+
+	return rg
+}
+
+// decibels parses a gain like "-6.54 dB".
+func decibels(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(s, "dB"), "db"))
+	if s == "" {
+		return 0, false
+	}
+
+	gain, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return gain, true
+}
+
+// peak parses a peak, 0 if unknown.
+func peak(s string) float64 {
+	p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+
+	return p
+}
@@ -2,14 +2,26 @@ package main
 
 import (
 	"flag"
+	"fmt"
 
 	"github.com/bh90210/super/server/config"
 )
 
 func main() {
 	configPath := flag.String("config", "config.yaml", "Path to configuration file")
+	user := flag.String("token", "", "Print the playlists token of this user and exit")
 	flag.Parse()
 
+	if *user != "" {
+		token, err := config.Token(*configPath, *user)
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Println(token)
+		return
+	}
+
 	err := config.Init(*configPath)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,122 @@
+package playlists
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+
+	"github.com/bh90210/super/server/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// A token identifies a user to the service. It is the user and an HMAC of
+// the user with the server's secret, each base64url encoded and joined by
+// a dot, sent as "authorization: Bearer <token>". Tokens are given out
+// with server -token <user> and last until the secret changes.
+
+// methods prefixes the full names of the methods of the service, the only
+// ones authenticated.
+var methods = "/" + api.Playlists_ServiceDesc.ServiceName + "/"
+
+type userKey struct{}
+
+// Token returns the token of user for a server with secret.
+func Token(secret []byte, user string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(user)) + "." + base64.RawURLEncoding.EncodeToString(sign(secret, user))
+}
+
+func sign(secret []byte, user string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(user))
+
+	return mac.Sum(nil)
+}
+
+// verify returns the user of token, if it was signed with secret.
+func verify(secret []byte, token string) (string, bool) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", false
+	}
+
+	user, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(user) == 0 {
+		return "", false
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, sign(secret, string(user))) {
+		return "", false
+	}
+
+	return string(user), true
+}
+
+// authenticate returns ctx with the user of the token the caller sent.
+func (s *Service) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if !ok {
+			continue
+		}
+
+		if user, ok := verify(s.secret, token); ok {
+			return context.WithValue(ctx, userKey{}, user), nil
+		}
+	}
+
+	return nil, errNoUser
+}
+
+// user returns who is calling, as authenticated.
+func user(ctx context.Context) (string, error) {
+	user, _ := ctx.Value(userKey{}).(string)
+	if user == "" {
+		return "", errNoUser
+	}
+
+	return user, nil
+}
+
+// UnaryInterceptor authenticates the calls to the service, and lets the
+// calls to other services through.
+func (s *Service) UnaryInterceptor(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !strings.HasPrefix(info.FullMethod, methods) {
+		return handler(ctx, request)
+	}
+
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, request)
+}
+
+// StreamInterceptor is UnaryInterceptor for streams.
+func (s *Service) StreamInterceptor(server any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !strings.HasPrefix(info.FullMethod, methods) {
+		return handler(server, stream)
+	}
+
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(server, &authenticated{ServerStream: stream, ctx: ctx})
+}
+
+// authenticated is a stream with the context of its caller.
+type authenticated struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authenticated) Context() context.Context {
+	return a.ctx
+}
@@ -0,0 +1,136 @@
+package playlists
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/bh90210/super/server/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var secret = []byte("secret")
+
+func TestVerify(t *testing.T) {
+	token := Token(secret, "alice")
+	encoded, signature, _ := strings.Cut(token, ".")
+
+	// A signature of another user.
+	_, bob, _ := strings.Cut(Token(secret, "bob"), ".")
+
+	tests := []struct {
+		name  string
+		token string
+		user  string
+	}{
+		{"valid", token, "alice"},
+		{"signed with another secret", Token([]byte("other"), "alice"), ""},
+		{"tampered signature", encoded + "." + bob, ""},
+		{"tampered user", base64.RawURLEncoding.EncodeToString([]byte("bob")) + "." + signature, ""},
+		{"missing dot", encoded + signature, ""},
+		{"missing signature", encoded + ".", ""},
+		{"missing user", "." + signature, ""},
+		{"not base64", "alice." + signature, ""},
+		{"empty", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user, ok := verify(secret, test.token)
+			if user != test.user || ok != (test.user != "") {
+				t.Errorf("verify(%q) = %q, %v, want %q", test.token, user, ok, test.user)
+			}
+		})
+	}
+}
+
+// incoming returns a context of a call sending authorization.
+func incoming(authorization ...string) context.Context {
+	md := metadata.MD{}
+	for _, value := range authorization {
+		md.Append("authorization", value)
+	}
+
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+// stream is a server stream of ctx.
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stream) Context() context.Context {
+	return s.ctx
+}
+
+func TestInterceptors(t *testing.T) {
+	s := &Service{secret: secret}
+
+	playlists := methods + "List"
+	library := "/" + api.Library_ServiceDesc.ServiceName + "/Get"
+
+	tests := []struct {
+		name   string
+		method string
+		ctx    context.Context
+		// user is who the handler sees, empty if it isn't called.
+		user string
+	}{
+		{"playlists with a token", playlists, incoming("Bearer " + Token(secret, "alice")), "alice"},
+		{"playlists after another header", playlists, incoming("Basic abc", "Bearer "+Token(secret, "alice")), "alice"},
+		{"playlists without a token", playlists, incoming(), ""},
+		{"playlists without metadata", playlists, context.Background(), ""},
+		{"playlists without Bearer", playlists, incoming(Token(secret, "alice")), ""},
+		{"playlists with a bad token", playlists, incoming("Bearer " + Token([]byte("other"), "alice")), ""},
+		{"another service without a token", library, context.Background(), "-"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := func(called bool, user string, err error) {
+				t.Helper()
+
+				switch test.user {
+				case "":
+					if called || status.Code(err) != codes.Unauthenticated {
+						t.Errorf("called %v, error %v, want Unauthenticated", called, err)
+					}
+
+				case "-":
+					if !called || err != nil {
+						t.Errorf("called %v, error %v, want it through", called, err)
+					}
+
+				default:
+					if !called || err != nil || user != test.user {
+						t.Errorf("called %v as %q, error %v, want %q", called, user, err, test.user)
+					}
+				}
+			}
+
+			var called bool
+			var caller string
+			_, err := s.UnaryInterceptor(test.ctx, nil, &grpc.UnaryServerInfo{FullMethod: test.method},
+				func(ctx context.Context, request any) (any, error) {
+					called = true
+					caller, _ = user(ctx)
+					return nil, nil
+				})
+			check(called, caller, err)
+
+			called, caller = false, ""
+			err = s.StreamInterceptor(nil, &stream{ctx: test.ctx}, &grpc.StreamServerInfo{FullMethod: test.method},
+				func(server any, stream grpc.ServerStream) error {
+					called = true
+					caller, _ = user(stream.Context())
+					return nil
+				})
+			check(called, caller, err)
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package playlists
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bh90210/super/server/api"
+	dgo "github.com/dgraph-io/dgo/v250"
+	dgapi "github.com/dgraph-io/dgo/v250/protos/api"
+)
+
+// schema is the Dgraph schema of the playlists. Tracks are a JSON array,
+// Dgraph lists are sets and lose the order. Revision is the index the
+// playlist last changed at, deleted playlists stay as tombstones, and
+// revoked are the users it was unshared with, or shared with when deleted,
+// so they learn it is gone once nothing in Keto relates them to it.
+const schema = `
+playlist.id: string @index(exact) @upsert .
+playlist.name: string .
+playlist.tracks: string .
+playlist.owner: string .
+playlist.created: int .
+playlist.modified: int .
+playlist.revision: int @index(int) .
+playlist.deleted: bool .
+playlist.revoked: [string] @index(exact) .
+
+type Playlist {
+	playlist.id
+	playlist.name
+	playlist.tracks
+	playlist.owner
+	playlist.created
+	playlist.modified
+	playlist.revision
+	playlist.deleted
+	playlist.revoked
+}
+`
+
+// fields are the predicates queried for a playlist.
+const fields = `uid playlist.id playlist.name playlist.tracks playlist.owner playlist.created playlist.modified playlist.revision playlist.deleted`
+
+// node is a playlist as stored in Dgraph.
+type node struct {
+	UID      string `json:"uid,omitempty"`
+	Type     string `json:"dgraph.type,omitempty"`
+	ID       string `json:"playlist.id"`
+	Name     string `json:"playlist.name"`
+	Tracks   string `json:"playlist.tracks"`
+	Owner    string `json:"playlist.owner"`
+	Created  int64  `json:"playlist.created"`
+	Modified int64  `json:"playlist.modified"`
+	Revision uint64 `json:"playlist.revision"`
+	Deleted  bool   `json:"playlist.deleted"`
+}
+
+func newNode(playlist *api.Playlist) (*node, error) {
+	tracks, err := json.Marshal(playlist.Tracks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &node{
+		Type:     "Playlist",
+		ID:       playlist.Id,
+		Name:     playlist.Name,
+		Tracks:   string(tracks),
+		Owner:    playlist.Owner,
+		Created:  playlist.Created,
+		Modified: playlist.Modified,
+	}, nil
+}
+
+// playlist returns the playlist of the node, as seen by a user of role.
+func (n *node) playlist(role api.Role) (*api.Playlist, error) {
+	var tracks []string
+	if n.Tracks != "" {
+		err := json.Unmarshal([]byte(n.Tracks), &tracks)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &api.Playlist{
+		Id:       n.ID,
+		Name:     n.Name,
+		Tracks:   tracks,
+		Created:  n.Created,
+		Modified: n.Modified,
+		Owner:    n.Owner,
+		Role:     role,
+	}, nil
+}
+
+// load returns the playlist with id, nil if there is none.
+func load(ctx context.Context, txn *dgo.Txn, id string) (*node, error) {
+	q := `query q($id: string) { q(func: eq(playlist.id, $id)) { ` + fields + ` } }`
+
+	response, err := txn.QueryWithVars(ctx, q, map[string]string{"$id": id})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Q []*node `json:"q"`
+	}
+
+	err = json.Unmarshal(response.Json, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Q) == 0 {
+		return nil, nil
+	}
+
+	return result.Q[0], nil
+}
+
+// save stores n, a new node if it has no uid.
+func save(ctx context.Context, txn *dgo.Txn, n *node) error {
+	b, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	_, err = txn.Mutate(ctx, &dgapi.Mutation{SetJson: b})
+	return err
+}
+
+// setRevoked adds user to the users n was unshared with, or removes them.
+func setRevoked(ctx context.Context, txn *dgo.Txn, n *node, user string, revoked bool) error {
+	value, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	quad := []byte(fmt.Sprintf("<%s> <playlist.revoked> %s .", n.UID, value))
+
+	mutation := &dgapi.Mutation{SetNquads: quad}
+	if !revoked {
+		mutation = &dgapi.Mutation{DelNquads: quad}
+	}
+
+	_, err = txn.Mutate(ctx, mutation)
+	return err
+}
+
+// since returns the playlists of ids changed after index, and the ids of
+// the ones unshared with user since.
+func since(ctx context.Context, txn *dgo.Txn, index uint64, ids []string, user string) ([]*node, []string, error) {
+	// Ids are checked when saved, they can be quoted as they are.
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = `"` + id + `"`
+	}
+
+	q := `query q($index: int, $user: string) {
+		revoked(func: eq(playlist.revoked, $user)) @filter(gt(playlist.revision, $index)) { playlist.id }`
+	if len(ids) > 0 {
+		q += `
+		changed(func: eq(playlist.id, [` + strings.Join(quoted, ",") + `])) @filter(gt(playlist.revision, $index)) { ` + fields + ` }`
+	}
+	q += `
+	}`
+
+	response, err := txn.QueryWithVars(ctx, q, map[string]string{
+		"$index": fmt.Sprint(index),
+		"$user":  user,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var result struct {
+		Revoked []*node `json:"revoked"`
+		Changed []*node `json:"changed"`
+	}
+
+	err = json.Unmarshal(response.Json, &result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revoked := make([]string, len(result.Revoked))
+	for i, n := range result.Revoked {
+		revoked[i] = n.ID
+	}
+
+	return result.Changed, revoked, nil
+}
+
+// lastRevision returns the revision of the playlist changed last.
+func lastRevision(ctx context.Context, client *dgo.Dgraph) (uint64, error) {
+	txn := client.NewReadOnlyTxn()
+	defer txn.Discard(ctx)
+
+	response, err := txn.Query(ctx, `{ q(func: has(playlist.revision), orderdesc: playlist.revision, first: 1) { playlist.revision } }`)
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		Q []*node `json:"q"`
+	}
+
+	err = json.Unmarshal(response.Json, &result)
+	if err != nil || len(result.Q) == 0 {
+		return 0, err
+	}
+
+	return result.Q[0].Revision, nil
+}
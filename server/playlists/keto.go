@@ -0,0 +1,152 @@
+package playlists
+
+import (
+	"context"
+
+	"github.com/bh90210/super/server/api"
+	relationtuples "github.com/ory/keto/proto/ory/keto/relation_tuples/v1alpha2"
+)
+
+// namespace is the Keto namespace of the playlists.
+const namespace = "playlists"
+
+// relations are the Keto relations of each role. Owners can do everything,
+// editors change the tracks and name, and viewers only get the playlist.
+var relations = map[api.Role]string{
+	api.Role_ROLE_OWNER:  "owner",
+	api.Role_ROLE_EDITOR: "editor",
+	api.Role_ROLE_VIEWER: "viewer",
+}
+
+func tuple(id, user string, role api.Role) *relationtuples.RelationTuple {
+	return &relationtuples.RelationTuple{
+		Namespace: namespace,
+		Object:    id,
+		Relation:  relations[role],
+		Subject:   relationtuples.NewSubjectID(user),
+	}
+}
+
+// role returns the role of user on the playlist with id, ROLE_NONE if it
+// isn't shared with them.
+func (s *Service) role(ctx context.Context, id, user string) (api.Role, error) {
+	for _, role := range []api.Role{api.Role_ROLE_OWNER, api.Role_ROLE_EDITOR, api.Role_ROLE_VIEWER} {
+		response, err := s.check.Check(ctx, &relationtuples.CheckRequest{Tuple: tuple(id, user, role)})
+		if err != nil {
+			return api.Role_ROLE_NONE, err
+		}
+
+		if response.Allowed {
+			return role, nil
+		}
+	}
+
+	return api.Role_ROLE_NONE, nil
+}
+
+// visible returns the ids of the playlists user has a role on, with the
+// role.
+func (s *Service) visible(ctx context.Context, user string) (map[string]api.Role, error) {
+	ns := namespace
+	roles := make(map[string]api.Role)
+
+	var token string
+	for {
+		response, err := s.read.ListRelationTuples(ctx, &relationtuples.ListRelationTuplesRequest{
+			RelationQuery: &relationtuples.RelationQuery{
+				Namespace: &ns,
+				Subject:   relationtuples.NewSubjectID(user),
+			},
+			PageToken: token,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range response.RelationTuples {
+			for role, relation := range relations {
+				if t.Relation == relation && role > roles[t.Object] {
+					roles[t.Object] = role
+				}
+			}
+		}
+
+		token = response.NextPageToken
+		if token == "" {
+			return roles, nil
+		}
+	}
+}
+
+// grant replaces the role of user on the playlist with id, ROLE_NONE
+// leaves them none.
+func (s *Service) grant(ctx context.Context, id, user string, role api.Role) error {
+	var deltas []*relationtuples.RelationTupleDelta
+	for r := range relations {
+		deltas = append(deltas, &relationtuples.RelationTupleDelta{
+			Action:        relationtuples.RelationTupleDelta_ACTION_DELETE,
+			RelationTuple: tuple(id, user, r),
+		})
+	}
+
+	if role != api.Role_ROLE_NONE {
+		deltas = append(deltas, &relationtuples.RelationTupleDelta{
+			Action:        relationtuples.RelationTupleDelta_ACTION_INSERT,
+			RelationTuple: tuple(id, user, role),
+		})
+	}
+
+	_, err := s.write.TransactRelationTuples(ctx, &relationtuples.TransactRelationTuplesRequest{
+		RelationTupleDeltas: deltas,
+	})
+
+	return err
+}
+
+// tuples returns the relations of every user on the playlist with id.
+func (s *Service) tuples(ctx context.Context, id string) ([]*relationtuples.RelationTuple, error) {
+	ns := namespace
+	var tuples []*relationtuples.RelationTuple
+
+	var token string
+	for {
+		response, err := s.read.ListRelationTuples(ctx, &relationtuples.ListRelationTuplesRequest{
+			RelationQuery: &relationtuples.RelationQuery{
+				Namespace: &ns,
+				Object:    &id,
+			},
+			PageToken: token,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		tuples = append(tuples, response.RelationTuples...)
+
+		token = response.NextPageToken
+		if token == "" {
+			return tuples, nil
+		}
+	}
+}
+
+// revoke removes the relations.
+func (s *Service) revoke(ctx context.Context, tuples []*relationtuples.RelationTuple) error {
+	if len(tuples) == 0 {
+		return nil
+	}
+
+	deltas := make([]*relationtuples.RelationTupleDelta, len(tuples))
+	for i, t := range tuples {
+		deltas[i] = &relationtuples.RelationTupleDelta{
+			Action:        relationtuples.RelationTupleDelta_ACTION_DELETE,
+			RelationTuple: t,
+		}
+	}
+
+	_, err := s.write.TransactRelationTuples(ctx, &relationtuples.TransactRelationTuplesRequest{
+		RelationTupleDeltas: deltas,
+	})
+
+	return err
+}
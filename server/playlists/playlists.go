@@ -0,0 +1,446 @@
+// Package playlists keeps the playlists of the users in Dgraph, with who
+// can see and change each one in Keto.
+package playlists
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bh90210/super/server/api"
+	dgo "github.com/dgraph-io/dgo/v250"
+	relationtuples "github.com/ory/keto/proto/ory/keto/relation_tuples/v1alpha2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ api.PlaylistsServer = (*Service)(nil)
+
+var (
+	errNoUser      = status.Error(codes.Unauthenticated, "missing or invalid token")
+	errNoSecret    = errors.New("no token secret")
+	errInvalidID   = status.Error(codes.InvalidArgument, "invalid playlist id")
+	errInvalidName = status.Error(codes.InvalidArgument, "invalid playlist name")
+	errDenied      = status.Error(codes.PermissionDenied, "not allowed")
+	errNotFound    = status.Error(codes.NotFound, "playlist not found")
+)
+
+// validID matches the ids clients make, crypto/rand.Text.
+var validID = regexp.MustCompile(`^[A-Z2-7]{26}$`)
+
+type Service struct {
+	api.UnimplementedPlaylistsServer
+
+	dgraph *dgo.Dgraph
+	check  relationtuples.CheckServiceClient
+	read   relationtuples.ReadServiceClient
+	write  relationtuples.WriteServiceClient
+	// secret signs the tokens of the users.
+	secret []byte
+
+	// revision is the last index a playlist changed at. Changes hold mu
+	// until committed, so every revision up to it is stored.
+	revision    uint64
+	subscribers map[chan struct{}]struct{}
+	mu          sync.Mutex
+}
+
+// NewService returns the service, its interceptors have to be installed on
+// the server for callers to be authenticated with tokens signed with
+// secret.
+func NewService(dgraph *dgo.Dgraph, ketoRead, ketoWrite *grpc.ClientConn, secret []byte) (*Service, error) {
+	// Anyone could sign tokens without one.
+	if len(secret) == 0 {
+		return nil, errNoSecret
+	}
+
+	ctx := context.Background()
+
+	err := dgraph.SetSchema(ctx, schema)
+	if err != nil {
+		slog.Error("dgraph.SetSchema", "error", err)
+		return nil, err
+	}
+
+	revision, err := lastRevision(ctx, dgraph)
+	if err != nil {
+		slog.Error("lastRevision", "error", err)
+		return nil, err
+	}
+
+	s := &Service{
+		dgraph:      dgraph,
+		check:       relationtuples.NewCheckServiceClient(ketoRead),
+		read:        relationtuples.NewReadServiceClient(ketoRead),
+		write:       relationtuples.NewWriteServiceClient(ketoWrite),
+		secret:      secret,
+		revision:    revision,
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+
+	return s, nil
+}
+
+func (s *Service) Get(request *api.PlaylistsRequest, response api.Playlists_GetServer) error {
+	ctx := response.Context()
+
+	user, err := user(ctx)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Playlists.Get", "user", user, "request", request)
+
+	changed := s.subscribe()
+	defer s.unsubscribe(changed)
+
+	index := request.Index
+	for {
+		index, err = s.send(ctx, response, user, index)
+		if err != nil {
+			slog.Error("Playlists.Get", "user", user, "error", err)
+			return err
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// send sends the changes after index that user can see, if there are any,
+// and returns the index they are up to.
+func (s *Service) send(ctx context.Context, response api.Playlists_GetServer, user string, index uint64) (uint64, error) {
+	s.mu.Lock()
+	current := s.revision
+	s.mu.Unlock()
+
+	// A client ahead of the server has playlists from before the server
+	// lost its own, it gets every one again.
+	if index > current {
+		index = 0
+	}
+
+	if current == index {
+		return index, nil
+	}
+
+	roles, err := s.visible(ctx, user)
+	if err != nil {
+		return index, err
+	}
+
+	ids := make([]string, 0, len(roles))
+	for id := range roles {
+		ids = append(ids, id)
+	}
+
+	txn := s.dgraph.NewReadOnlyTxn()
+	defer txn.Discard(ctx)
+
+	nodes, revoked, err := since(ctx, txn, index, ids, user)
+	if err != nil {
+		return index, err
+	}
+
+	message := &api.PlaylistsResponse{
+		Index:  current,
+		Remove: revoked,
+	}
+
+	for _, n := range nodes {
+		if n.Deleted {
+			message.Remove = append(message.Remove, n.ID)
+			continue
+		}
+
+		playlist, err := n.playlist(roles[n.ID])
+		if err != nil {
+			return index, err
+		}
+
+		message.Update = append(message.Update, playlist)
+	}
+
+	err = response.Send(message)
+	if err != nil {
+		return index, err
+	}
+
+	return current, nil
+}
+
+func (s *Service) Save(ctx context.Context, playlist *api.Playlist) (*api.Playlist, error) {
+	user, err := user(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !validID.MatchString(playlist.Id) {
+		return nil, errInvalidID
+	}
+
+	if strings.TrimSpace(playlist.Name) == "" {
+		return nil, errInvalidName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txn := s.dgraph.NewTxn()
+	defer txn.Discard(ctx)
+
+	stored, err := load(ctx, txn, playlist.Id)
+	if err != nil {
+		slog.Error("load", "id", playlist.Id, "error", err)
+		return nil, err
+	}
+
+	role := api.Role_ROLE_OWNER
+	if stored == nil {
+		// A new playlist, the caller owns it.
+		err = s.grant(ctx, playlist.Id, user, role)
+		if err != nil {
+			slog.Error("keto grant", "id", playlist.Id, "error", err)
+			return nil, err
+		}
+
+		playlist.Owner = user
+	} else {
+		if stored.Deleted {
+			return nil, errNotFound
+		}
+
+		role, err = s.role(ctx, playlist.Id, user)
+		if err != nil {
+			slog.Error("keto check", "id", playlist.Id, "error", err)
+			return nil, err
+		}
+
+		if role != api.Role_ROLE_OWNER && role != api.Role_ROLE_EDITOR {
+			return nil, errDenied
+		}
+
+		// The version changed last wins, the caller gets it back.
+		if stored.Modified > playlist.Modified {
+			return stored.playlist(role)
+		}
+
+		playlist.Owner = stored.Owner
+		playlist.Created = stored.Created
+	}
+
+	n, err := newNode(playlist)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored != nil {
+		n.UID = stored.UID
+	}
+
+	err = s.commit(ctx, txn, n)
+	if err != nil {
+		// Nothing was stored, so there is nothing to own.
+		if stored == nil {
+			undo := s.grant(context.WithoutCancel(ctx), playlist.Id, user, api.Role_ROLE_NONE)
+			if undo != nil {
+				slog.Error("keto grant", "id", playlist.Id, "error", undo)
+			}
+		}
+
+		return nil, err
+	}
+
+	return n.playlist(role)
+}
+
+func (s *Service) Delete(ctx context.Context, request *api.PlaylistDeleteRequest) (*api.PlaylistDeleteResponse, error) {
+	stored, txn, err := s.owned(ctx, request.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	defer txn.Discard(ctx)
+
+	shared, err := s.tuples(ctx, request.Id)
+	if err != nil {
+		slog.Error("keto list", "id", request.Id, "error", err)
+		return nil, err
+	}
+
+	// Already deleted if removing the relations failed before.
+	if !stored.Deleted {
+		// Everyone it was shared with learns it is gone the way they would
+		// if it was unshared, so the relations don't have to stay.
+		for _, t := range shared {
+			err = setRevoked(ctx, txn, stored, t.Subject.GetId(), true)
+			if err != nil {
+				slog.Error("setRevoked", "id", request.Id, "error", err)
+				return nil, err
+			}
+		}
+
+		stored.Name, stored.Tracks, stored.Deleted = "", "", true
+
+		err = s.commit(ctx, txn, stored)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.revoke(ctx, shared)
+	if err != nil {
+		slog.Error("keto revoke", "id", request.Id, "error", err)
+		return nil, err
+	}
+
+	return &api.PlaylistDeleteResponse{}, nil
+}
+
+func (s *Service) Share(ctx context.Context, request *api.ShareRequest) (*api.ShareResponse, error) {
+	if request.User == "" || request.Role == api.Role_ROLE_OWNER {
+		return nil, status.Error(codes.InvalidArgument, "invalid share")
+	}
+
+	stored, txn, err := s.owned(ctx, request.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	defer txn.Discard(ctx)
+
+	if stored.Deleted {
+		return nil, errNotFound
+	}
+
+	if request.User == stored.Owner {
+		return nil, status.Error(codes.InvalidArgument, "the owner can't be shared with")
+	}
+
+	// What the user had, given back if the playlist can't be stored.
+	previous, err := s.role(ctx, request.Id, request.User)
+	if err != nil {
+		slog.Error("keto check", "id", request.Id, "error", err)
+		return nil, err
+	}
+
+	err = s.grant(ctx, request.Id, request.User, request.Role)
+	if err != nil {
+		slog.Error("keto grant", "id", request.Id, "error", err)
+		return nil, err
+	}
+
+	err = setRevoked(ctx, txn, stored, request.User, request.Role == api.Role_ROLE_NONE)
+	if err == nil {
+		// A new revision, so the user gets it, or learns it is gone.
+		err = s.commit(ctx, txn, stored)
+	} else {
+		slog.Error("setRevoked", "id", request.Id, "error", err)
+	}
+
+	if err != nil {
+		undo := s.grant(context.WithoutCancel(ctx), request.Id, request.User, previous)
+		if undo != nil {
+			slog.Error("keto grant", "id", request.Id, "error", undo)
+		}
+
+		return nil, err
+	}
+
+	return &api.ShareResponse{}, nil
+}
+
+// owned loads the playlist with id if the caller owns it, deleted or not.
+// On success mu is held and the transaction open, the caller releases both.
+func (s *Service) owned(ctx context.Context, id string) (*node, *dgo.Txn, error) {
+	user, err := user(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !validID.MatchString(id) {
+		return nil, nil, errInvalidID
+	}
+
+	role, err := s.role(ctx, id, user)
+	if err != nil {
+		slog.Error("keto check", "id", id, "error", err)
+		return nil, nil, err
+	}
+
+	if role != api.Role_ROLE_OWNER {
+		return nil, nil, errDenied
+	}
+
+	s.mu.Lock()
+
+	txn := s.dgraph.NewTxn()
+	stored, err := load(ctx, txn, id)
+	if err == nil && stored == nil {
+		err = errNotFound
+	}
+
+	if err != nil {
+		txn.Discard(ctx)
+		s.mu.Unlock()
+		return nil, nil, err
+	}
+
+	return stored, txn, nil
+}
+
+// commit stores n at the next revision and tells the streams. Must be
+// called with mu held.
+func (s *Service) commit(ctx context.Context, txn *dgo.Txn, n *node) error {
+	n.Revision = s.revision + 1
+
+	err := save(ctx, txn, n)
+	if err == nil {
+		err = txn.Commit(ctx)
+	}
+
+	if err != nil {
+		slog.Error("dgraph commit", "id", n.ID, "error", err)
+		if errors.Is(err, dgo.ErrAborted) {
+			return status.Error(codes.Aborted, err.Error())
+		}
+
+		return err
+	}
+
+	s.revision = n.Revision
+
+	for changed := range s.subscribers {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// subscribe returns a channel that gets a value when a playlist changes.
+func (s *Service) subscribe() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := make(chan struct{}, 1)
+	s.subscribers[changed] = struct{}{}
+
+	return changed
+}
+
+func (s *Service) unsubscribe(changed chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscribers, changed)
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// indexKey is where search keeps the library index it is at.
+const indexKey = "index"
+
+// gobToProto rewrites the files of the library and the pinned ones, and
+// the library index, from gob to protobuf. Values that are protobuf already
+// are left, from a run that was interrupted.
+func gobToProto(db *badger.DB) error {
+	for _, prefix := range []string{super.File, super.Pinned} {
+		err := rewrite(db, []byte(prefix), func(key, value []byte) ([]byte, error) {
+			file := &api.File{}
+			err := gob.NewDecoder(bytes.NewReader(value)).Decode(file)
+			if err != nil {
+				return value, proto.Unmarshal(value, file)
+			}
+
+			return proto.Marshal(file)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(indexKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		var index uint64
+		err = gob.NewDecoder(bytes.NewReader(value)).Decode(&index)
+		if err != nil {
+			return proto.Unmarshal(value, &wrapperspb.UInt64Value{})
+		}
+
+		value, err = proto.Marshal(wrapperspb.UInt64(index))
+		if err != nil {
+			return err
+		}
+
+		return txn.Set([]byte(indexKey), value)
+	})
+}
+
+// legacyOwner owns the pins from before pins had owners, what was pinned
+// isn't known for those.
+const legacyOwner = ""
+
+// pinOwners moves the pinned files from pinned_<path> to
+// pinned_<owner>\x00<path>, the key the cache counts pins by. Keys moved
+// already, from a run that was interrupted, are left.
+func pinOwners(db *badger.DB) error {
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+
+	prefix := []byte(super.Pinned)
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			path := item.Key()[len(prefix):]
+			if bytes.IndexByte(path, 0) >= 0 {
+				continue
+			}
+
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			err = wb.Set([]byte(super.Pinned+legacyOwner+"\x00"+string(path)), value)
+			if err != nil {
+				return err
+			}
+
+			err = wb.Delete(item.KeyCopy(nil))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return wb.Flush()
+}
@@ -0,0 +1,152 @@
+// Package store keeps the local Badger store at the schema this version of
+// super reads, upgrading older data on start.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// ErrTooNew is returned for a store written by a newer version of super.
+var ErrTooNew = errors.New("store written by a newer version")
+
+// versionKey is where the schema version of the store is kept.
+const versionKey = super.Schema + "version"
+
+// migration upgrades the store from one version to the next.
+type migration struct {
+	name string
+	up   func(db *badger.DB) error
+}
+
+// migrations upgrade the store, the one at i from version i to i+1. Only
+// ever append to it, the version of the store is its length.
+var migrations = []migration{
+	{"gob to protobuf", gobToProto},
+	{"pins by owner", pinOwners},
+}
+
+// Version is the schema version this version of super writes.
+var Version = uint32(len(migrations))
+
+// Migrate upgrades the store to Version, one migration at a time. The
+// version is stored after each so an interrupted upgrade resumes from the
+// migration it was in, which must be safe to run again.
+func Migrate(db *badger.DB) error {
+	version, err := version(db)
+	if err != nil {
+		return err
+	}
+
+	if version > Version {
+		slog.Error("store.Migrate", "version", version, "supported", Version)
+		return ErrTooNew
+	}
+
+	for ; version < Version; version++ {
+		m := migrations[version]
+		slog.Info("migrating store", "from", version, "to", version+1, "migration", m.name)
+
+		err = m.up(db)
+		if err != nil {
+			slog.Error("store migration", "migration", m.name, "error", err)
+			return fmt.Errorf("migration %q: %w", m.name, err)
+		}
+
+		err = setVersion(db, version+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// version returns the schema version of the store. Stores from before
+// there was one are at 0.
+func version(db *badger.DB) (uint32, error) {
+	v := &wrapperspb.UInt32Value{}
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(versionKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(b []byte) error {
+			return proto.Unmarshal(b, v)
+		})
+	})
+	if err != nil {
+		slog.Error("reading the store version", "error", err)
+		return 0, err
+	}
+
+	return v.GetValue(), nil
+}
+
+func setVersion(db *badger.DB, version uint32) error {
+	b, err := proto.Marshal(wrapperspb.UInt32(version))
+	if err != nil {
+		slog.Error("proto.Marshal", "error", err)
+		return err
+	}
+
+	err = db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(versionKey), b)
+	})
+	if err != nil {
+		slog.Error("badger.Set", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// rewrite replaces the value of every key with prefix with what fn makes of
+// it. The values are read from a snapshot and written in batches, so a
+// store of any size fits.
+func rewrite(db *badger.DB, prefix []byte, fn func(key, value []byte) ([]byte, error)) error {
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			value, err = fn(item.KeyCopy(nil), value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", item.Key(), err)
+			}
+
+			err = wb.Set(item.KeyCopy(nil), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return wb.Flush()
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"testing"
+
+	"github.com/bh90210/super/server/api"
+	"github.com/bh90210/super/super"
+	badger "github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func open(t *testing.T) *badger.DB {
+	t.Helper()
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func encode(t *testing.T, v any) []byte {
+	t.Helper()
+
+	buf := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buf).Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func set(t *testing.T, db *badger.DB, values map[string][]byte) {
+	t.Helper()
+
+	err := db.Update(func(txn *badger.Txn) error {
+		for key, value := range values {
+			err := txn.Set([]byte(key), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// values returns every key with prefix and its value.
+func values(t *testing.T, db *badger.DB, prefix string) map[string][]byte {
+	t.Helper()
+
+	values := make(map[string][]byte)
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			values[string(it.Item().Key())] = value
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return values
+}
+
+// file returns the file stored at key, as protobuf.
+func file(t *testing.T, db *badger.DB, key string) *api.File {
+	t.Helper()
+
+	value, ok := values(t, db, key)[key]
+	if !ok {
+		t.Fatalf("%q is missing", key)
+	}
+
+	file := &api.File{}
+	err := proto.Unmarshal(value, file)
+	if err != nil {
+		t.Fatalf("%q isn't protobuf: %v", key, err)
+	}
+
+	return file
+}
+
+// fixture is a store as version 0 of super left it.
+func fixture(t *testing.T) *badger.DB {
+	t.Helper()
+
+	db := open(t)
+	set(t, db, map[string][]byte{
+		super.File + "/a.flac":   encode(t, &api.File{Path: "/a.flac", Artist: "A", Source: "server"}),
+		super.File + "/b.mp3":    encode(t, &api.File{Path: "/b.mp3", Artist: "B", Source: "/music"}),
+		super.Pinned + "/a.flac": encode(t, &api.File{Path: "/a.flac", Artist: "A", Source: "server"}),
+		indexKey:                 encode(t, uint64(42)),
+		// Not touched by any migration.
+		super.Setting + "volume": []byte("0.5"),
+	})
+
+	return db
+}
+
+func TestGobToProto(t *testing.T) {
+	db := fixture(t)
+
+	// Run twice, as if the first run was interrupted.
+	for range 2 {
+		err := gobToProto(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := file(t, db, super.File+"/b.mp3"); got.Artist != "B" || got.Source != "/music" {
+			t.Errorf("file %v", got)
+		}
+
+		if got := file(t, db, super.Pinned+"/a.flac"); got.Path != "/a.flac" {
+			t.Errorf("pinned %v", got)
+		}
+
+		index := &wrapperspb.UInt64Value{}
+		err = proto.Unmarshal(values(t, db, indexKey)[indexKey], index)
+		if err != nil || index.Value != 42 {
+			t.Errorf("index %v, %v, want 42", index, err)
+		}
+	}
+}
+
+func TestPinOwners(t *testing.T) {
+	db := fixture(t)
+
+	err := gobToProto(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pinned after the move by an owner, left as it is.
+	owned, err := proto.Marshal(&api.File{Path: "/b.mp3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set(t, db, map[string][]byte{super.Pinned + "album\x00/b.mp3": owned})
+
+	for range 2 {
+		err = pinOwners(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pinned := values(t, db, super.Pinned)
+		if len(pinned) != 2 {
+			t.Errorf("pinned keys %q, want 2", pinned)
+		}
+
+		if got := file(t, db, super.Pinned+legacyOwner+"\x00/a.flac"); got.Path != "/a.flac" {
+			t.Errorf("legacy pin %v", got)
+		}
+
+		if got := file(t, db, super.Pinned+"album\x00/b.mp3"); got.Path != "/b.mp3" {
+			t.Errorf("owned pin %v", got)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := fixture(t)
+
+	err := Migrate(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := version(db)
+	if err != nil || got != Version {
+		t.Fatalf("version %d, %v, want %d", got, err, Version)
+	}
+
+	if got := file(t, db, super.Pinned+legacyOwner+"\x00/a.flac"); got.Artist != "A" {
+		t.Errorf("pin %v after every migration", got)
+	}
+
+	if got := string(values(t, db, super.Setting+"volume")[super.Setting+"volume"]); got != "0.5" {
+		t.Errorf("unrelated setting %q", got)
+	}
+
+	// Nothing to do the second time.
+	err = Migrate(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = setVersion(db, Version+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Migrate(db)
+	if !errors.Is(err, ErrTooNew) {
+		t.Errorf("migrating a newer store: %v, want ErrTooNew", err)
+	}
+}
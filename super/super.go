@@ -1,12 +1,23 @@
 package super
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"os"
 	"path/filepath"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 const SuperServer = "super.aeroponics.club:443"
 
+// Dial returns a connection to the server, which only talks TLS. It is
+// made once and shared by every client.
+func Dial() (*grpc.ClientConn, error) {
+	return grpc.NewClient(SuperServer, grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(nil, "")))
+}
+
 type Storage string
 
 const superStore Storage = ".super"
@@ -31,6 +42,29 @@ func LocalStorage(store ...Storage) string {
 	return filepath.Join(paths...)
 }
 
+// MusicFile returns where the downloaded copy of a remote file is stored.
+func MusicFile(path string) string {
+	hashed := sha256.Sum256([]byte(path))
+
+	return LocalStorage(MusicStore, Storage(fmt.Sprintf("%x", hashed)))
+}
+
 const (
-	File = "file_"
+	File     = "file_"
+	Source   = "source_"
+	Cached   = "cached_"
+	Pinned   = "pinned_"
+	Setting  = "setting_"
+	Queue    = "queue_"
+	Recent   = "recent_"
+	Journal  = "journal_"
+	Schema   = "schema_"
+	Playlist = "playlist_"
+	Pending  = "pending_"
+	Offline  = "offline_"
 )
+
+// Local reports whether source is a local folder rather than a server.
+func Local(source string) bool {
+	return filepath.IsAbs(source)
+}